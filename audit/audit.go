@@ -0,0 +1,282 @@
+package audit
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type contextKey string
+
+const (
+	requestKey  contextKey = "auditRequest"
+	recorderKey contextKey = "auditRecorder"
+)
+
+// event is a document of the AuditLog collection. Events are only ever inserted.
+type event struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty"`
+	ActorID   *primitive.ObjectID `bson:"actorId,omitempty"`
+	ActorRole string              `bson:"actorRole,omitempty"`
+	Operation string              `bson:"operation"`
+	Entity    string              `bson:"entity,omitempty"`
+	EntityID  string              `bson:"entityId,omitempty"`
+	Changes   []change            `bson:"changes"`
+	Arguments string              `bson:"arguments,omitempty"`
+	Success   bool                `bson:"success"`
+	Error     string              `bson:"error,omitempty"`
+	IP        string              `bson:"ip,omitempty"`
+	RequestID string              `bson:"requestId,omitempty"`
+	Timestamp time.Time           `bson:"timestamp"`
+}
+
+// change is one modified field, values are JSON encoded
+type change struct {
+	Field  string  `bson:"field"`
+	Before *string `bson:"before,omitempty"`
+	After  *string `bson:"after,omitempty"`
+}
+
+type request struct {
+	ip string
+	id string
+}
+
+// recorder collects what a mutation changed while it runs
+type recorder struct {
+	sync.Mutex
+	entity   string
+	entityID string
+	changes  []change
+}
+
+// Middleware tags every request with the client IP and a request ID, reusing
+// X-Request-ID when the client or a proxy already set one
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = primitive.NewObjectID().Hex()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := context.WithValue(r.Context(), requestKey, request{ip: clientIP(r), id: requestID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FieldMiddleware records an audit event for every mutation, whether it
+// succeeded or not
+func FieldMiddleware(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || fc.Object != "Mutation" {
+		return next(ctx)
+	}
+
+	rec := &recorder{}
+	res, err := next(context.WithValue(ctx, recorderKey, rec))
+	record(ctx, fc.Field.Name, fc.Args, rec, err)
+	return res, err
+}
+
+// Track attaches the entity a mutation changed to its audit event, with the
+// fields that differ between before and after. Either may be nil for creations
+// and deletions.
+func Track(ctx context.Context, entity string, entityID string, before interface{}, after interface{}) {
+	rec, ok := ctx.Value(recorderKey).(*recorder)
+	if !ok {
+		return
+	}
+
+	changes, err := diff(before, after)
+	if err != nil {
+		log.Printf("audit: failed to diff %s %s: %v", entity, entityID, err)
+	}
+
+	rec.Lock()
+	defer rec.Unlock()
+	rec.entity = entity
+	rec.entityID = entityID
+	rec.changes = append(rec.changes, changes...)
+}
+
+func record(ctx context.Context, operation string, args map[string]interface{}, rec *recorder, resolverErr error) {
+	rec.Lock()
+	e := event{
+		Operation: operation,
+		Entity:    rec.entity,
+		EntityID:  rec.entityID,
+		Changes:   rec.changes,
+		Success:   resolverErr == nil,
+		Timestamp: time.Now(),
+	}
+	rec.Unlock()
+
+	if e.Changes == nil {
+		e.Changes = []change{}
+	}
+	if e.EntityID == "" {
+		e.Entity, e.EntityID = targetFromArgs(args)
+	}
+	if resolverErr != nil {
+		e.Error = resolverErr.Error()
+	}
+	if userID, ok := auth.GetUserID(ctx); ok {
+		if actorId, err := primitive.ObjectIDFromHex(userID); err == nil {
+			e.ActorID = &actorId
+		}
+	}
+	if accountType, ok := auth.GetAccountType(ctx); ok {
+		e.ActorRole = accountType
+	}
+	if req, ok := ctx.Value(requestKey).(request); ok {
+		e.IP = req.ip
+		e.RequestID = req.id
+	}
+	if arguments, err := json.Marshal(redact(args)); err == nil {
+		e.Arguments = string(arguments)
+	}
+
+	insert(ctx, e)
+}
+
+// Summary records work done outside a mutation, such as a background import
+// that saves books without Track, as one event. The fields of summary are
+// stored as changes without a before value. actorID is whoever asked for the
+// work, nil for command line tools.
+func Summary(ctx context.Context, operation string, entity string, entityID string, actorID *primitive.ObjectID, summary interface{}, workErr error) {
+	changes, err := diff(nil, summary)
+	if err != nil {
+		log.Printf("audit: failed to encode %s %s: %v", entity, entityID, err)
+	}
+	e := event{
+		ActorID:   actorID,
+		Operation: operation,
+		Entity:    entity,
+		EntityID:  entityID,
+		Changes:   changes,
+		Success:   workErr == nil,
+		Timestamp: time.Now(),
+	}
+	if e.Changes == nil {
+		e.Changes = []change{}
+	}
+	if workErr != nil {
+		e.Error = workErr.Error()
+	}
+	insert(ctx, e)
+}
+
+func insert(ctx context.Context, e event) {
+	// the work already happened, record it even if the client went away
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if _, err := database.DB.Collection("AuditLog").InsertOne(writeCtx, e); err != nil {
+		log.Printf("audit: failed to record %s: %v", e.Operation, err)
+	}
+}
+
+// targetFromArgs guesses the target of a mutation that did not call Track
+func targetFromArgs(args map[string]interface{}) (string, string) {
+	for _, arg := range []struct{ name, entity string }{
+		{"bookId", "Book"},
+		{"reviewId", "Review"},
+		{"discussionId", "Discussion"},
+		{"id", ""},
+	} {
+		if id, ok := args[arg.name].(string); ok && id != "" {
+			return arg.entity, id
+		}
+	}
+	return "", ""
+}
+
+// trustedProxies are the networks of the reverse proxies in front of the
+// server, from TRUSTED_PROXIES as comma separated IPs or CIDRs. Only their
+// X-Forwarded-For and X-Real-IP headers are believed, anyone else could set
+// them to hide behind another address.
+var trustedProxies = parseProxies(os.Getenv("TRUSTED_PROXIES"))
+
+func parseProxies(list string) []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("audit: ignoring invalid trusted proxy %q", entry)
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+func trusted(proxies []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func clientIP(r *http.Request) string {
+	return forwardedIP(r, trustedProxies)
+}
+
+// forwardedIP is the address of the client behind proxies. Addresses in
+// X-Forwarded-For are read from the right, each one added by the hop before,
+// and the first not added by a trusted proxy is the client.
+func forwardedIP(r *http.Request, proxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(proxies, host) {
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			host = hop
+			if !trusted(proxies, hop) {
+				break
+			}
+		}
+		return host
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return host
+}
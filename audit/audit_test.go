@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestForwardedIP(t *testing.T) {
+	proxies := parseProxies("10.0.0.0/8, 192.168.1.5, fd00::/8")
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"direct", "203.0.113.7:5123", "", "", "203.0.113.7"},
+		{"spoofed forwarded for from untrusted client", "203.0.113.7:5123", "1.2.3.4", "", "203.0.113.7"},
+		{"spoofed real ip from untrusted client", "203.0.113.7:5123", "", "1.2.3.4", "203.0.113.7"},
+		{"behind a trusted proxy", "10.1.2.3:443", "198.51.100.9", "", "198.51.100.9"},
+		{"proxy given by IP", "192.168.1.5:443", "198.51.100.9", "", "198.51.100.9"},
+		{"client prepends a fake hop", "10.1.2.3:443", "1.2.3.4, 198.51.100.9", "", "198.51.100.9"},
+		{"chain of trusted proxies", "10.1.2.3:443", "198.51.100.9, 10.4.4.4", "", "198.51.100.9"},
+		{"only trusted hops", "10.1.2.3:443", "10.4.4.4", "", "10.4.4.4"},
+		{"garbage hop stops the walk", "10.1.2.3:443", "198.51.100.9, nonsense", "", "10.1.2.3"},
+		{"real ip behind a trusted proxy", "10.1.2.3:443", "", "198.51.100.9", "198.51.100.9"},
+		{"trusted proxy without headers", "10.1.2.3:443", "", "", "10.1.2.3"},
+		{"ipv6 proxy", "[fd00::1]:443", "2001:db8::5", "", "2001:db8::5"},
+		{"untrusted ipv6", "[2001:db8::9]:443", "1.2.3.4", "", "2001:db8::9"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := forwardedIP(r, proxies); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	if got := parseProxies(""); len(got) != 0 {
+		t.Errorf("no proxies configured: got %v", got)
+	}
+	if got := parseProxies("10.0.0.0/8,not-an-ip,::1"); len(got) != 2 {
+		t.Errorf("got %v, want the two valid entries", got)
+	}
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveArgs are never written to the audit log, matched case-insensitively
+// anywhere in the arguments
+var sensitiveArgs = map[string]bool{
+	"password":        true,
+	"newpassword":     true,
+	"currentpassword": true,
+	"otp":             true,
+	"paymentdetails":  true,
+	"token":           true,
+}
+
+// diff compares the stored form of before and after field by field
+func diff(before interface{}, after interface{}) ([]change, error) {
+	beforeDoc, err := toDocument(before)
+	if err != nil {
+		return nil, err
+	}
+	afterDoc, err := toDocument(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]bool{}
+	for field := range beforeDoc {
+		fields[field] = true
+	}
+	for field := range afterDoc {
+		fields[field] = true
+	}
+	delete(fields, "_id")
+
+	changes := []change{}
+	for field := range fields {
+		beforeValue, inBefore := beforeDoc[field]
+		afterValue, inAfter := afterDoc[field]
+		if inBefore && inAfter && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		c := change{Field: field}
+		if inBefore {
+			c.Before = encode(beforeValue)
+		}
+		if inAfter {
+			c.After = encode(afterValue)
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// toDocument converts v to the document it is stored as, nested documents
+// decode as maps so they compare and encode like the top level
+func toDocument(v interface{}) (bson.M, error) {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return bson.M{}, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	decoder.DefaultDocumentM()
+	doc := bson.M{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func encode(v interface{}) *string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	s := string(data)
+	return &s
+}
+
+// redact returns the JSON form of args with sensitive values replaced
+func redact(args map[string]interface{}) interface{} {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil
+	}
+	return redactValue(decoded)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if sensitiveArgs[strings.ToLower(key)] {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+	return v
+}
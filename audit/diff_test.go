@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testBook struct {
+	ID     string   `bson:"_id,omitempty"`
+	Title  string   `bson:"title"`
+	Author string   `bson:"author"`
+	Tags   []string `bson:"tags,omitempty"`
+	Pages  int      `bson:"pages"`
+	Series *struct {
+		Name   string `bson:"name"`
+		Number int    `bson:"number"`
+	} `bson:"series,omitempty"`
+}
+
+func TestDiff(t *testing.T) {
+	str := func(s string) *string { return &s }
+	book := testBook{ID: "1", Title: "Dune", Author: "Frank Herbert", Pages: 412, Tags: []string{"classic"}}
+	edited := book
+	edited.Title = "Dune Messiah"
+	edited.Tags = []string{"classic", "sequel"}
+	series := book
+	series.Series = &struct {
+		Name   string `bson:"name"`
+		Number int    `bson:"number"`
+	}{"Dune", 1}
+	renumbered := series
+	renumbered.Series = &struct {
+		Name   string `bson:"name"`
+		Number int    `bson:"number"`
+	}{"Dune", 2}
+
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          []change
+	}{
+		{"unchanged", &book, &book, []change{}},
+		{"edited fields in name order", &book, &edited, []change{
+			{Field: "tags", Before: str(`["classic"]`), After: str(`["classic","sequel"]`)},
+			{Field: "title", Before: str(`"Dune"`), After: str(`"Dune Messiah"`)},
+		}},
+		{"created", nil, &book, []change{
+			{Field: "author", After: str(`"Frank Herbert"`)},
+			{Field: "pages", After: str(`412`)},
+			{Field: "tags", After: str(`["classic"]`)},
+			{Field: "title", After: str(`"Dune"`)},
+		}},
+		{"deleted through a nil pointer", &book, (*testBook)(nil), []change{
+			{Field: "author", Before: str(`"Frank Herbert"`)},
+			{Field: "pages", Before: str(`412`)},
+			{Field: "tags", Before: str(`["classic"]`)},
+			{Field: "title", Before: str(`"Dune"`)},
+		}},
+		{"nested document added", &book, &series, []change{
+			{Field: "series", After: str(`{"name":"Dune","number":1}`)},
+		}},
+		{"nested document changed", &series, &renumbered, []change{
+			{Field: "series", Before: str(`{"name":"Dune","number":1}`), After: str(`{"name":"Dune","number":2}`)},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := diff(tt.before, tt.after)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, formatChanges(got), formatChanges(tt.want))
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	args := map[string]interface{}{
+		"email":    "reader@example.com",
+		"password": "hunter2",
+		"input": map[string]interface{}{
+			"NewPassword": "hunter3",
+			"cards":       []interface{}{map[string]interface{}{"paymentDetails": "4111", "name": "visa"}},
+		},
+	}
+	got := redact(args)
+	want := map[string]interface{}{
+		"email":    "reader@example.com",
+		"password": redacted,
+		"input": map[string]interface{}{
+			"NewPassword": redacted,
+			"cards":       []interface{}{map[string]interface{}{"paymentDetails": redacted, "name": "visa"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redact = %v, want %v", got, want)
+	}
+}
+
+func formatChanges(changes []change) string {
+	s := "["
+	for _, c := range changes {
+		s += " " + c.Field + ":"
+		if c.Before != nil {
+			s += *c.Before
+		}
+		s += "->"
+		if c.After != nil {
+			s += *c.After
+		}
+	}
+	return s + " ]"
+}
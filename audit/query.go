@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bmsgql/paging"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	dateLayout = "2006-01-02"
+)
+
+// AuditLog lists audit events, newest first
+func AuditLog(ctx context.Context, filter *model.AuditLogFilterInput, limit *int, offset *int) ([]*model.AuditEvent, error) {
+	AuditCollection := database.DB.Collection("AuditLog")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	query := bson.M{}
+	if filter != nil {
+		if filter.ActorID != nil {
+			actorId, err := primitive.ObjectIDFromHex(*filter.ActorID)
+			if err != nil {
+				return nil, fmt.Errorf("invalid actor ID")
+			}
+			query["actorId"] = actorId
+		}
+		if filter.Entity != nil {
+			query["entity"] = *filter.Entity
+		}
+		if filter.EntityID != nil {
+			query["entityId"] = *filter.EntityID
+		}
+		if filter.Operation != nil {
+			query["operation"] = *filter.Operation
+		}
+		if filter.DateRange != nil {
+			from, err := parseDate(filter.DateRange.StartDate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid start date: %w", err)
+			}
+			to, err := parseDate(filter.DateRange.EndDate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid end date: %w", err)
+			}
+			// a plain end date includes the whole day
+			if len(filter.DateRange.EndDate) == len(dateLayout) {
+				to = to.AddDate(0, 0, 1)
+			}
+			query["timestamp"] = bson.M{"$gte": from, "$lt": to}
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(paging.Offset(offset))).
+		SetLimit(int64(paging.Limit(limit)))
+	cursor, err := AuditCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch audit log: %w", err)
+	}
+	var events []event
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, fmt.Errorf("failed to decode audit log: %w", err)
+	}
+
+	auditEvents := make([]*model.AuditEvent, len(events))
+	for i, e := range events {
+		auditEvents[i] = e.toModel()
+	}
+	return auditEvents, nil
+}
+
+func (e *event) toModel() *model.AuditEvent {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+
+	changes := make([]*model.FieldChange, len(e.Changes))
+	for i, c := range e.Changes {
+		changes[i] = &model.FieldChange{Field: c.Field, Before: c.Before, After: c.After}
+	}
+
+	return &model.AuditEvent{
+		ID:        e.ID.Hex(),
+		ActorID:   e.ActorID,
+		ActorRole: optional(e.ActorRole),
+		Operation: e.Operation,
+		Entity:    optional(e.Entity),
+		EntityID:  optional(e.EntityID),
+		Changes:   changes,
+		Arguments: optional(e.Arguments),
+		Success:   e.Success,
+		Error:     optional(e.Error),
+		IP:        optional(e.IP),
+		RequestID: optional(e.RequestID),
+		Timestamp: e.Timestamp.Format(time.RFC3339),
+	}
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, value)
+}
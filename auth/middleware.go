@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 )
 
 var (
@@ -98,6 +100,10 @@ func extractOperationName(query string) string {
 func validateAuthHeader(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	fmt.Printf("Authorization header: %v\n", authHeader)
+	return bearerToken(authHeader)
+}
+
+func bearerToken(authHeader string) (string, error) {
 	if authHeader == "" {
 		return "", ErrMissingAuth
 	}
@@ -116,7 +122,15 @@ func validateAuthHeader(r *http.Request) (string, error) {
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("AuthMiddleware triggered")
-		if r.Method == http.MethodPost {
+		// Browsers cannot set headers on WebSocket upgrades, subscriptions
+		// authenticate with the connection_init payload in WebSocketInit instead
+		if isWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Multipart uploads carry the operation in a form field next to the files,
+		// none of them is public so they always need a token
+		if r.Method == http.MethodPost && !isMultipart(r) {
 			bodyBytes, err := io.ReadAll(r.Body)
 			if err != nil {
 				http.Error(w, `{"errors": [{"message": "invalid request body"}]}`, http.StatusBadRequest)
@@ -150,12 +164,38 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			http.Error(w, `{"errors": [{"message": "`+err.Error()+`"}]}`, http.StatusUnauthorized)
 			return
 		}
-		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, userRoleKey, claims.UserRole)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
 	})
 }
 
+// WebSocketInit authenticates a subscription connection using the Authorization
+// value of the connection_init payload, the same way AuthMiddleware checks the header
+func WebSocketInit(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	tokenStr, err := bearerToken(initPayload.Authorization())
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := ValidateJWT(tokenStr)
+	if err != nil {
+		fmt.Printf("JWT validation error: %v\n", err)
+		return nil, nil, err
+	}
+	return withClaims(ctx, claims), &initPayload, nil
+}
+
+func withClaims(ctx context.Context, claims *Claims) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, claims.UserID)
+	return context.WithValue(ctx, userRoleKey, claims.UserRole)
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func isMultipart(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+}
+
 // Helper function to remove __typename from the query
 func removeTypenameFromQuery(query string) string {
 	// Simple regex to remove __typename
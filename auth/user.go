@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CurrentUser returns the ID of the signed in user
+func CurrentUser(ctx context.Context) (primitive.ObjectID, error) {
+	userID, ok := GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return primitive.NilObjectID, fmt.Errorf("user not authenticated")
+	}
+
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("invalid user ID")
+	}
+	return userObjId, nil
+}
+
+// RequireAdmin returns the ID of the signed in user when they are an ADMIN
+func RequireAdmin(ctx context.Context) (primitive.ObjectID, error) {
+	userID, ok := GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return primitive.NilObjectID, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return primitive.NilObjectID, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return primitive.NilObjectID, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("invalid user ID")
+	}
+	return userObjId, nil
+}
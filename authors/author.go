@@ -0,0 +1,465 @@
+package authors
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/paging"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Author(ctx context.Context, id string) (*model.Author, error) {
+	AuthorCollection := database.DB.Collection("Authors")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	authorId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid author ID")
+	}
+
+	var author model.Author
+	err = AuthorCollection.FindOne(ctx, bson.M{"_id": authorId}).Decode(&author)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errcode.Errorf(errcode.NotFound, "author not found")
+		}
+		return nil, fmt.Errorf("failed to find author: %w", err)
+	}
+	return &author, nil
+}
+
+// SearchAuthors matches query against names and aliases, ignoring case, spacing
+// and punctuation so "jrr tolkien" finds "J.R.R. Tolkien"
+func SearchAuthors(ctx context.Context, query string, limit, offset *int) ([]*model.Author, error) {
+	AuthorCollection := database.DB.Collection("Authors")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []*model.Author{}, nil
+	}
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+	match := bson.A{bson.M{"name": pattern}, bson.M{"aliases": pattern}}
+	if key := NameKey(query); key != "" {
+		match = append(match, bson.M{"nameKeys": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(key)}})
+	}
+
+	cursor, err := AuthorCollection.Find(ctx, bson.M{"$or": match}, options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(paging.Offset(offset))).
+		SetLimit(int64(paging.Limit(limit))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search authors: %w", err)
+	}
+	authorlist := []*model.Author{}
+	if err := cursor.All(ctx, &authorlist); err != nil {
+		return nil, fmt.Errorf("failed to decode authors: %w", err)
+	}
+	return authorlist, nil
+}
+
+// Books lists the books an author contributed to, in any role
+func Books(ctx context.Context, author *model.Author, limit, offset *int) ([]*model.Book, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	authorId, err := primitive.ObjectIDFromHex(author.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid author ID")
+	}
+
+	cursor, err := BookCollection.Find(ctx,
+		bson.M{"contributors.authorId": authorId, "deletedAt": bson.M{"$exists": false}},
+		options.Find().
+			SetSort(bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64(paging.Offset(offset))).
+			SetLimit(int64(paging.Limit(limit))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	books := []*model.Book{}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+	return books, nil
+}
+
+func AddAuthor(ctx context.Context, input model.AuthorInput) (*model.Author, error) {
+	AuthorCollection := database.DB.Collection("Authors")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	name := strings.Join(strings.Fields(input.Name), " ")
+	if NameKey(name) == "" {
+		return nil, errcode.Errorf(errcode.BadUserInput, "author name is required")
+	}
+
+	// authors sharing a name are allowed, two people can have the same one
+	author := &model.Author{
+		Name:    name,
+		Aliases: cleanAliases(name, input.Aliases),
+	}
+	if input.Bio != nil {
+		author.Bio = *input.Bio
+	}
+	if input.Photo != nil {
+		author.Photo = *input.Photo
+	}
+	author.NameKeys = nameKeys(author.Name, author.Aliases)
+
+	result, err := AuthorCollection.InsertOne(ctx, bson.M{
+		"name":     author.Name,
+		"bio":      author.Bio,
+		"aliases":  author.Aliases,
+		"photo":    author.Photo,
+		"nameKeys": author.NameKeys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add author: %w", err)
+	}
+	author.ID = result.InsertedID.(primitive.ObjectID).Hex()
+
+	audit.Track(ctx, "Author", author.ID, nil, author)
+	return author, nil
+}
+
+// EditAuthor updates an author. A new name is shown on all their books, the
+// old one is kept as an alias so it still finds them.
+func EditAuthor(ctx context.Context, id string, input model.EditAuthorInput) (*model.Author, error) {
+	AuthorCollection := database.DB.Collection("Authors")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	authorId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid author ID")
+	}
+
+	var before model.Author
+	err = AuthorCollection.FindOne(ctx, bson.M{"_id": authorId}).Decode(&before)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.NotFound, "author not found")
+	}
+
+	name, aliases := before.Name, before.Aliases
+	if input.Aliases != nil {
+		aliases = input.Aliases
+	}
+	if input.Name != nil {
+		name = strings.Join(strings.Fields(*input.Name), " ")
+		if NameKey(name) == "" {
+			return nil, errcode.Errorf(errcode.BadUserInput, "author name is required")
+		}
+		if name != before.Name {
+			aliases = append(aliases, before.Name)
+		}
+	}
+	aliases = cleanAliases(name, aliases)
+
+	updateAuthor := bson.M{"name": name, "aliases": aliases, "nameKeys": nameKeys(name, aliases)}
+	if input.Bio != nil {
+		updateAuthor["bio"] = input.Bio
+	}
+	if input.Photo != nil {
+		updateAuthor["photo"] = input.Photo
+	}
+
+	var author model.Author
+	err = AuthorCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": authorId},
+		bson.M{"$set": updateAuthor},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&author)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update author: %w", err)
+	}
+
+	if author.Name != before.Name {
+		if err := refreshBooks(ctx, authorId); err != nil {
+			return nil, err
+		}
+	}
+
+	audit.Track(ctx, "Author", id, &before, &author)
+	return &author, nil
+}
+
+// Credit is a name credited on a book in a role
+type Credit struct {
+	Name string
+	Role model.ContributorRole
+}
+
+// Resolve links a plain author string to author records, creating the ones not
+// seen before, and returns them as AUTHOR contributors together with the names
+// to show. Known spellings map to the existing author, new spellings of a known
+// author become aliases.
+func Resolve(ctx context.Context, author string) ([]*model.BookContributor, string, error) {
+	return ResolveCredits(ctx, Authored(SplitNames(author)))
+}
+
+// ResolveCredits is Resolve for names credited in any role, such as the added
+// entries of a MARC record. The names to show are chosen as Display does.
+func ResolveCredits(ctx context.Context, credits []Credit) ([]*model.BookContributor, string, error) {
+	contributors, display, _, err := resolveNames(ctx, credits)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(contributors) == 0 {
+		return nil, "", errcode.Errorf(errcode.BadUserInput, "author is required")
+	}
+	return contributors, display, nil
+}
+
+// Authored credits every name as an AUTHOR
+func Authored(names []string) []Credit {
+	credits := make([]Credit, len(names))
+	for i, name := range names {
+		credits[i] = Credit{Name: name, Role: model.ContributorRoleAuthor}
+	}
+	return credits
+}
+
+// resolveNames does the work of ResolveCredits and also counts the authors it
+// created. Names without a letter or digit are skipped.
+func resolveNames(ctx context.Context, credits []Credit) ([]*model.BookContributor, string, int, error) {
+	contributors := []*model.BookContributor{}
+	var credited, authored []string
+	created := 0
+	seen := map[model.BookContributor]bool{}
+	for _, credit := range credits {
+		if NameKey(credit.Name) == "" {
+			continue
+		}
+		found, isNew, err := findOrCreate(ctx, credit.Name)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		if isNew {
+			created++
+		}
+		authorId, _ := primitive.ObjectIDFromHex(found.ID)
+		contributor := model.BookContributor{AuthorID: authorId, Role: credit.Role}
+		if seen[contributor] {
+			continue
+		}
+		seen[contributor] = true
+		contributors = append(contributors, &contributor)
+		if !contains(credited, found.Name) {
+			credited = append(credited, found.Name)
+		}
+		if credit.Role == model.ContributorRoleAuthor && !contains(authored, found.Name) {
+			authored = append(authored, found.Name)
+		}
+	}
+	if len(authored) > 0 {
+		return contributors, JoinNames(authored), created, nil
+	}
+	return contributors, JoinNames(credited), created, nil
+}
+
+// Contributors checks the authors of explicit contributor input exist and
+// returns the contributors together with the names to show
+func Contributors(ctx context.Context, inputs []*model.ContributorInput) ([]*model.BookContributor, string, error) {
+	if len(inputs) == 0 {
+		return nil, "", errcode.Errorf(errcode.BadUserInput, "a book needs at least one contributor")
+	}
+
+	contributors := []*model.BookContributor{}
+	seen := map[model.BookContributor]bool{}
+	for _, input := range inputs {
+		authorId, err := primitive.ObjectIDFromHex(input.AuthorID)
+		if err != nil {
+			return nil, "", errcode.Errorf(errcode.BadUserInput, "invalid author ID %q", input.AuthorID)
+		}
+		role := model.ContributorRoleAuthor
+		if input.Role != nil {
+			role = *input.Role
+		}
+		contributor := model.BookContributor{AuthorID: authorId, Role: role}
+		if seen[contributor] {
+			continue
+		}
+		seen[contributor] = true
+		contributors = append(contributors, &contributor)
+	}
+
+	display, err := Display(ctx, contributors)
+	if err != nil {
+		return nil, "", err
+	}
+	return contributors, display, nil
+}
+
+// Display is the author string of a book: its authors, or everyone credited
+// when it has no author, such as an anthology with only an editor
+func Display(ctx context.Context, contributors []*model.BookContributor) (string, error) {
+	AuthorCollection := database.DB.Collection("Authors")
+
+	ids := bson.A{}
+	for _, contributor := range contributors {
+		ids = append(ids, contributor.AuthorID)
+	}
+	cursor, err := AuthorCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return "", fmt.Errorf("failed to look up authors: %w", err)
+	}
+	var found []model.Author
+	if err := cursor.All(ctx, &found); err != nil {
+		return "", fmt.Errorf("failed to decode authors: %w", err)
+	}
+	names := map[string]string{}
+	for _, author := range found {
+		names[author.ID] = author.Name
+	}
+
+	var credited, authored []string
+	for _, contributor := range contributors {
+		name, ok := names[contributor.AuthorID.Hex()]
+		if !ok {
+			return "", errcode.Errorf(errcode.NotFound, "author %s not found", contributor.AuthorID.Hex())
+		}
+		if !contains(credited, name) {
+			credited = append(credited, name)
+		}
+		if contributor.Role == model.ContributorRoleAuthor && !contains(authored, name) {
+			authored = append(authored, name)
+		}
+	}
+	if len(authored) > 0 {
+		return JoinNames(authored), nil
+	}
+	return JoinNames(credited), nil
+}
+
+// findOrCreate returns the oldest author known by name, or a new one. The bool
+// reports whether the author was created.
+func findOrCreate(ctx context.Context, name string) (*model.Author, bool, error) {
+	AuthorCollection := database.DB.Collection("Authors")
+
+	key := NameKey(name)
+	if key == "" {
+		return nil, false, errcode.Errorf(errcode.BadUserInput, "invalid author name %q", name)
+	}
+
+	var author model.Author
+	err := AuthorCollection.FindOne(ctx, bson.M{"nameKeys": key}, options.FindOne().SetSort(bson.M{"_id": 1})).Decode(&author)
+	if err == nil {
+		if name != author.Name && !contains(author.Aliases, name) {
+			authorId, _ := primitive.ObjectIDFromHex(author.ID)
+			_, err = AuthorCollection.UpdateOne(ctx, bson.M{"_id": authorId}, bson.M{"$addToSet": bson.M{"aliases": name}})
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to record author alias: %w", err)
+			}
+		}
+		return &author, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, fmt.Errorf("failed to look up author: %w", err)
+	}
+
+	author = model.Author{Name: name, Aliases: []string{}, NameKeys: []string{key}}
+	result, err := AuthorCollection.InsertOne(ctx, bson.M{
+		"name":     author.Name,
+		"bio":      "",
+		"aliases":  author.Aliases,
+		"photo":    "",
+		"nameKeys": author.NameKeys,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to add author: %w", err)
+	}
+	author.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return &author, true, nil
+}
+
+// refreshBooks rewrites the author string of every book an author contributed to
+func refreshBooks(ctx context.Context, authorId primitive.ObjectID) error {
+	BookCollection := database.DB.Collection("Books")
+
+	cursor, err := BookCollection.Find(ctx, bson.M{"contributors.authorId": authorId}, options.Find().SetProjection(bson.M{"contributors": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to fetch books: %w", err)
+	}
+	var books []model.Book
+	if err := cursor.All(ctx, &books); err != nil {
+		return fmt.Errorf("failed to decode books: %w", err)
+	}
+	for _, book := range books {
+		display, err := Display(ctx, book.Contributors)
+		if err != nil {
+			return err
+		}
+		bookId, _ := primitive.ObjectIDFromHex(book.ID)
+		if _, err := BookCollection.UpdateOne(ctx, bson.M{"_id": bookId}, bson.M{"$set": bson.M{"author": display}}); err != nil {
+			return fmt.Errorf("failed to update book author: %w", err)
+		}
+	}
+	return nil
+}
+
+// EnsureIndexes creates the index author lookups by name rely on
+func EnsureIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("Authors").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "nameKeys", Value: 1}},
+		Options: options.Index().SetName("name_keys"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create author name index: %w", err)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
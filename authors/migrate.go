@@ -0,0 +1,104 @@
+package authors
+
+import (
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationResult counts what Migrate changed
+type MigrationResult struct {
+	Books     int
+	Authors   int
+	Skipped   int
+	Refreshed int
+}
+
+// Migrate links every book that only has a plain author string to author
+// records. Spellings that differ only in case, spacing, punctuation, accents or
+// "Last, First" order become one author with the other spellings as aliases,
+// and the book shows the first spelling seen. Books already linked are left
+// alone, so the migration can be run again after importing old data, but
+// their author string is rewritten when it no longer matches their
+// contributors, as with names once shown comma separated.
+func Migrate(ctx context.Context) (*MigrationResult, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	// oldest first, the first spelling seen becomes the author's name
+	cursor, err := BookCollection.Find(ctx,
+		bson.M{"contributors": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"author": 1}).SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	result := &MigrationResult{}
+	for cursor.Next(ctx) {
+		var book struct {
+			ID     primitive.ObjectID `bson:"_id"`
+			Author string             `bson:"author"`
+		}
+		if err := cursor.Decode(&book); err != nil {
+			return result, fmt.Errorf("failed to decode book: %w", err)
+		}
+
+		contributors, display, created, err := resolveNames(ctx, Authored(SplitNames(book.Author)))
+		if err != nil {
+			return result, err
+		}
+		result.Authors += created
+		if len(contributors) == 0 {
+			result.Skipped++
+			continue
+		}
+
+		_, err = BookCollection.UpdateOne(ctx,
+			bson.M{"_id": book.ID, "contributors": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"contributors": contributors, "author": display}},
+		)
+		if err != nil {
+			return result, fmt.Errorf("failed to link book %s: %w", book.ID.Hex(), err)
+		}
+		result.Books++
+	}
+	if err := cursor.Err(); err != nil {
+		return result, fmt.Errorf("failed to read books: %w", err)
+	}
+
+	linked, err := BookCollection.Find(ctx,
+		bson.M{"contributors": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"author": 1, "contributors": 1}))
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	defer linked.Close(ctx)
+	for linked.Next(ctx) {
+		var book model.Book
+		if err := linked.Decode(&book); err != nil {
+			return result, fmt.Errorf("failed to decode book: %w", err)
+		}
+		display, err := Display(ctx, book.Contributors)
+		if err != nil {
+			return result, err
+		}
+		if display == book.Author {
+			continue
+		}
+		bookId, _ := primitive.ObjectIDFromHex(book.ID)
+		_, err = BookCollection.UpdateOne(ctx, bson.M{"_id": bookId}, bson.M{"$set": bson.M{"author": display}})
+		if err != nil {
+			return result, fmt.Errorf("failed to refresh book %s: %w", book.ID, err)
+		}
+		result.Refreshed++
+	}
+	if err := linked.Err(); err != nil {
+		return result, fmt.Errorf("failed to read books: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package authors
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameSeparator splits a plain author string naming several people,
+// "Terry Pratchett & Neil Gaiman" or "Pratchett, Terry; Gaiman, Neil"
+var nameSeparator = regexp.MustCompile(`(?i)\s*(?:;|&|\band\b)\s*`)
+
+// SplitNames returns the people named in a plain author string
+func SplitNames(author string) []string {
+	var names []string
+	for _, name := range nameSeparator.Split(author, -1) {
+		if name = strings.Join(strings.Fields(name), " "); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// JoinNames is the author string showing names, the reverse of SplitNames.
+// Names are kept apart with semicolons as a comma can be part of a name,
+// "Pratchett, Terry".
+func JoinNames(names []string) string {
+	return strings.Join(names, "; ")
+}
+
+// NameKey reduces a name to what its spellings have in common, so
+// "J.R.R. Tolkien", "J. R. R. Tolkien" and "Tolkien, J.R.R." all give
+// "jrrtolkien" and "García Márquez" matches "Garcia Marquez"
+func NameKey(name string) string {
+	// catalogue order, "Last, First"
+	if last, first, ok := strings.Cut(name, ","); ok && !strings.Contains(first, ",") {
+		name = first + " " + last
+	}
+
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn))), name)
+	if err != nil {
+		folded = name
+	}
+
+	var key strings.Builder
+	for _, r := range strings.ToLower(folded) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			key.WriteRune(r)
+		}
+	}
+	return key.String()
+}
+
+// nameKeys are the lookup keys of an author, one per distinct spelling
+func nameKeys(name string, aliases []string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, spelling := range append([]string{name}, aliases...) {
+		if key := NameKey(spelling); key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// cleanAliases trims aliases and drops blanks, repeats and the name itself
+func cleanAliases(name string, aliases []string) []string {
+	seen := map[string]bool{name: true}
+	cleaned := []string{}
+	for _, alias := range aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		if alias != "" && !seen[alias] {
+			seen[alias] = true
+			cleaned = append(cleaned, alias)
+		}
+	}
+	return cleaned
+}
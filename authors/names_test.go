@@ -0,0 +1,94 @@
+package authors
+
+import (
+	"reflect"
+	"testing"
+)
+
+// the author string shown for a book resolves back to the same people
+func TestJoinNamesRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{"Terry Pratchett"},
+		{"Terry Pratchett", "Neil Gaiman"},
+		{"Pratchett, Terry", "Gaiman, Neil"},
+		{"Gabriel García Márquez", "Tolkien, J.R.R.", "Ursula K. Le Guin"},
+	}
+	for _, names := range tests {
+		display := JoinNames(names)
+		got := SplitNames(display)
+		if !reflect.DeepEqual(got, names) {
+			t.Errorf("SplitNames(%q) = %q, want %q", display, got, names)
+			continue
+		}
+		for i := range names {
+			if NameKey(got[i]) != NameKey(names[i]) {
+				t.Errorf("NameKey(%q) = %q, want %q", got[i], NameKey(got[i]), NameKey(names[i]))
+			}
+		}
+	}
+}
+
+func TestSplitNames(t *testing.T) {
+	tests := []struct {
+		author string
+		want   []string
+	}{
+		{"Terry Pratchett", []string{"Terry Pratchett"}},
+		{"Terry Pratchett & Neil Gaiman", []string{"Terry Pratchett", "Neil Gaiman"}},
+		{"Terry Pratchett and Neil Gaiman", []string{"Terry Pratchett", "Neil Gaiman"}},
+		{"Terry Pratchett AND Neil Gaiman", []string{"Terry Pratchett", "Neil Gaiman"}},
+		{"Pratchett, Terry; Gaiman, Neil", []string{"Pratchett, Terry", "Gaiman, Neil"}},
+		{"  Terry   Pratchett ;; ", []string{"Terry Pratchett"}},
+		// "and" only separates as a word
+		{"Anderson Cooper", []string{"Anderson Cooper"}},
+		{"Alexander Grand", []string{"Alexander Grand"}},
+		{"", nil},
+		{" ; & ", nil},
+	}
+	for _, tt := range tests {
+		if got := SplitNames(tt.author); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitNames(%q) = %q, want %q", tt.author, got, tt.want)
+		}
+	}
+}
+
+func TestNameKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"J.R.R. Tolkien", "jrrtolkien"},
+		{"J. R. R. Tolkien", "jrrtolkien"},
+		{"Tolkien, J.R.R.", "jrrtolkien"},
+		{"García Márquez, Gabriel", "gabrielgarciamarquez"},
+		{"Gabriel Garcia Marquez", "gabrielgarciamarquez"},
+		{"Ursula K. Le Guin", "ursulakleguin"},
+		// two commas are not "Last, First"
+		{"Smith, John, Jr.", "smithjohnjr"},
+		{"Władysław Reymont", "władysławreymont"},
+		{"村上春樹", "村上春樹"},
+		{"...", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NameKey(tt.name); got != tt.want {
+			t.Errorf("NameKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNameKeys(t *testing.T) {
+	got := nameKeys("J.R.R. Tolkien", []string{"Tolkien, J. R. R.", "John Ronald Reuel Tolkien", "---"})
+	want := []string{"jrrtolkien", "johnronaldreueltolkien"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nameKeys = %q, want %q", got, want)
+	}
+}
+
+func TestCleanAliases(t *testing.T) {
+	got := cleanAliases("Terry Pratchett", []string{" T.  Pratchett ", "", "Terry Pratchett", "T. Pratchett", "Sir Terry"})
+	want := []string{"T. Pratchett", "Sir Terry"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cleanAliases = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,210 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// unsignedPayload lets uploads stream without hashing the body first
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateLayout   = "20060102T150405Z"
+)
+
+// S3Config configures an S3-compatible store such as AWS S3, MinIO or R2
+type S3Config struct {
+	// Endpoint defaults to AWS for the region, e.g. http://localhost:9000 for MinIO
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PublicURL is where clients fetch objects, a CDN or the bucket's public
+	// address. Defaults to the bucket on the endpoint.
+	PublicURL string
+	// PathStyle addresses the bucket as endpoint/bucket instead of
+	// bucket.endpoint, most self-hosted services need it
+	PathStyle bool
+}
+
+// S3 stores objects in a bucket through the S3 REST API, signing requests with
+// AWS Signature Version 4
+type S3 struct {
+	config  S3Config
+	bucket  *url.URL
+	client  *http.Client
+	baseURL string
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required")
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	bucket := *endpoint
+	if config.PathStyle {
+		bucket.Path += "/" + config.Bucket
+	} else {
+		bucket.Host = config.Bucket + "." + bucket.Host
+	}
+
+	baseURL := strings.TrimRight(config.PublicURL, "/")
+	if baseURL == "" {
+		baseURL = bucket.String()
+	}
+	return &S3{
+		config:  config,
+		bucket:  &bucket,
+		client:  &http.Client{Timeout: time.Minute},
+		baseURL: baseURL,
+	}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	return s.do(req, "upload")
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	// S3 answers 204 whether or not the object existed
+	return s.do(req, "delete")
+}
+
+func (s *S3) URL(key string) string {
+	return s.baseURL + "/" + escapePath(key)
+}
+
+func (s *S3) request(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	target := *s.bucket
+	target.Path += "/" + key
+	target.RawPath = s.bucket.EscapedPath() + "/" + escapePath(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build S3 request: %w", err)
+	}
+	return req, nil
+}
+
+func (s *S3) do(req *http.Request, action string) error {
+	s.sign(req, time.Now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("S3 %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && !(req.Method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 %s failed with %s: %s", action, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// sign adds a Signature Version 4 Authorization header. Only host and the
+// x-amz-* headers are signed, proxies may rewrite the others.
+func (s *S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format(amzDateLayout)
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", unsignedPayload)
+
+	canonical, signedHeaders := canonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, [][2]string{
+		{"host", req.URL.Host},
+		{"x-amz-content-sha256", unsignedPayload},
+		{"x-amz-date", amzDate},
+	}, unsignedPayload)
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	key := signingKey(s.config.SecretAccessKey, date, s.config.Region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign(amzDate, scope, canonical)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalRequest is the form of a request Signature Version 4 hashes, with
+// the list of signed headers. headers are name and value pairs, names in
+// lowercase and sorted.
+func canonicalRequest(method, path, query string, headers [][2]string, payloadHash string) (string, string) {
+	lines := []string{method, path, query}
+	names := make([]string, len(headers))
+	for i, header := range headers {
+		lines = append(lines, header[0]+":"+strings.TrimSpace(header[1]))
+		names[i] = header[0]
+	}
+	signedHeaders := strings.Join(names, ";")
+	lines = append(lines, "", signedHeaders, payloadHash)
+	return strings.Join(lines, "\n"), signedHeaders
+}
+
+func stringToSign(amzDate, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath percent-encodes every byte of a key except unreserved characters
+// and slashes, the encoding Signature Version 4 expects
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package books
+
+import (
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bmsgql/notifications"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// digestInterval is how long new arrivals are collected before a digest is sent
+const digestInterval = 24 * time.Hour
+
+// digestEntry is a document of the NewArrivalDigests collection, a new arrival
+// waiting to be included in a reader's next digest
+type digestEntry struct {
+	UserID    primitive.ObjectID `bson:"userId"`
+	BookID    primitive.ObjectID `bson:"bookId"`
+	Title     string             `bson:"title"`
+	Author    string             `bson:"author"`
+	Category  string             `bson:"category"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// announceNewArrival notifies readers whose favorite genres include the book's
+// category. Readers who prefer a digest get the book queued for their next one.
+func announceNewArrival(ctx context.Context, book *model.Book) error {
+	UserCollection := database.DB.Collection("Users")
+	DigestCollection := database.DB.Collection("NewArrivalDigests")
+
+	bookId, err := primitive.ObjectIDFromHex(book.ID)
+	if err != nil {
+		return fmt.Errorf("invalid book ID")
+	}
+
+	cursor, err := UserCollection.Find(ctx, bson.M{
+		"favoriteGenres":                   book.Category,
+		"notificationSettings.newArrivals": bson.M{"$ne": false},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch readers: %w", err)
+	}
+	var readers []struct {
+		ID       primitive.ObjectID          `bson:"_id"`
+		Settings *model.NotificationSettings `bson:"notificationSettings"`
+	}
+	if err := cursor.All(ctx, &readers); err != nil {
+		return fmt.Errorf("failed to decode readers: %w", err)
+	}
+
+	message := fmt.Sprintf("New arrival in %s: \"%s\" by %s", categoryName(book.Category), book.Title, book.Author)
+	now := time.Now()
+	for _, reader := range readers {
+		if reader.Settings != nil && reader.Settings.NewArrivalsDigest != nil && *reader.Settings.NewArrivalsDigest {
+			_, err := DigestCollection.InsertOne(ctx, digestEntry{
+				UserID:    reader.ID,
+				BookID:    bookId,
+				Title:     book.Title,
+				Author:    book.Author,
+				Category:  string(book.Category),
+				CreatedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to queue digest entry: %w", err)
+			}
+			continue
+		}
+		notifications.NotifyWithRetry(ctx, reader.ID, notifications.TypeNewArrival, message)
+	}
+	return nil
+}
+
+// SendNewArrivalDigests sends one notification per reader listing the new arrivals
+// queued for them, once the oldest of them has waited for a day
+func SendNewArrivalDigests(ctx context.Context) error {
+	DigestCollection := database.DB.Collection("NewArrivalDigests")
+
+	cursor, err := DigestCollection.Aggregate(ctx, bson.A{
+		bson.M{"$sort": bson.M{"createdAt": 1}},
+		bson.M{"$group": bson.M{
+			"_id":     "$userId",
+			"oldest":  bson.M{"$min": "$createdAt"},
+			"latest":  bson.M{"$max": "$createdAt"},
+			"entries": bson.M{"$push": "$$ROOT"},
+		}},
+		bson.M{"$match": bson.M{"oldest": bson.M{"$lte": time.Now().Add(-digestInterval)}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to group digest entries: %w", err)
+	}
+	var digests []struct {
+		UserID  primitive.ObjectID `bson:"_id"`
+		Latest  time.Time          `bson:"latest"`
+		Entries []digestEntry      `bson:"entries"`
+	}
+	if err := cursor.All(ctx, &digests); err != nil {
+		return fmt.Errorf("failed to decode digest entries: %w", err)
+	}
+
+	for _, digest := range digests {
+		titles := make([]string, len(digest.Entries))
+		for i, entry := range digest.Entries {
+			titles[i] = fmt.Sprintf("\"%s\" by %s", entry.Title, entry.Author)
+		}
+		message := fmt.Sprintf("%d new arrivals in your favorite genres: %s", len(titles), strings.Join(titles, ", "))
+
+		// remove what this digest covers before sending so a rerun cannot repeat it
+		_, err := DigestCollection.DeleteMany(ctx, bson.M{"userId": digest.UserID, "createdAt": bson.M{"$lte": digest.Latest}})
+		if err != nil {
+			return fmt.Errorf("failed to clear digest entries: %w", err)
+		}
+		notifications.NotifyWithRetry(ctx, digest.UserID, notifications.TypeNewArrival, message)
+	}
+	return nil
+}
+
+func categoryName(category model.BookCategory) string {
+	return strings.ReplaceAll(strings.ToLower(category.String()), "_", " ")
+}
+
+// announceInBackground runs announceNewArrival without holding up the mutation
+func announceInBackground(ctx context.Context, book *model.Book) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+		defer cancel()
+		if err := announceNewArrival(ctx, book); err != nil {
+			log.Printf("failed to announce new arrival %s: %v", book.ID, err)
+		}
+	}()
+}
@@ -1,14 +1,27 @@
 package books
 
 import (
+	"bmsgql/audit"
 	"bmsgql/auth"
+	"bmsgql/authors"
+	"bmsgql/collections"
+	"bmsgql/covers"
 	"bmsgql/database"
+	"bmsgql/errcode"
 	"bmsgql/graph/model"
+	"bmsgql/isbn"
+	"bmsgql/notifications"
+	"bmsgql/pubsub"
+	"bmsgql/tags"
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func AddBook(ctx context.Context, input model.AddBookInput) (*model.Book, error) {
@@ -29,35 +42,89 @@ func AddBook(ctx context.Context, input model.AddBookInput) (*model.Book, error)
 		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
 	}
 
-	newBook, err := BookCollection.InsertOne(ctx, bson.M{
-		"title":       input.Title,
-		"author":      input.Author,
-		"description": input.Description,
-		"category":    input.Category,
-		"isbn":        input.Isbn,
-		"coverImage":  input.CoverImage,
-	})
+	isbn, err := checkIsbn(ctx, input.Isbn, primitive.NilObjectID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add book: %w", err)
+		return nil, err
+	}
+	contributors, author, err := bookContributors(ctx, input.Author, input.Contributors)
+	if err != nil {
+		return nil, err
+	}
+	details, err := editionDetails(ctx, input.Format, input.Language, input.Publisher, input.PublicationYear, input.WorkID)
+	if err != nil {
+		return nil, err
+	}
+	classes, err := classification(ctx, input.TagIds, input.GenreIds)
+	if err != nil {
+		return nil, err
 	}
 
-	insertedId := newBook.InsertedID.(primitive.ObjectID)
-
+	insertedId := primitive.NewObjectID()
 	book := &model.Book{
-		ID:          insertedId.Hex(),
-		Title:       input.Title,
-		Author:      input.Author,
-		Description: input.Description,
-		Category:    input.Category,
-		Isbn:        input.Isbn,
-		CoverImage:  input.CoverImage,
+		ID:           insertedId.Hex(),
+		Title:        input.Title,
+		Author:       author,
+		Contributors: contributors,
+		Description:  input.Description,
+		Category:     input.Category,
+		Isbn:         isbn,
+		Availability: model.BookAvailabilityAvailable,
+		Version:      1,
+	}
+	if input.CoverImage != nil {
+		book.CoverImage = *input.CoverImage
+	}
+	if input.Cover != nil {
+		if input.CoverImage != nil {
+			return nil, errcode.Errorf(errcode.BadUserInput, "give either coverImage or cover, not both")
+		}
+		book.CoverKey, err = covers.Save(ctx, insertedId, *input.Cover)
+		if err != nil {
+			return nil, err
+		}
+		book.CoverImage = covers.URL(book, model.CoverSizeOriginal)
+	}
+
+	newBook := bson.M{
+		"_id":          insertedId,
+		"title":        book.Title,
+		"author":       book.Author,
+		"contributors": book.Contributors,
+		"description":  book.Description,
+		"category":     book.Category,
+		"isbn":         isbn,
+		"coverImage":   book.CoverImage,
+		"availability": book.Availability,
+		"version":      1,
+	}
+	if book.CoverKey != "" {
+		newBook["coverKey"] = book.CoverKey
+	}
+	details.apply(book, newBook)
+	for field, ids := range classes {
+		newBook[field] = ids
+	}
+	book.TagIDs, book.GenreIDs = classes["tagIds"], classes["genreIds"]
+	_, err = BookCollection.InsertOne(ctx, newBook)
+	if err != nil {
+		if book.CoverKey != "" {
+			covers.Delete(ctx, book.CoverKey)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateIsbn(isbn)
+		}
+		return nil, fmt.Errorf("failed to add book: %w", err)
 	}
 
+	audit.Track(ctx, "Book", book.ID, nil, book)
+	announceInBackground(ctx, book)
+
 	return book, nil
 }
 
-// EditBook is the resolver for the editBook field.
-func EditBook(ctx context.Context, id string, input model.EditBookInput) (*model.Book, error) {
+// EditBook is the resolver for the editBook field. The edit only applies if the
+// book is still at expectedVersion, otherwise it fails with a CONFLICT error.
+func EditBook(ctx context.Context, id string, input model.EditBookInput, expectedVersion int) (*model.Book, error) {
 	BookCollection := database.DB.Collection("Books")
 
 	userID, ok := auth.GetUserID(ctx)
@@ -85,8 +152,13 @@ func EditBook(ctx context.Context, id string, input model.EditBookInput) (*model
 	if input.Title != nil {
 		updateBook["title"] = input.Title
 	}
-	if input.Author != nil {
-		updateBook["author"] = input.Author
+	if input.Author != nil || input.Contributors != nil {
+		contributors, author, err := bookContributors(ctx, input.Author, input.Contributors)
+		if err != nil {
+			return nil, err
+		}
+		updateBook["author"] = author
+		updateBook["contributors"] = contributors
 	}
 	if input.Description != nil {
 		updateBook["description"] = input.Description
@@ -95,29 +167,101 @@ func EditBook(ctx context.Context, id string, input model.EditBookInput) (*model
 		updateBook["category"] = input.Category
 	}
 	if input.Isbn != nil {
-		updateBook["isbn"] = input.Isbn
+		isbn, err := checkIsbn(ctx, *input.Isbn, bookId)
+		if err != nil {
+			return nil, err
+		}
+		updateBook["isbn"] = isbn
+	}
+	if input.CoverImage != nil && input.Cover != nil {
+		return nil, errcode.Errorf(errcode.BadUserInput, "give either coverImage or cover, not both")
+	}
+	details, err := editionDetails(ctx, input.Format, input.Language, input.Publisher, input.PublicationYear, input.WorkID)
+	if err != nil {
+		return nil, err
+	}
+	for field, value := range details.set {
+		updateBook[field] = value
+	}
+	classes, err := classification(ctx, input.TagIds, input.GenreIds)
+	if err != nil {
+		return nil, err
+	}
+	for field, ids := range classes {
+		updateBook[field] = ids
+	}
+
+	var before model.Book
+	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId, "deletedAt": database.NotDeleted}).Decode(&before)
+	if err != nil {
+		return nil, fmt.Errorf("book not found")
 	}
+
+	if before.Version != expectedVersion {
+		return nil, versionConflict(before.Version)
+	}
+
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	unsetBook := details.unset
+	// an external coverImage replaces an uploaded cover
+	var coverKey string
 	if input.CoverImage != nil {
 		updateBook["coverImage"] = input.CoverImage
+		unsetBook["coverKey"] = ""
+	}
+	if input.Cover != nil {
+		coverKey, err = covers.Save(ctx, bookId, *input.Cover)
+		if err != nil {
+			return nil, err
+		}
+		updateBook["coverImage"] = covers.URL(&model.Book{CoverKey: coverKey}, model.CoverSizeOriginal)
+		updateBook["coverKey"] = coverKey
+	}
+	if len(updateBook) > 0 {
+		update["$set"] = updateBook
+	}
+	if len(unsetBook) > 0 {
+		update["$unset"] = unsetBook
 	}
 
 	var book model.Book
-	_, err = BookCollection.UpdateOne(ctx, bson.M{"_id": bookId}, bson.M{"$set": updateBook})
+	err = BookCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": bookId, "deletedAt": database.NotDeleted, "version": database.VersionFilter(expectedVersion)},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&book)
 	if err != nil {
+		if coverKey != "" && coverKey != before.CoverKey {
+			covers.Delete(ctx, coverKey)
+		}
+		if err == mongo.ErrNoDocuments {
+			// changed or deleted since it was read above
+			current, err := findBook(ctx, bookId)
+			if err != nil {
+				return nil, err
+			}
+			return nil, versionConflict(current.Version)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateIsbn(updateBook["isbn"].(string))
+		}
 		return nil, fmt.Errorf("failed to update book: %w", err)
 	}
 
-	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId}).Decode(&book)
-	if err != nil {
-		return nil, fmt.Errorf("book not found")
+	if before.CoverKey != "" && before.CoverKey != book.CoverKey {
+		covers.Delete(ctx, before.CoverKey)
 	}
 
+	audit.Track(ctx, "Book", id, &before, &book)
 	return &book, nil
 }
 
-// DeleteBook is the resolver for the deleteBook field.
+// DeleteBook is the resolver for the deleteBook field. Books are soft deleted so
+// reviews and loan history keep pointing at a document, see PurgeDeletedBooks.
 func DeleteBook(ctx context.Context, id string) (bool, error) {
 	BookCollection := database.DB.Collection("Books")
+	LoanCollection := database.DB.Collection("Loans")
+	ReservationCollection := database.DB.Collection("Reservations")
 
 	userID, ok := auth.GetUserID(ctx)
 	if !ok || userID == "" {
@@ -138,22 +282,280 @@ func DeleteBook(ctx context.Context, id string) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("invalid book ID")
 	}
-	_, err = BookCollection.DeleteOne(ctx, bson.M{"_id": bookId})
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID")
+	}
+
+	var before model.Book
+	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId, "deletedAt": database.NotDeleted}).Decode(&before)
+	if err != nil {
+		return false, fmt.Errorf("book not found")
+	}
+
+	outstanding, err := LoanCollection.CountDocuments(ctx, bson.M{"bookId": bookId, "status": bson.M{"$in": bson.A{LoanActive, LoanOverdue}}})
+	if err != nil {
+		return false, fmt.Errorf("failed to look up loans: %w", err)
+	}
+	if outstanding > 0 {
+		return false, fmt.Errorf("book cannot be deleted while it has %d outstanding loan(s)", outstanding)
+	}
+
+	// the availability guard catches a loan made since the count above
+	result, err := BookCollection.UpdateOne(ctx,
+		bson.M{"_id": bookId, "deletedAt": database.NotDeleted, "availability": bson.M{"$ne": model.BookAvailabilityBorrowed}},
+		bson.M{"$set": bson.M{"deletedAt": time.Now(), "deletedBy": userObjId}, "$inc": bson.M{"version": 1}},
+	)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete book: %w", err)
 	}
+	if result.MatchedCount == 0 {
+		return false, fmt.Errorf("book cannot be deleted while it has outstanding loans")
+	}
+
+	reservations, err := findReservations(ctx, bson.M{"bookId": bookId, "status": bson.M{"$in": bson.A{ReservationPending, ReservationReady}}})
+	if err != nil {
+		return false, err
+	}
+	_, err = ReservationCollection.UpdateMany(ctx,
+		bson.M{"bookId": bookId, "status": bson.M{"$in": bson.A{ReservationPending, ReservationReady}}},
+		bson.M{"$set": bson.M{"status": ReservationCancelled}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel reservations: %w", err)
+	}
+	for _, reservation := range reservations {
+		message := fmt.Sprintf("Your reservation for \"%s\" was cancelled because the book was withdrawn", before.Title)
+		notifications.NotifyWithRetry(ctx, reservation.UserID, notifications.TypeReservationExpired, message)
+	}
 
+	audit.Track(ctx, "Book", id, &before, nil)
 	return true, nil
 }
 
-// BorrowBook is the resolver for the borrowBook field.
-func BorrowBook(ctx context.Context, bookID string) (*model.BorrowReceipt, error) {
-	panic(fmt.Errorf("not implemented: BorrowBook - borrowBook"))
+// RestoreBook undoes DeleteBook. Reservations cancelled by the deletion stay cancelled.
+func RestoreBook(ctx context.Context, id string) (*model.Book, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	bookId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID")
+	}
+
+	result, err := BookCollection.UpdateOne(ctx,
+		bson.M{"_id": bookId, "deletedAt": bson.M{"$exists": true}},
+		bson.M{
+			"$set":   bson.M{"availability": model.BookAvailabilityAvailable},
+			"$unset": bson.M{"deletedAt": "", "deletedBy": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore book: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("deleted book not found")
+	}
+
+	book, err := findBook(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	audit.Track(ctx, "Book", id, nil, book)
+	PublishAvailability(book)
+	return book, nil
 }
 
-// ReserveBook is the resolver for the reserveBook field.
-func ReserveBook(ctx context.Context, bookID string) (*model.ReserveReceipt, error) {
-	panic(fmt.Errorf("not implemented: ReserveBook - reserveBook"))
+// PurgeDeletedBooks permanently removes books deleted before cutoff together with
+// their reviews, reservations, queued digest entries, view events, favorites
+// and uploaded covers. Collections and recently viewed lists drop them,
+// discussions lose their book link and loans and purchases are kept as history.
+func PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int64, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	cursor, err := BookCollection.Find(ctx, bson.M{"deletedAt": bson.M{"$lt": cutoff}}, options.Find().SetProjection(bson.M{"_id": 1, "coverKey": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch deleted books: %w", err)
+	}
+	var deleted []struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		CoverKey string             `bson:"coverKey"`
+	}
+	if err := cursor.All(ctx, &deleted); err != nil {
+		return 0, fmt.Errorf("failed to decode deleted books: %w", err)
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	bookIds := make(bson.A, len(deleted))
+	for i, book := range deleted {
+		bookIds[i] = book.ID
+	}
+	byBook := bson.M{"bookId": bson.M{"$in": bookIds}}
+
+	for _, name := range []string{"Reviews", "Reservations", "NewArrivalDigests", "BookViews", "Favorites"} {
+		if _, err := database.DB.Collection(name).DeleteMany(ctx, byBook); err != nil {
+			return 0, fmt.Errorf("failed to purge %s: %w", name, err)
+		}
+	}
+	_, err = database.DB.Collection("Discussions").UpdateMany(ctx, byBook, bson.M{"$unset": bson.M{"bookId": ""}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to unlink discussions: %w", err)
+	}
+	_, err = database.DB.Collection("Collections").UpdateMany(ctx,
+		bson.M{"bookIds": bson.M{"$in": bookIds}},
+		bson.M{"$pull": bson.M{"bookIds": bson.M{"$in": bookIds}}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to take books off collections: %w", err)
+	}
+	_, err = database.DB.Collection("RecentlyViewed").UpdateMany(ctx,
+		bson.M{"books.bookId": bson.M{"$in": bookIds}},
+		bson.M{"$pull": bson.M{"books": bson.M{"bookId": bson.M{"$in": bookIds}}}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to take books off recently viewed: %w", err)
+	}
+
+	// books are removed last so an interrupted purge is picked up by the next run
+	result, err := BookCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": bookIds}, "deletedAt": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge books: %w", err)
+	}
+	// a book restored while purging keeps its cover
+	restored, err := BookCollection.Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": bookIds}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to check purged books: %w", err)
+	}
+	for _, book := range deleted {
+		if book.CoverKey != "" && !containsId(restored, book.ID) {
+			covers.Delete(ctx, book.CoverKey)
+		}
+	}
+	return result.DeletedCount, nil
+}
+
+// bookContributors links a book to its authors, from explicit contributors or
+// by resolving a plain author string
+func bookContributors(ctx context.Context, author *string, contributors []*model.ContributorInput) ([]*model.BookContributor, string, error) {
+	if contributors != nil {
+		if author != nil {
+			return nil, "", errcode.Errorf(errcode.BadUserInput, "give either author or contributors, not both")
+		}
+		return authors.Contributors(ctx, contributors)
+	}
+	if author == nil {
+		return nil, "", errcode.Errorf(errcode.BadUserInput, "author or contributors is required")
+	}
+	return authors.Resolve(ctx, *author)
+}
+
+// classification checks the tags and genres given for a book. Only the lists
+// given are returned, keyed by the book field they are stored in.
+func classification(ctx context.Context, tagIds, genreIds []string) (map[string][]primitive.ObjectID, error) {
+	classes := map[string][]primitive.ObjectID{}
+	if tagIds != nil {
+		ids, err := tags.CheckTags(ctx, tagIds)
+		if err != nil {
+			return nil, err
+		}
+		classes["tagIds"] = ids
+	}
+	if genreIds != nil {
+		ids, err := tags.CheckGenres(ctx, genreIds)
+		if err != nil {
+			return nil, err
+		}
+		classes["genreIds"] = ids
+	}
+	return classes, nil
+}
+
+func containsId(ids []interface{}, id primitive.ObjectID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func versionConflict(current int) error {
+	return errcode.Errorf(errcode.Conflict, "book was modified by someone else and is now at version %d, reload it and try again", current)
+}
+
+// checkIsbn normalizes value to ISBN-13 and makes sure no other book, deleted or
+// not, already uses it
+func checkIsbn(ctx context.Context, value string, bookId primitive.ObjectID) (string, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	normalized, err := isbn.Normalize(value)
+	if err != nil {
+		return "", errcode.Errorf(errcode.BadUserInput, "invalid ISBN %q: expected a valid ISBN-10 or ISBN-13", value)
+	}
+
+	var existing struct {
+		DeletedAt *time.Time `bson:"deletedAt"`
+	}
+	err = BookCollection.FindOne(ctx, bson.M{"isbn": normalized, "_id": bson.M{"$ne": bookId}}).Decode(&existing)
+	if err == nil {
+		if existing.DeletedAt != nil {
+			return "", errcode.Errorf(errcode.Conflict, "a deleted book with ISBN %s exists, restore it instead", normalized)
+		}
+		return "", duplicateIsbn(normalized)
+	}
+	if err != mongo.ErrNoDocuments {
+		return "", fmt.Errorf("failed to look up ISBN: %w", err)
+	}
+	return normalized, nil
+}
+
+func duplicateIsbn(isbn string) error {
+	return errcode.Errorf(errcode.Conflict, "a book with ISBN %s already exists", isbn)
+}
+
+// BookByIsbn looks a book up by ISBN-10 or ISBN-13, with or without hyphens
+func BookByIsbn(ctx context.Context, value string) (*model.Book, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	normalized, err := isbn.Normalize(value)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.BadUserInput, "invalid ISBN %q", value)
+	}
+
+	var book model.Book
+	err = BookCollection.FindOne(ctx, bson.M{"isbn": normalized, "deletedAt": database.NotDeleted}).Decode(&book)
+	if err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+	return &book, nil
+}
+
+// EnsureIndexes creates the indexes the books package relies on besides the
+// ISBN one, see EnsureIsbnIndex. Each index is created even when another
+// fails.
+func EnsureIndexes(ctx context.Context) error {
+	return errors.Join(ensureViewIndexes(ctx), ensureLibraryIndexes(ctx))
 }
 
 func BookDetails(ctx context.Context, id string) (*model.Book, error) {
@@ -170,14 +572,16 @@ func BookDetails(ctx context.Context, id string) (*model.Book, error) {
 		return nil, fmt.Errorf("invalid book ID")
 	}
 	var book model.Book
-	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId}).Decode(&book)
+	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId, "deletedAt": database.NotDeleted}).Decode(&book)
 	if err != nil {
 		return nil, fmt.Errorf("book not found")
 	}
+	recordView(ctx, userID, &book)
 	return &book, nil
 }
 
-func FeaturedBooks(ctx context.Context) ([]*model.Book, error) {
+// AvailabilityChanged streams the book every time its availability changes until ctx is done
+func AvailabilityChanged(ctx context.Context, bookID string) (<-chan *model.Book, error) {
 	BookCollection := database.DB.Collection("Books")
 
 	userID, ok := auth.GetUserID(ctx)
@@ -186,26 +590,46 @@ func FeaturedBooks(ctx context.Context) ([]*model.Book, error) {
 		return nil, fmt.Errorf("user not authenticated")
 	}
 
-	var books []*model.Book
-	cursor, err := BookCollection.Find(ctx, bson.M{})
+	bookId, err := primitive.ObjectIDFromHex(bookID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch books: %w", err)
+		return nil, fmt.Errorf("invalid book ID")
 	}
-	defer cursor.Close(ctx)
-	for cursor.Next(ctx) {
-		var book model.Book
-		if err := cursor.Decode(&book); err != nil {
-			return nil, fmt.Errorf("failed to decode book: %w", err)
-		}
-		books = append(books, &book)
+	count, err := BookCollection.CountDocuments(ctx, bson.M{"_id": bookId, "deletedAt": database.NotDeleted})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up book: %w", err)
 	}
-	if err := cursor.Err(); err != nil {
-		return nil, fmt.Errorf("cursor error: %w", err)
+	if count == 0 {
+		return nil, fmt.Errorf("book not found")
 	}
-	return books, nil
+
+	return pubsub.Stream[*model.Book](ctx, pubsub.BookAvailabilityTopic(bookID)), nil
 }
 
-// RecentlyViewedBooks is the resolver for the recentlyViewedBooks field.
-func RecentlyViewedBooks(ctx context.Context) ([]*model.Book, error) {
-	panic(fmt.Errorf("not implemented: RecentlyViewedBooks - recentlyViewedBooks"))
+// PublishAvailability notifies subscribers that the availability of book changed
+func PublishAvailability(book *model.Book) {
+	pubsub.Publish(pubsub.BookAvailabilityTopic(book.ID), book)
+}
+
+// featuredFallbackSize is how many top rated titles are featured when no
+// collection is
+const featuredFallbackSize = 20
+
+// FeaturedBooks are the books of the active featured collection, or the best
+// rated titles while no collection is featured, see TopRated
+func FeaturedBooks(ctx context.Context) ([]*model.Book, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	featured, err := collections.Featured(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if featured != nil {
+		return featured, nil
+	}
+
+	return TopRated(ctx, bson.M{}, featuredFallbackSize)
 }
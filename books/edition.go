@@ -0,0 +1,92 @@
+package books
+
+import (
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/works"
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// languageTag accepts BCP 47 style tags such as "en", "pt-BR" or "zh-Hant"
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// edition holds the validated edition details of an add or edit
+type edition struct {
+	set   bson.M
+	unset bson.M
+}
+
+// editionDetails validates the edition fields given in an add or edit. An
+// empty workId takes the book out of its work.
+func editionDetails(ctx context.Context, format *model.EditionFormat, language, publisher *string, publicationYear *int, workId *string) (*edition, error) {
+	details := &edition{set: bson.M{}, unset: bson.M{}}
+	if format != nil {
+		if !format.IsValid() {
+			return nil, errcode.Errorf(errcode.BadUserInput, "invalid edition format %q", *format)
+		}
+		details.set["format"] = *format
+	}
+	if language != nil {
+		tag := strings.TrimSpace(*language)
+		if !languageTag.MatchString(tag) {
+			return nil, errcode.Errorf(errcode.BadUserInput, "invalid language %q: expected a code such as \"en\" or \"pt-BR\"", *language)
+		}
+		primary, region, _ := strings.Cut(tag, "-")
+		if region != "" {
+			tag = strings.ToLower(primary) + "-" + region
+		} else {
+			tag = strings.ToLower(primary)
+		}
+		details.set["language"] = tag
+	}
+	if publisher != nil {
+		details.set["publisher"] = strings.Join(strings.Fields(*publisher), " ")
+	}
+	if publicationYear != nil {
+		// announced editions may carry next year's date
+		if *publicationYear < 1 || *publicationYear > time.Now().Year()+1 {
+			return nil, errcode.Errorf(errcode.BadUserInput, "invalid publication year %d", *publicationYear)
+		}
+		details.set["publicationYear"] = *publicationYear
+	}
+	if workId != nil {
+		if *workId == "" {
+			details.unset["workId"] = ""
+		} else {
+			id, err := works.CheckWork(ctx, *workId)
+			if err != nil {
+				return nil, err
+			}
+			details.set["workId"] = id
+		}
+	}
+	return details, nil
+}
+
+// apply copies the edition details onto a new book and its document
+func (e *edition) apply(book *model.Book, doc bson.M) {
+	for field, value := range e.set {
+		doc[field] = value
+	}
+	if format, ok := e.set["format"].(model.EditionFormat); ok {
+		book.Format = &format
+	}
+	if language, ok := e.set["language"].(string); ok {
+		book.Language = &language
+	}
+	if publisher, ok := e.set["publisher"].(string); ok {
+		book.Publisher = &publisher
+	}
+	if year, ok := e.set["publicationYear"].(int); ok {
+		book.PublicationYear = &year
+	}
+	if workId, ok := e.set["workId"].(primitive.ObjectID); ok {
+		book.WorkID = &workId
+	}
+}
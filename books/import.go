@@ -0,0 +1,75 @@
+package books
+
+import (
+	"bmsgql/auth"
+	"bmsgql/authors"
+	"bmsgql/catalogue"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var metadataProvider = sync.OnceValues(catalogue.ProviderFromEnv)
+
+// ImportBookByIsbn fetches the catalogue record of an ISBN and fills in the
+// fields of AddBookInput. Nothing is saved, the admin reviews the result and
+// submits it with addBook.
+func ImportBookByIsbn(ctx context.Context, value string) (*model.BookMetadata, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	isbn, err := checkIsbn(ctx, value, primitive.NilObjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := metadataProvider()
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := provider.Lookup(ctx, isbn)
+	if err != nil {
+		if errors.Is(err, catalogue.ErrNotFound) {
+			return nil, errcode.Errorf(errcode.NotFound, "no catalogue record found for ISBN %s", isbn)
+		}
+		return nil, fmt.Errorf("failed to look up ISBN %s: %w", isbn, err)
+	}
+
+	return bookMetadata(isbn, metadata, provider.Name()), nil
+}
+
+// bookMetadata fills in the fields of AddBookInput from a catalogue record.
+// The author string is the one addBook splits back into authors.
+func bookMetadata(isbn string, metadata *catalogue.Metadata, source string) *model.BookMetadata {
+	subjects := metadata.Subjects
+	if subjects == nil {
+		subjects = []string{}
+	}
+	return &model.BookMetadata{
+		Isbn:        isbn,
+		Title:       metadata.Title,
+		Author:      authors.JoinNames(metadata.Authors),
+		Description: metadata.Description,
+		Category:    catalogue.Category(subjects),
+		CoverImage:  metadata.CoverURL,
+		Subjects:    subjects,
+		Source:      source,
+	}
+}
@@ -0,0 +1,29 @@
+package books
+
+import (
+	"bmsgql/authors"
+	"bmsgql/catalogue"
+	"reflect"
+	"testing"
+)
+
+// a record imported by ISBN and submitted with addBook keeps its authors apart
+func TestBookMetadataAuthors(t *testing.T) {
+	tests := []struct {
+		authors []string
+		want    string
+	}{
+		{[]string{"Terry Pratchett"}, "Terry Pratchett"},
+		{[]string{"Terry Pratchett", "Neil Gaiman"}, "Terry Pratchett; Neil Gaiman"},
+		{[]string{"Pratchett, Terry", "Gaiman, Neil"}, "Pratchett, Terry; Gaiman, Neil"},
+	}
+	for _, tt := range tests {
+		metadata := bookMetadata("9780552137034", &catalogue.Metadata{Title: "Good Omens", Authors: tt.authors}, "fixtures")
+		if metadata.Author != tt.want {
+			t.Errorf("Author = %q, want %q", metadata.Author, tt.want)
+		}
+		if got := authors.SplitNames(metadata.Author); !reflect.DeepEqual(got, tt.authors) {
+			t.Errorf("SplitNames(%q) = %q, want %q", metadata.Author, got, tt.authors)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package books
+
+import (
+	"bmsgql/database"
+	"bmsgql/isbn"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// isbnIndex is the unique index on ISBN. Books without an ISBN are left out
+// of it, legacy records can have an empty one.
+const isbnIndex = "isbn_unique_nonempty"
+
+// IsbnBook is a book named in the ISBN migration report
+type IsbnBook struct {
+	ID      string
+	Title   string
+	Isbn    string
+	Deleted bool
+}
+
+// IsbnMigrationResult reports what NormalizeIsbns changed and what it could
+// not. Duplicates are keyed by the ISBN-13 the books share.
+type IsbnMigrationResult struct {
+	Normalized int
+	Invalid    []IsbnBook
+	Duplicates map[string][]IsbnBook
+}
+
+// NormalizeIsbns rewrites the ISBN of every book to ISBN-13 without
+// separators, the form new books are stored with. Books sharing an ISBN once
+// normalized are reported and left as they are, someone has to decide which
+// record to keep before the unique index can be built. Invalid ISBNs are
+// reported too.
+func NormalizeIsbns(ctx context.Context) (*IsbnMigrationResult, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	cursor, err := BookCollection.Find(ctx,
+		bson.M{"isbn": bson.M{"$gt": ""}},
+		options.Find().SetProjection(bson.M{"isbn": 1, "title": 1, "deletedAt": 1}).SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	var stored []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		Title     string             `bson:"title"`
+		Isbn      string             `bson:"isbn"`
+		DeletedAt *time.Time         `bson:"deletedAt"`
+	}
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+
+	result := &IsbnMigrationResult{Duplicates: map[string][]IsbnBook{}}
+	byIsbn := map[string][]int{}
+	normalized := make([]string, len(stored))
+	for i, book := range stored {
+		value, err := isbn.Normalize(book.Isbn)
+		if err != nil {
+			result.Invalid = append(result.Invalid, IsbnBook{ID: book.ID.Hex(), Title: book.Title, Isbn: book.Isbn, Deleted: book.DeletedAt != nil})
+			continue
+		}
+		normalized[i] = value
+		byIsbn[value] = append(byIsbn[value], i)
+	}
+
+	for value, books := range byIsbn {
+		if len(books) > 1 {
+			for _, i := range books {
+				book := stored[i]
+				result.Duplicates[value] = append(result.Duplicates[value], IsbnBook{ID: book.ID.Hex(), Title: book.Title, Isbn: book.Isbn, Deleted: book.DeletedAt != nil})
+			}
+			continue
+		}
+		book := stored[books[0]]
+		if book.Isbn == value {
+			continue
+		}
+		_, err := BookCollection.UpdateOne(ctx, bson.M{"_id": book.ID, "isbn": book.Isbn}, bson.M{"$set": bson.M{"isbn": value}})
+		if err != nil {
+			return result, fmt.Errorf("failed to normalize ISBN of book %s: %w", book.ID.Hex(), err)
+		}
+		result.Normalized++
+	}
+	for _, books := range result.Duplicates {
+		sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+	}
+	return result, nil
+}
+
+// EnsureIsbnIndex makes ISBNs unique across all books, deleted ones too, so
+// restoring a book can never clash. It fails while books share an ISBN, run
+// NormalizeIsbns to find them. The unique index on every ISBN, empty ones
+// included, that preceded it is dropped.
+func EnsureIsbnIndex(ctx context.Context) error {
+	Indexes := database.DB.Collection("Books").Indexes()
+
+	// older MongoDB versions refuse a second index on the same key, the old
+	// one can only have been built if ISBNs were already unique
+	_, err := Indexes.DropOne(ctx, "isbn_unique")
+	var commandErr mongo.CommandError
+	if err != nil && !(errors.As(err, &commandErr) && (commandErr.Name == "IndexNotFound" || commandErr.Name == "NamespaceNotFound")) {
+		return fmt.Errorf("failed to drop the previous ISBN index: %w", err)
+	}
+	_, err = Indexes.CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "isbn", Value: 1}},
+		Options: options.Index().SetName(isbnIndex).SetUnique(true).
+			SetPartialFilterExpression(bson.M{"isbn": bson.M{"$gt": ""}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create ISBN index, run bms normalize-isbns to find duplicates: %w", err)
+	}
+	return nil
+}
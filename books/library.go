@@ -0,0 +1,274 @@
+package books
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/paging"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Purchase is a document of the Purchases collection. Nothing records
+// purchases until purchaseBook is implemented, so purchased books stay empty.
+type Purchase struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	UserID      primitive.ObjectID `bson:"userId"`
+	BookID      primitive.ObjectID `bson:"bookId"`
+	PurchasedAt time.Time          `bson:"purchasedAt"`
+}
+
+// Favorite is a document of the Favorites collection
+type Favorite struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	UserID  primitive.ObjectID `bson:"userId"`
+	BookID  primitive.ObjectID `bson:"bookId"`
+	AddedAt time.Time          `bson:"addedAt"`
+}
+
+// MyLibrary gathers the books the reader has on loan, on hold, bought and
+// marked as favorite, most recent first. Bought books are always empty for
+// now, see Purchase.
+func MyLibrary(ctx context.Context) (*model.Library, error) {
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byUser := bson.M{"userId": userObjId}
+	newest := func(field string) *options.FindOptions {
+		return options.Find().SetSort(bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}})
+	}
+
+	var loans []Loan
+	if err := findAll(ctx, "Loans", bson.M{"userId": userObjId, "status": bson.M{"$in": bson.A{LoanActive, LoanOverdue}}}, newest("borrowedAt"), &loans); err != nil {
+		return nil, err
+	}
+	borrowedIds := make([]primitive.ObjectID, len(loans))
+	for i, loan := range loans {
+		borrowedIds[i] = loan.BookID
+	}
+
+	var reservations []Reservation
+	if err := findAll(ctx, "Reservations", bson.M{"userId": userObjId, "status": bson.M{"$in": bson.A{ReservationPending, ReservationReady}}}, newest("reservedAt"), &reservations); err != nil {
+		return nil, err
+	}
+	reservedIds := []primitive.ObjectID{}
+	for _, reservation := range reservations {
+		bookId := reservation.BookID
+		// a hold on a work shows the edition it was placed through
+		if bookId.IsZero() && reservation.WorkID != nil {
+			if bookId, err = firstEdition(ctx, *reservation.WorkID); err != nil {
+				return nil, err
+			}
+		}
+		if !bookId.IsZero() {
+			reservedIds = append(reservedIds, bookId)
+		}
+	}
+
+	var purchases []Purchase
+	if err := findAll(ctx, "Purchases", byUser, newest("purchasedAt"), &purchases); err != nil {
+		return nil, err
+	}
+	purchasedIds := make([]primitive.ObjectID, len(purchases))
+	for i, purchase := range purchases {
+		purchasedIds[i] = purchase.BookID
+	}
+
+	var favorites []Favorite
+	if err := findAll(ctx, "Favorites", byUser, newest("addedAt"), &favorites); err != nil {
+		return nil, err
+	}
+	favoriteIds := make([]primitive.ObjectID, len(favorites))
+	for i, favorite := range favorites {
+		favoriteIds[i] = favorite.BookID
+	}
+
+	library := &model.Library{}
+	for _, list := range []struct {
+		ids   []primitive.ObjectID
+		books *[]*model.Book
+	}{
+		{borrowedIds, &library.BorrowedBooks},
+		{reservedIds, &library.ReservedBooks},
+		{purchasedIds, &library.PurchasedBooks},
+		{favoriteIds, &library.FavoriteBooks},
+	} {
+		if *list.books, err = database.OrderedBooks(ctx, list.ids, nil); err != nil {
+			return nil, err
+		}
+	}
+	return library, nil
+}
+
+// BookHistory lists the reader's returned loans, most recently borrowed first
+func BookHistory(ctx context.Context, year *int, limit, offset *int) ([]*model.BookHistory, error) {
+	LoanCollection := database.DB.Collection("Loans")
+	BookCollection := database.DB.Collection("Books")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"userId": userObjId, "status": LoanReturned}
+	if year != nil {
+		if *year < 1 || *year > 9999 {
+			return nil, errcode.Errorf(errcode.BadUserInput, "invalid year %d", *year)
+		}
+		start := time.Date(*year, time.January, 1, 0, 0, 0, 0, time.Local)
+		filter["borrowedAt"] = bson.M{"$gte": start, "$lt": start.AddDate(1, 0, 0)}
+	}
+	cursor, err := LoanCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "borrowedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(paging.Offset(offset))).
+		SetLimit(int64(paging.Limit(limit))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch loans: %w", err)
+	}
+	var loans []Loan
+	if err := cursor.All(ctx, &loans); err != nil {
+		return nil, fmt.Errorf("failed to decode loans: %w", err)
+	}
+	if len(loans) == 0 {
+		return []*model.BookHistory{}, nil
+	}
+
+	// deleted books are still part of the reader's history
+	bookIds := make([]primitive.ObjectID, len(loans))
+	for i, loan := range loans {
+		bookIds[i] = loan.BookID
+	}
+	cursor, err = BookCollection.Find(ctx, bson.M{"_id": bson.M{"$in": bookIds}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	var found []*model.Book
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+	byId := make(map[string]*model.Book, len(found))
+	for _, book := range found {
+		byId[book.ID] = book
+	}
+
+	history := make([]*model.BookHistory, len(loans))
+	for i, loan := range loans {
+		borrowed := loan.BorrowedAt.Format(time.RFC3339)
+		entry := &model.BookHistory{Book: byId[loan.BookID.Hex()], BorrowedDate: &borrowed}
+		if loan.ReturnedAt != nil {
+			returned := loan.ReturnedAt.Format(time.RFC3339)
+			entry.ReturnedDate = &returned
+		}
+		history[i] = entry
+	}
+	return history, nil
+}
+
+// AddFavorite marks a book as one of the reader's favorites. Adding it again
+// keeps the date it was first added.
+func AddFavorite(ctx context.Context, bookID string) (*model.Book, error) {
+	FavoriteCollection := database.DB.Collection("Favorites")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bookId, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID")
+	}
+	book, err := findBook(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = FavoriteCollection.UpdateOne(ctx,
+		bson.M{"userId": userObjId, "bookId": bookId},
+		bson.M{"$setOnInsert": bson.M{"addedAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	// two concurrent adds race on the unique index, the loser is a repeat
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("failed to add favorite: %w", err)
+	}
+	return book, nil
+}
+
+func RemoveFavorite(ctx context.Context, bookID string) (bool, error) {
+	FavoriteCollection := database.DB.Collection("Favorites")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	bookId, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return false, fmt.Errorf("invalid book ID")
+	}
+
+	result, err := FavoriteCollection.DeleteOne(ctx, bson.M{"userId": userObjId, "bookId": bookId})
+	if err != nil {
+		return false, fmt.Errorf("failed to remove favorite: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return false, errcode.Errorf(errcode.NotFound, "book is not one of your favorites")
+	}
+	return true, nil
+}
+
+// ensureLibraryIndexes keeps a book in a reader's favorites once and indexes
+// purchases by reader
+func ensureLibraryIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("Favorites").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "bookId", Value: 1}},
+		Options: options.Index().SetName("user_book_unique").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create favorite index: %w", err)
+	}
+	_, err = database.DB.Collection("Purchases").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "purchasedAt", Value: -1}},
+		Options: options.Index().SetName("user_purchases"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create purchase index: %w", err)
+	}
+	return nil
+}
+
+// firstEdition is the edition a work hold is shown as, the same one
+// ReserveWork puts on its receipt
+func firstEdition(ctx context.Context, workId primitive.ObjectID) (primitive.ObjectID, error) {
+	var edition struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := database.DB.Collection("Books").FindOne(ctx,
+		bson.M{"workId": workId, "deletedAt": database.NotDeleted},
+		options.FindOne().
+			SetSort(bson.D{{Key: "publicationYear", Value: 1}, {Key: "_id", Value: 1}}).
+			SetProjection(bson.M{"_id": 1}),
+	).Decode(&edition)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return primitive.NilObjectID, fmt.Errorf("failed to look up editions: %w", err)
+	}
+	return edition.ID, nil
+}
+
+func findAll(ctx context.Context, collection string, filter bson.M, opts *options.FindOptions, results interface{}) error {
+	cursor, err := database.DB.Collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", collection, err)
+	}
+	if err := cursor.All(ctx, results); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", collection, err)
+	}
+	return nil
+}
@@ -0,0 +1,625 @@
+package books
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bmsgql/notifications"
+	"bmsgql/works"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Loan statuses
+const (
+	LoanActive   = "ACTIVE"
+	LoanOverdue  = "OVERDUE"
+	LoanReturned = "RETURNED"
+)
+
+// Reservation statuses. A PENDING reservation waits for the book to be returned,
+// a READY one holds the book for the reader until ExpiresAt.
+const (
+	ReservationPending   = "PENDING"
+	ReservationReady     = "READY"
+	ReservationCollected = "COLLECTED"
+	ReservationExpired   = "EXPIRED"
+	ReservationCancelled = "CANCELLED"
+)
+
+// Loan is a document of the Loans collection
+type Loan struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	UserID         primitive.ObjectID `bson:"userId"`
+	BookID         primitive.ObjectID `bson:"bookId"`
+	BorrowedAt     time.Time          `bson:"borrowedAt"`
+	DueDate        time.Time          `bson:"dueDate"`
+	ReturnedAt     *time.Time         `bson:"returnedAt,omitempty"`
+	Status         string             `bson:"status"`
+	ReminderSentAt *time.Time         `bson:"reminderSentAt,omitempty"`
+}
+
+// Reservation is a document of the Reservations collection. A hold placed on
+// a work has no BookID until one of its editions is held for the reader.
+type Reservation struct {
+	ID         primitive.ObjectID  `bson:"_id"`
+	UserID     primitive.ObjectID  `bson:"userId"`
+	BookID     primitive.ObjectID  `bson:"bookId,omitempty"`
+	WorkID     *primitive.ObjectID `bson:"workId,omitempty"`
+	ReservedAt time.Time           `bson:"reservedAt"`
+	ExpiresAt  *time.Time          `bson:"expiresAt,omitempty"`
+	Status     string              `bson:"status"`
+}
+
+// availableFilter matches books that can be handed out, books created before
+// availability was tracked have no availability field at all
+var availableFilter = bson.M{"$in": bson.A{model.BookAvailabilityAvailable, nil}}
+
+// BorrowBook lends the book to the current user, either because it is on the
+// shelf or because the user collects a copy held for their reservation
+func BorrowBook(ctx context.Context, bookID string) (*model.BorrowReceipt, error) {
+	BookCollection := database.DB.Collection("Books")
+	LoanCollection := database.DB.Collection("Loans")
+	ReservationCollection := database.DB.Collection("Reservations")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bookId, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID")
+	}
+
+	now := time.Now()
+	var collected Reservation
+	err = ReservationCollection.FindOneAndUpdate(ctx,
+		bson.M{"bookId": bookId, "userId": userObjId, "status": ReservationReady},
+		bson.M{"$set": bson.M{"status": ReservationCollected, "collectedAt": now}},
+	).Decode(&collected)
+	// previous is the availability to put back if the loan cannot be saved
+	previous := model.BookAvailabilityAvailable
+	switch err {
+	case nil:
+		_, err = BookCollection.UpdateOne(ctx, bson.M{"_id": bookId, "deletedAt": database.NotDeleted}, bson.M{"$set": bson.M{"availability": model.BookAvailabilityBorrowed}})
+		if err != nil {
+			uncollect(ctx, collected.ID)
+			return nil, fmt.Errorf("failed to update book: %w", err)
+		}
+		previous = model.BookAvailabilityReserved
+	case mongo.ErrNoDocuments:
+		result, err := BookCollection.UpdateOne(ctx,
+			bson.M{"_id": bookId, "deletedAt": database.NotDeleted, "availability": availableFilter},
+			bson.M{"$set": bson.M{"availability": model.BookAvailabilityBorrowed}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update book: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			if _, err := findBook(ctx, bookId); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("book is not available for borrowing")
+		}
+	default:
+		return nil, fmt.Errorf("failed to look up reservation: %w", err)
+	}
+
+	dueDate := now.AddDate(0, 0, envDays("LOAN_PERIOD_DAYS", 14))
+	loan := bson.M{
+		"userId":     userObjId,
+		"bookId":     bookId,
+		"borrowedAt": now,
+		"dueDate":    dueDate,
+		"status":     LoanActive,
+	}
+	newLoan, err := LoanCollection.InsertOne(ctx, loan)
+	if err != nil {
+		restoreBook(ctx, bookId, model.BookAvailabilityBorrowed, previous)
+		if previous == model.BookAvailabilityReserved {
+			uncollect(ctx, collected.ID)
+		}
+		return nil, fmt.Errorf("failed to create loan: %w", err)
+	}
+	audit.Track(ctx, "Loan", newLoan.InsertedID.(primitive.ObjectID).Hex(), nil, loan)
+
+	book, err := findBook(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	PublishAvailability(book)
+
+	return &model.BorrowReceipt{
+		Book:    book,
+		DueDate: dueDate.Format(time.RFC3339),
+	}, nil
+}
+
+// ReserveBook holds the book for the current user if it is on the shelf, or
+// queues the user for the next copy returned otherwise
+func ReserveBook(ctx context.Context, bookID string) (*model.ReserveReceipt, error) {
+	BookCollection := database.DB.Collection("Books")
+	LoanCollection := database.DB.Collection("Loans")
+	ReservationCollection := database.DB.Collection("Reservations")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bookId, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID")
+	}
+
+	book, err := findBook(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	if book.Availability == model.BookAvailabilitySoldOut {
+		return nil, fmt.Errorf("book is sold out")
+	}
+
+	reserved := bson.A{bson.M{"bookId": bookId}}
+	if book.WorkID != nil {
+		// a hold on the work already covers this edition
+		reserved = append(reserved, bson.M{"workId": *book.WorkID, "bookId": bson.M{"$exists": false}})
+	}
+	count, err := ReservationCollection.CountDocuments(ctx, bson.M{
+		"$or":    reserved,
+		"userId": userObjId,
+		"status": bson.M{"$in": bson.A{ReservationPending, ReservationReady}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up reservations: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("you already have a reservation for this book")
+	}
+
+	count, err = LoanCollection.CountDocuments(ctx, bson.M{
+		"bookId": bookId,
+		"userId": userObjId,
+		"status": bson.M{"$in": bson.A{LoanActive, LoanOverdue}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up loans: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("you already have this book on loan")
+	}
+
+	now := time.Now()
+	reservation := bson.M{
+		"userId":     userObjId,
+		"bookId":     bookId,
+		"reservedAt": now,
+		"status":     ReservationPending,
+	}
+
+	result, err := BookCollection.UpdateOne(ctx,
+		bson.M{"_id": bookId, "deletedAt": database.NotDeleted, "availability": availableFilter},
+		bson.M{"$set": bson.M{"availability": model.BookAvailabilityReserved}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update book: %w", err)
+	}
+	held := result.ModifiedCount > 0
+	if held {
+		reservation["status"] = ReservationReady
+		reservation["expiresAt"] = now.AddDate(0, 0, envDays("RESERVATION_HOLD_DAYS", 3))
+	}
+
+	newReservation, err := ReservationCollection.InsertOne(ctx, reservation)
+	if err != nil {
+		if held {
+			restoreBook(ctx, bookId, model.BookAvailabilityReserved, model.BookAvailabilityAvailable)
+		}
+		return nil, fmt.Errorf("failed to create reservation: %w", err)
+	}
+	audit.Track(ctx, "Reservation", newReservation.InsertedID.(primitive.ObjectID).Hex(), nil, reservation)
+
+	if held {
+		book.Availability = model.BookAvailabilityReserved
+		PublishAvailability(book)
+	}
+
+	return &model.ReserveReceipt{
+		Book:            book,
+		ReservationDate: now.Format(time.RFC3339),
+	}, nil
+}
+
+// ReserveWork holds whichever edition of a work the current user can get first.
+// An edition on the shelf is held straight away, otherwise the user queues for
+// the first edition returned. The receipt names the held edition, or the
+// work's first edition while the hold is pending.
+func ReserveWork(ctx context.Context, workID string) (*model.ReserveReceipt, error) {
+	BookCollection := database.DB.Collection("Books")
+	LoanCollection := database.DB.Collection("Loans")
+	ReservationCollection := database.DB.Collection("Reservations")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workId, err := works.CheckWork(ctx, workID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := BookCollection.Find(ctx,
+		bson.M{"workId": workId, "deletedAt": database.NotDeleted},
+		options.Find().SetSort(bson.D{{Key: "publicationYear", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch editions: %w", err)
+	}
+	var editions []*model.Book
+	if err := cursor.All(ctx, &editions); err != nil {
+		return nil, fmt.Errorf("failed to decode editions: %w", err)
+	}
+	editionIds := bson.A{}
+	soldOut := true
+	for _, edition := range editions {
+		bookId, _ := primitive.ObjectIDFromHex(edition.ID)
+		editionIds = append(editionIds, bookId)
+		if edition.Availability != model.BookAvailabilitySoldOut {
+			soldOut = false
+		}
+	}
+	if len(editions) == 0 {
+		return nil, fmt.Errorf("work has no editions to reserve")
+	}
+	if soldOut {
+		return nil, fmt.Errorf("all editions of this work are sold out")
+	}
+
+	count, err := ReservationCollection.CountDocuments(ctx, bson.M{
+		"$or":    bson.A{bson.M{"bookId": bson.M{"$in": editionIds}}, bson.M{"workId": workId}},
+		"userId": userObjId,
+		"status": bson.M{"$in": bson.A{ReservationPending, ReservationReady}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up reservations: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("you already have a reservation for this work")
+	}
+
+	count, err = LoanCollection.CountDocuments(ctx, bson.M{
+		"bookId": bson.M{"$in": editionIds},
+		"userId": userObjId,
+		"status": bson.M{"$in": bson.A{LoanActive, LoanOverdue}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up loans: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("you already have an edition of this work on loan")
+	}
+
+	now := time.Now()
+	reservation := bson.M{
+		"userId":     userObjId,
+		"workId":     workId,
+		"reservedAt": now,
+		"status":     ReservationPending,
+	}
+
+	var held model.Book
+	err = BookCollection.FindOneAndUpdate(ctx,
+		bson.M{"workId": workId, "deletedAt": database.NotDeleted, "availability": availableFilter},
+		bson.M{"$set": bson.M{"availability": model.BookAvailabilityReserved}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "publicationYear", Value: 1}, {Key: "_id", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&held)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to update book: %w", err)
+	}
+	book := editions[0]
+	isHeld := err == nil
+	if isHeld {
+		book = &held
+		bookId, _ := primitive.ObjectIDFromHex(held.ID)
+		reservation["bookId"] = bookId
+		reservation["status"] = ReservationReady
+		reservation["expiresAt"] = now.AddDate(0, 0, envDays("RESERVATION_HOLD_DAYS", 3))
+	}
+
+	newReservation, err := ReservationCollection.InsertOne(ctx, reservation)
+	if err != nil {
+		if isHeld {
+			restoreBook(ctx, reservation["bookId"].(primitive.ObjectID), model.BookAvailabilityReserved, model.BookAvailabilityAvailable)
+		}
+		return nil, fmt.Errorf("failed to create reservation: %w", err)
+	}
+	audit.Track(ctx, "Reservation", newReservation.InsertedID.(primitive.ObjectID).Hex(), nil, reservation)
+
+	if isHeld {
+		PublishAvailability(book)
+	}
+
+	return &model.ReserveReceipt{
+		Book:            book,
+		ReservationDate: now.Format(time.RFC3339),
+	}, nil
+}
+
+// ReturnBook closes the current user's loan of the book and hands it to the next reservation
+func ReturnBook(ctx context.Context, bookID string) (*model.Book, error) {
+	LoanCollection := database.DB.Collection("Loans")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bookId, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID")
+	}
+
+	var loan Loan
+	returnedAt := time.Now()
+	err = LoanCollection.FindOneAndUpdate(ctx,
+		bson.M{"bookId": bookId, "userId": userObjId, "status": bson.M{"$in": bson.A{LoanActive, LoanOverdue}}},
+		bson.M{"$set": bson.M{"status": LoanReturned, "returnedAt": returnedAt}},
+	).Decode(&loan)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("no active loan for this book")
+		}
+		return nil, fmt.Errorf("failed to update loan: %w", err)
+	}
+	returned := loan
+	returned.Status = LoanReturned
+	returned.ReturnedAt = &returnedAt
+	audit.Track(ctx, "Loan", loan.ID.Hex(), loan, returned)
+
+	if err := releaseBook(ctx, bookId); err != nil {
+		return nil, err
+	}
+	return findBook(ctx, bookId)
+}
+
+// SendDueDateReminders notifies readers whose loans are due within
+// REMINDER_DAYS_BEFORE_DUE days, each loan is reminded once
+func SendDueDateReminders(ctx context.Context) error {
+	LoanCollection := database.DB.Collection("Loans")
+
+	now := time.Now()
+	horizon := now.AddDate(0, 0, envDays("REMINDER_DAYS_BEFORE_DUE", 2))
+	loans, err := findLoans(ctx, bson.M{
+		"status":         LoanActive,
+		"dueDate":        bson.M{"$gt": now, "$lte": horizon},
+		"reminderSentAt": nil,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, loan := range loans {
+		// claim the reminder first so an overlapping run cannot send it twice
+		result, err := LoanCollection.UpdateOne(ctx,
+			bson.M{"_id": loan.ID, "reminderSentAt": nil},
+			bson.M{"$set": bson.M{"reminderSentAt": now}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		message := fmt.Sprintf("\"%s\" is due on %s", bookTitle(ctx, loan.BookID), loan.DueDate.Format("Jan 2, 2006"))
+		notifications.NotifyWithRetry(ctx, loan.UserID, notifications.TypeDueDateReminder, message)
+	}
+	return nil
+}
+
+// MarkOverdueLoans flags active loans past their due date and notifies the readers
+func MarkOverdueLoans(ctx context.Context) error {
+	LoanCollection := database.DB.Collection("Loans")
+
+	loans, err := findLoans(ctx, bson.M{"status": LoanActive, "dueDate": bson.M{"$lt": time.Now()}})
+	if err != nil {
+		return err
+	}
+
+	for _, loan := range loans {
+		result, err := LoanCollection.UpdateOne(ctx,
+			bson.M{"_id": loan.ID, "status": LoanActive},
+			bson.M{"$set": bson.M{"status": LoanOverdue}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		message := fmt.Sprintf("\"%s\" was due on %s and is now overdue", bookTitle(ctx, loan.BookID), loan.DueDate.Format("Jan 2, 2006"))
+		notifications.NotifyWithRetry(ctx, loan.UserID, notifications.TypeLoanOverdue, message)
+	}
+	return nil
+}
+
+// ExpireReservations releases books held for readers who did not collect them in time
+func ExpireReservations(ctx context.Context) error {
+	ReservationCollection := database.DB.Collection("Reservations")
+
+	cursor, err := ReservationCollection.Find(ctx, bson.M{"status": ReservationReady, "expiresAt": bson.M{"$lt": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to fetch reservations: %w", err)
+	}
+	var reservations []Reservation
+	if err := cursor.All(ctx, &reservations); err != nil {
+		return fmt.Errorf("failed to decode reservations: %w", err)
+	}
+
+	for _, reservation := range reservations {
+		result, err := ReservationCollection.UpdateOne(ctx,
+			bson.M{"_id": reservation.ID, "status": ReservationReady},
+			bson.M{"$set": bson.M{"status": ReservationExpired}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update reservation: %w", err)
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		message := fmt.Sprintf("Your reservation of \"%s\" expired because it was not collected", bookTitle(ctx, reservation.BookID))
+		notifications.NotifyWithRetry(ctx, reservation.UserID, notifications.TypeReservationExpired, message)
+
+		if err := releaseBook(ctx, reservation.BookID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreBook sets a book changed for a loan or reservation that could not be
+// saved back to its previous availability, unless something else changed it
+// since
+func restoreBook(ctx context.Context, bookId primitive.ObjectID, current, previous model.BookAvailability) {
+	// the write may have failed because ctx is done
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection("Books").UpdateOne(ctx,
+		bson.M{"_id": bookId, "availability": current},
+		bson.M{"$set": bson.M{"availability": previous}},
+	)
+	if err != nil {
+		log.Printf("Failed to put book %s back to %s: %v", bookId.Hex(), previous, err)
+	}
+}
+
+// uncollect makes a reservation collected for a loan that could not be saved
+// ready to collect again
+func uncollect(ctx context.Context, reservationId primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection("Reservations").UpdateOne(ctx,
+		bson.M{"_id": reservationId, "status": ReservationCollected},
+		bson.M{"$set": bson.M{"status": ReservationReady}, "$unset": bson.M{"collectedAt": ""}},
+	)
+	if err != nil {
+		log.Printf("Failed to put reservation %s back to ready: %v", reservationId.Hex(), err)
+	}
+}
+
+// releaseBook holds a book that came back for the oldest pending reservation of
+// the book or of its work, or puts it back on the shelf when nobody is waiting
+func releaseBook(ctx context.Context, bookId primitive.ObjectID) error {
+	BookCollection := database.DB.Collection("Books")
+	ReservationCollection := database.DB.Collection("Reservations")
+
+	var edition struct {
+		WorkID *primitive.ObjectID `bson:"workId"`
+	}
+	err := BookCollection.FindOne(ctx, bson.M{"_id": bookId}, options.FindOne().SetProjection(bson.M{"workId": 1})).Decode(&edition)
+	if err != nil {
+		return fmt.Errorf("failed to look up book: %w", err)
+	}
+	waiting := bson.A{bson.M{"bookId": bookId}}
+	if edition.WorkID != nil {
+		waiting = append(waiting, bson.M{"workId": *edition.WorkID, "bookId": bson.M{"$exists": false}})
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, envDays("RESERVATION_HOLD_DAYS", 3))
+	var next Reservation
+	err = ReservationCollection.FindOneAndUpdate(ctx,
+		bson.M{"$or": waiting, "status": ReservationPending},
+		bson.M{"$set": bson.M{"bookId": bookId, "status": ReservationReady, "expiresAt": expiresAt}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "reservedAt", Value: 1}}),
+	).Decode(&next)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return fmt.Errorf("failed to look up reservations: %w", err)
+	}
+
+	availability := model.BookAvailabilityAvailable
+	if err == nil {
+		availability = model.BookAvailabilityReserved
+	}
+	_, err = BookCollection.UpdateOne(ctx, bson.M{"_id": bookId}, bson.M{"$set": bson.M{"availability": availability}})
+	if err != nil {
+		return fmt.Errorf("failed to update book: %w", err)
+	}
+
+	book, err := findBook(ctx, bookId)
+	if err != nil {
+		return err
+	}
+	PublishAvailability(book)
+
+	if availability == model.BookAvailabilityReserved {
+		message := fmt.Sprintf("\"%s\" is ready for pickup until %s", book.Title, expiresAt.Format("Jan 2, 2006"))
+		notifications.NotifyWithRetry(ctx, next.UserID, notifications.TypeReservationReady, message)
+	}
+	return nil
+}
+
+func findLoans(ctx context.Context, filter bson.M) ([]Loan, error) {
+	cursor, err := database.DB.Collection("Loans").Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch loans: %w", err)
+	}
+	var loans []Loan
+	if err := cursor.All(ctx, &loans); err != nil {
+		return nil, fmt.Errorf("failed to decode loans: %w", err)
+	}
+	return loans, nil
+}
+
+func findReservations(ctx context.Context, filter bson.M) ([]Reservation, error) {
+	cursor, err := database.DB.Collection("Reservations").Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reservations: %w", err)
+	}
+	var reservations []Reservation
+	if err := cursor.All(ctx, &reservations); err != nil {
+		return nil, fmt.Errorf("failed to decode reservations: %w", err)
+	}
+	return reservations, nil
+}
+
+func findBook(ctx context.Context, bookId primitive.ObjectID) (*model.Book, error) {
+	var book model.Book
+	err := database.DB.Collection("Books").FindOne(ctx, bson.M{"_id": bookId, "deletedAt": database.NotDeleted}).Decode(&book)
+	if err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+	return &book, nil
+}
+
+func bookTitle(ctx context.Context, bookId primitive.ObjectID) string {
+	book, err := findBook(ctx, bookId)
+	if err != nil {
+		log.Printf("failed to look up book %s: %v", bookId.Hex(), err)
+		return "A book"
+	}
+	return book.Title
+}
+
+// envDays reads a number of days from the environment
+func envDays(name string, fallback int) int {
+	days, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || days <= 0 {
+		return fallback
+	}
+	return days
+}
@@ -0,0 +1,95 @@
+package books
+
+import (
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RatingPrior is how many PriorRating ratings a book starts with when ranking
+// by rating, so one five star review does not put it above a book with fifty
+// four star ones. The featured fallback and recommendations rank with the
+// same prior.
+const (
+	RatingPrior = 5
+	PriorRating = 3.0
+)
+
+// ratedCandidates is how many times limit of the best rated books TopRated
+// looks up, leaving room for deleted and filtered ones without joining every
+// reviewed book. A short list is topped up like one with too few reviews.
+const ratedCandidates = 3
+
+// TopRated lists the best rated books matching filter, rated from their
+// reviews. When fewer than limit books have reviews the list is topped up with
+// the most recently added ones. Deleted books are always left out.
+func TopRated(ctx context.Context, filter bson.M, limit int) ([]*model.Book, error) {
+	match := bson.M{"book.deletedAt": database.NotDeleted}
+	for field, value := range filter {
+		match["book."+field] = value
+	}
+
+	cursor, err := database.DB.Collection("Reviews").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$bookId",
+			"rating":  bson.M{"$avg": "$rating"},
+			"total":   bson.M{"$sum": "$rating"},
+			"reviews": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$addFields", Value: bson.M{"score": bson.M{"$divide": bson.A{
+			bson.M{"$add": bson.A{"$total", RatingPrior * PriorRating}},
+			bson.M{"$add": bson.A{"$reviews", RatingPrior}},
+		}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit * ratedCandidates}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "Books",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "book",
+		}}},
+		{{Key: "$unwind", Value: "$book"}},
+		{{Key: "$match", Value: match}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{"$book", bson.M{"rating": "$rating"}}}}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank books: %w", err)
+	}
+	books := []*model.Book{}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+	if len(books) == limit {
+		return books, nil
+	}
+
+	unrated := bson.M{"deletedAt": database.NotDeleted}
+	for field, value := range filter {
+		unrated[field] = value
+	}
+	if len(books) > 0 {
+		rated := make([]primitive.ObjectID, len(books))
+		for i, book := range books {
+			rated[i], _ = primitive.ObjectIDFromHex(book.ID)
+		}
+		unrated["_id"] = bson.M{"$nin": rated}
+	}
+	cursor, err = database.DB.Collection("Books").Find(ctx, unrated, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(limit-len(books))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	var newest []*model.Book
+	if err := cursor.All(ctx, &newest); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+	return append(books, newest...), nil
+}
@@ -0,0 +1,175 @@
+package books
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxRecentlyViewed is how many books are kept in a reader's recently
+	// viewed list
+	maxRecentlyViewed     = 50
+	defaultRecentlyViewed = 20
+	// viewRetention is how long view events are kept for popularity
+	viewRetention = 30 * 24 * time.Hour
+)
+
+// recordView puts book at the front of the reader's recently viewed list and
+// counts the view in BookViews, one document per reader, book and day.
+// Failures are only logged, viewing a book never fails because of them.
+func recordView(ctx context.Context, userID string, book *model.Book) {
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+	bookId, err := primitive.ObjectIDFromHex(book.ID)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+
+	// one pipeline update so concurrent views cannot list a book twice
+	_, err = database.DB.Collection("RecentlyViewed").UpdateOne(ctx,
+		bson.M{"_id": userObjId},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"books": bson.M{"$slice": bson.A{
+				bson.M{"$concatArrays": bson.A{
+					bson.A{bson.M{"bookId": bookId, "viewedAt": now}},
+					bson.M{"$filter": bson.M{
+						"input": bson.M{"$ifNull": bson.A{"$books", bson.A{}}},
+						"cond":  bson.M{"$ne": bson.A{"$$this.bookId", bookId}},
+					}},
+				}},
+				maxRecentlyViewed,
+			}},
+		}}}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Failed to record view of book %s: %v", book.ID, err)
+	}
+
+	// staff looking books up would skew what readers find popular
+	if accountType, _ := auth.GetAccountType(ctx); accountType == "ADMIN" {
+		return
+	}
+	year, month, day := now.UTC().Date()
+	countView := func() error {
+		_, err := database.DB.Collection("BookViews").UpdateOne(ctx,
+			bson.M{"bookId": bookId, "userId": userObjId, "day": time.Date(year, month, day, 0, 0, 0, 0, time.UTC)},
+			bson.M{"$set": bson.M{"viewedAt": now}, "$inc": bson.M{"views": 1}},
+			options.Update().SetUpsert(true),
+		)
+		return err
+	}
+	// two first views of the day race on the unique index, the loser updates
+	// the winner's document
+	if err = countView(); mongo.IsDuplicateKeyError(err) {
+		err = countView()
+	}
+	if err != nil {
+		log.Printf("Failed to record view of book %s: %v", book.ID, err)
+	}
+}
+
+// RecentlyViewedBooks lists the books the reader viewed, most recent first
+func RecentlyViewedBooks(ctx context.Context, limit *int) ([]*model.Book, error) {
+	RecentlyViewedCollection := database.DB.Collection("RecentlyViewed")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	size := defaultRecentlyViewed
+	if limit != nil && *limit > 0 && *limit <= maxRecentlyViewed {
+		size = *limit
+	}
+
+	var viewed struct {
+		Books []struct {
+			BookID primitive.ObjectID `bson:"bookId"`
+		} `bson:"books"`
+	}
+	err = RecentlyViewedCollection.FindOne(ctx, bson.M{"_id": userObjId}).Decode(&viewed)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return []*model.Book{}, nil
+		}
+		return nil, fmt.Errorf("failed to look up recently viewed books: %w", err)
+	}
+	if len(viewed.Books) == 0 {
+		return []*model.Book{}, nil
+	}
+	bookIds := make([]primitive.ObjectID, len(viewed.Books))
+	for i, entry := range viewed.Books {
+		bookIds[i] = entry.BookID
+	}
+
+	books, err := database.OrderedBooks(ctx, bookIds, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(books) > size {
+		books = books[:size]
+	}
+	return books, nil
+}
+
+// ClearRecentlyViewed empties the reader's recently viewed list. View events
+// already counted towards popularity are kept.
+func ClearRecentlyViewed(ctx context.Context) (bool, error) {
+	RecentlyViewedCollection := database.DB.Collection("RecentlyViewed")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return false, fmt.Errorf("user not authenticated")
+	}
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID")
+	}
+
+	_, err = RecentlyViewedCollection.DeleteOne(ctx, bson.M{"_id": userObjId})
+	if err != nil {
+		return false, fmt.Errorf("failed to clear recently viewed books: %w", err)
+	}
+	return true, nil
+}
+
+// ensureViewIndexes expires view counts after viewRetention and keeps one per
+// reader, book and day, indexed by book for popularity
+func ensureViewIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("BookViews").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "viewedAt", Value: 1}},
+			Options: options.Index().SetName("viewed_at_ttl").SetExpireAfterSeconds(int32(viewRetention.Seconds())),
+		},
+		{
+			Keys: bson.D{{Key: "bookId", Value: 1}, {Key: "userId", Value: 1}, {Key: "day", Value: 1}},
+			// events recorded one per request before views were counted
+			// per day have no day, they expire on their own
+			Options: options.Index().SetName("book_viewer_day_unique").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"day": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create book view indexes: %w", err)
+	}
+	return nil
+}
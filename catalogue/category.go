@@ -0,0 +1,45 @@
+package catalogue
+
+import (
+	"bmsgql/graph/model"
+	"strings"
+)
+
+// categoryKeywords is checked in order, so narrower categories come before the
+// broader ones whose keywords they contain ("science fiction" before "science")
+var categoryKeywords = []struct {
+	category model.BookCategory
+	keywords []string
+}{
+	{model.BookCategoryChildren, []string{"juvenile", "children", "picture book"}},
+	{model.BookCategoryScienceFiction, []string{"science fiction", "sci-fi", "dystopia"}},
+	{model.BookCategoryFantasy, []string{"fantasy", "magic", "dragons"}},
+	{model.BookCategoryMystery, []string{"mystery", "detective", "crime"}},
+	{model.BookCategoryThriller, []string{"thriller", "suspense", "espionage"}},
+	{model.BookCategoryRomance, []string{"romance", "love stories", "courtship"}},
+	{model.BookCategoryBiography, []string{"biography", "autobiography", "memoir"}},
+	{model.BookCategorySelfHelp, []string{"self-help", "self help", "personal development", "success"}},
+	{model.BookCategoryHistory, []string{"history", "historical"}},
+	{model.BookCategoryScience, []string{"science", "physics", "biology", "chemistry", "mathematics", "astronomy"}},
+	{model.BookCategoryFiction, []string{"fiction", "novel"}},
+}
+
+// Category maps catalogue subjects to the closest BookCategory, NON_FICTION when
+// none of them match
+func Category(subjects []string) model.BookCategory {
+	lowered := make([]string, len(subjects))
+	for i, subject := range subjects {
+		lowered[i] = strings.ToLower(subject)
+	}
+
+	for _, candidate := range categoryKeywords {
+		for _, subject := range lowered {
+			for _, keyword := range candidate.keywords {
+				if strings.Contains(subject, keyword) {
+					return candidate.category
+				}
+			}
+		}
+	}
+	return model.BookCategoryNonFiction
+}
@@ -0,0 +1,32 @@
+package catalogue
+
+import (
+	"bmsgql/graph/model"
+	"testing"
+)
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		subjects []string
+		want     model.BookCategory
+	}{
+		{nil, model.BookCategoryNonFiction},
+		{[]string{"Cooking", "Baking"}, model.BookCategoryNonFiction},
+		{[]string{"Science fiction"}, model.BookCategoryScienceFiction},
+		{[]string{"SCIENCE"}, model.BookCategoryScience},
+		{[]string{"Physics", "Science fiction"}, model.BookCategoryScienceFiction},
+		{[]string{"Juvenile fiction", "Dragons"}, model.BookCategoryChildren},
+		{[]string{"Fantasy fiction"}, model.BookCategoryFantasy},
+		{[]string{"Detective and mystery stories"}, model.BookCategoryMystery},
+		{[]string{"Love stories"}, model.BookCategoryRomance},
+		{[]string{"Historical fiction"}, model.BookCategoryHistory},
+		{[]string{"Self-help techniques"}, model.BookCategorySelfHelp},
+		{[]string{"Autobiography"}, model.BookCategoryBiography},
+		{[]string{"Novel"}, model.BookCategoryFiction},
+	}
+	for _, tt := range tests {
+		if got := Category(tt.subjects); got != tt.want {
+			t.Errorf("Category(%q) = %s, want %s", tt.subjects, got, tt.want)
+		}
+	}
+}
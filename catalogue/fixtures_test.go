@@ -0,0 +1,64 @@
+package catalogue
+
+import (
+	"bmsgql/graph/model"
+	"bmsgql/isbn"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFixtureProvider(t *testing.T) {
+	provider, err := NewFixtureProvider(bundledFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		isbn     string
+		title    string
+		category model.BookCategory
+	}{
+		{"9780261103573", "The Fellowship of the Ring", model.BookCategoryFantasy},
+		{"9780441172719", "Dune", model.BookCategoryScienceFiction},
+		{"9780062316097", "Sapiens: A Brief History of Humankind", model.BookCategoryHistory},
+		{"9780007119318", "And Then There Were None", model.BookCategoryMystery},
+		{"9780064400558", "Charlotte's Web", model.BookCategoryChildren},
+	}
+	for _, tt := range tests {
+		metadata, err := provider.Lookup(context.Background(), tt.isbn)
+		if err != nil {
+			t.Errorf("Lookup(%s) = %v", tt.isbn, err)
+			continue
+		}
+		if metadata.ISBN != tt.isbn || metadata.Title != tt.title {
+			t.Errorf("Lookup(%s) = %s %q, want %q", tt.isbn, metadata.ISBN, metadata.Title, tt.title)
+		}
+		if got := Category(metadata.Subjects); got != tt.category {
+			t.Errorf("%s: category %s, want %s", tt.title, got, tt.category)
+		}
+	}
+
+	if _, err := provider.Lookup(context.Background(), "9780306406157"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown ISBN: got %v, want ErrNotFound", err)
+	}
+}
+
+// the bundled records are keyed the way books store ISBNs
+func TestBundledFixturesAreNormalized(t *testing.T) {
+	provider, err := NewFixtureProvider(bundledFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range provider.records {
+		if normalized, err := isbn.Normalize(key); err != nil || normalized != key {
+			t.Errorf("fixture %s normalizes to %q, %v", key, normalized, err)
+		}
+	}
+}
+
+func TestFixtureProviderRejectsBadData(t *testing.T) {
+	if _, err := NewFixtureProvider([]byte("[1, 2]")); err == nil {
+		t.Error("decoded a list as fixtures")
+	}
+}
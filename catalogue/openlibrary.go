@@ -0,0 +1,152 @@
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultOpenLibraryURL = "https://openlibrary.org"
+
+// OpenLibraryProvider reads metadata from the Open Library Books API
+type OpenLibraryProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenLibraryProvider(baseURL string) *OpenLibraryProvider {
+	if baseURL == "" {
+		baseURL = defaultOpenLibraryURL
+	}
+	return &OpenLibraryProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *OpenLibraryProvider) Name() string {
+	return "openlibrary"
+}
+
+type openLibraryEdition struct {
+	Key     string `json:"key"`
+	Title   string `json:"title"`
+	Authors []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Subjects []struct {
+		Name string `json:"name"`
+	} `json:"subjects"`
+	Cover struct {
+		Large  string `json:"large"`
+		Medium string `json:"medium"`
+	} `json:"cover"`
+}
+
+func (p *OpenLibraryProvider) Lookup(ctx context.Context, isbn string) (*Metadata, error) {
+	bibkey := "ISBN:" + isbn
+	query := url.Values{"bibkeys": {bibkey}, "format": {"json"}, "jscmd": {"data"}}
+
+	var editions map[string]openLibraryEdition
+	if err := p.get(ctx, "/api/books?"+query.Encode(), &editions); err != nil {
+		return nil, err
+	}
+	edition, ok := editions[bibkey]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	metadata := &Metadata{
+		ISBN:     isbn,
+		Title:    edition.Title,
+		CoverURL: edition.Cover.Large,
+	}
+	if metadata.CoverURL == "" {
+		metadata.CoverURL = edition.Cover.Medium
+	}
+	for _, author := range edition.Authors {
+		metadata.Authors = append(metadata.Authors, author.Name)
+	}
+	for _, subject := range edition.Subjects {
+		metadata.Subjects = append(metadata.Subjects, subject.Name)
+	}
+	// the Books API has no description, the work usually does. It is a nice to
+	// have, so failing to fetch it does not fail the lookup.
+	if edition.Key != "" {
+		metadata.Description, _ = p.description(ctx, edition.Key)
+	}
+	return metadata, nil
+}
+
+// description returns the description of the edition, falling back to its work's
+func (p *OpenLibraryProvider) description(ctx context.Context, editionKey string) (string, error) {
+	var edition struct {
+		Description openLibraryText `json:"description"`
+		Works       []struct {
+			Key string `json:"key"`
+		} `json:"works"`
+	}
+	if err := p.get(ctx, editionKey+".json", &edition); err != nil {
+		return "", err
+	}
+	if edition.Description != "" || len(edition.Works) == 0 {
+		return string(edition.Description), nil
+	}
+
+	var work struct {
+		Description openLibraryText `json:"description"`
+	}
+	if err := p.get(ctx, edition.Works[0].Key+".json", &work); err != nil {
+		return "", err
+	}
+	return string(work.Description), nil
+}
+
+func (p *OpenLibraryProvider) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("open library request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("open library responded with status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode open library response: %w", err)
+	}
+	return nil
+}
+
+// openLibraryText is a text field that is either a plain string or a
+// {"type": "/type/text", "value": "..."} object
+type openLibraryText string
+
+func (t *openLibraryText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = openLibraryText(s)
+		return nil
+	}
+	var text struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*t = openLibraryText(text.Value)
+	return nil
+}
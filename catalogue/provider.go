@@ -0,0 +1,38 @@
+package catalogue
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+var ErrNotFound = errors.New("no catalogue record for this ISBN")
+
+// Metadata is what a catalogue provider knows about an edition
+type Metadata struct {
+	ISBN        string   `json:"isbn"`
+	Title       string   `json:"title"`
+	Authors     []string `json:"authors"`
+	Description string   `json:"description"`
+	Subjects    []string `json:"subjects"`
+	CoverURL    string   `json:"coverUrl"`
+}
+
+// MetadataProvider looks up edition metadata by ISBN-13
+type MetadataProvider interface {
+	Name() string
+	Lookup(ctx context.Context, isbn string) (*Metadata, error)
+}
+
+// ProviderFromEnv picks the provider from METADATA_PROVIDER. "fixtures" serves the
+// records of METADATA_FIXTURES, or the bundled ones, without network access;
+// anything else uses Open Library.
+func ProviderFromEnv() (MetadataProvider, error) {
+	if os.Getenv("METADATA_PROVIDER") == "fixtures" {
+		if path := os.Getenv("METADATA_FIXTURES"); path != "" {
+			return NewFixtureProviderFromFile(path)
+		}
+		return NewFixtureProvider(bundledFixtures)
+	}
+	return NewOpenLibraryProvider(os.Getenv("OPEN_LIBRARY_URL")), nil
+}
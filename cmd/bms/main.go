@@ -0,0 +1,278 @@
+// Command bms runs maintenance tasks against the library database.
+//
+//	bms import [-format csv|marc|marcxml] <file>
+//	bms export [-format csv|jsonl|onix] [-category FANTASY] [-from 2024-01-01] [-to 2024-12-31] [-o file]
+//	bms purge-books [-older-than 720h]
+//	bms migrate-authors
+//	bms normalize-isbns
+//	bms recommend
+package main
+
+import (
+	"bmsgql/authors"
+	"bmsgql/books"
+	"bmsgql/database"
+	"bmsgql/exports"
+	"bmsgql/graph/model"
+	"bmsgql/imports"
+	"bmsgql/recommendations"
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: bms <command> [flags]
+
+commands:
+  import           import catalogue records from a CSV, MARC or MARCXML file
+  export           export the catalogue as CSV, JSON Lines or ONIX
+  purge-books      permanently remove books that were soft deleted
+  migrate-authors  link plain author strings to deduplicated author records
+  normalize-isbns  store ISBNs as ISBN-13 and report books sharing one
+  recommend        recompute reader recommendations now instead of at night
+`
+
+var commands = map[string]func(args []string){
+	"import":          importCatalogue,
+	"export":          exportCatalogue,
+	"purge-books":     purgeBooks,
+	"migrate-authors": migrateAuthors,
+	"normalize-isbns": normalizeIsbns,
+	"recommend":       recommend,
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	commands[os.Args[1]](os.Args[2:])
+}
+
+func connect() func() {
+	err := godotenv.Load()
+	if err != nil {
+		log.Println("No .env file found, loading environment variables from system")
+	}
+
+	client, err := database.Connect()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	return func() {
+		client.Disconnect(context.Background())
+	}
+}
+
+// importCatalogue imports a file in the foreground, the same way the
+// importCatalogue mutation does in the background
+func importCatalogue(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "csv, marc or marcxml, detected from the file when empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("usage: bms import [-format csv|marc|marcxml] <file>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open import file: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	importFormat := model.ImportFormat(strings.ToUpper(*format))
+	if *format == "" {
+		head, _ := reader.Peek(512)
+		importFormat = imports.DetectFormat(file.Name(), head)
+	} else if !importFormat.IsValid() {
+		log.Fatalf("Unknown import format %q", *format)
+	}
+
+	total, err := imports.Count(reader, importFormat)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", file.Name(), err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Fatalf("Failed to read %s: %v", file.Name(), err)
+	}
+	rows, err := imports.NewReader(bufio.NewReader(file), importFormat)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", file.Name(), err)
+	}
+
+	disconnect := connect()
+	defer disconnect()
+
+	ctx := context.Background()
+	result, err := imports.Import(ctx, rows, total, func(result imports.Result) {
+		log.Printf("Processed %d/%d row(s)", result.Processed, result.Total)
+	})
+	if result.Processed > 0 {
+		imports.Audit(ctx, "ImportFile", file.Name(), nil, result, err)
+	}
+	if err != nil {
+		log.Fatalf("Import stopped: %v", err)
+	}
+	for _, rowError := range result.RowErrors {
+		log.Printf("Row %d: %s", rowError.Row, rowError.Message)
+	}
+	log.Printf("Imported %s: %d created, %d updated, %d failed", file.Name(), result.Created, result.Updated, result.Failed)
+}
+
+// exportCatalogue writes the catalogue to -o, or to standard output
+func exportCatalogue(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "csv", "csv, jsonl or onix")
+	category := flags.String("category", "", "only export books of this category")
+	from := flags.String("from", "", "only export books added on or after this date")
+	to := flags.String("to", "", "only export books added on or before this date")
+	output := flags.String("o", "", "file to write, standard output when empty")
+	flags.Parse(args)
+
+	exportFormat, err := exports.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	filter, err := exports.ParseFilter(*category, *from, *to)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create export file: %v", err)
+		}
+		defer out.Close()
+	}
+
+	disconnect := connect()
+	defer disconnect()
+
+	writer := bufio.NewWriter(out)
+	count, err := exports.Export(context.Background(), writer, exportFormat, filter)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		log.Fatalf("Export failed after %d book(s): %v", count, err)
+	}
+	log.Printf("Exported %d book(s)", count)
+}
+
+// purgeBooks permanently removes books that were soft deleted longer than
+// -older-than ago, along with the records that still point at them
+func purgeBooks(args []string) {
+	flags := flag.NewFlagSet("purge-books", flag.ExitOnError)
+	olderThan := flags.Duration("older-than", 30*24*time.Hour, "purge books deleted longer ago than this")
+	flags.Parse(args)
+
+	disconnect := connect()
+	defer disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cutoff := time.Now().Add(-*olderThan)
+	purged, err := books.PurgeDeletedBooks(ctx, cutoff)
+	if err != nil {
+		log.Fatalf("Failed to purge deleted books: %v", err)
+	}
+	log.Printf("Purged %d book(s) deleted before %s", purged, cutoff.Format(time.RFC3339))
+}
+
+// migrateAuthors turns the author strings of books into author records,
+// merging spellings of the same name
+func migrateAuthors(args []string) {
+	flags := flag.NewFlagSet("migrate-authors", flag.ExitOnError)
+	flags.Parse(args)
+
+	disconnect := connect()
+	defer disconnect()
+
+	ctx := context.Background()
+	if err := authors.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("Failed to create indexes: %v", err)
+	}
+	result, err := authors.Migrate(ctx)
+	if err != nil {
+		log.Fatalf("Author migration failed: %v", err)
+	}
+	log.Printf("Linked %d book(s) to authors, created %d author(s), skipped %d book(s) without an author, refreshed the author of %d book(s)", result.Books, result.Authors, result.Skipped, result.Refreshed)
+}
+
+// normalizeIsbns converts stored ISBNs to ISBN-13 and builds the unique ISBN
+// index the server needs to start. Books that share an ISBN or have an
+// invalid one are listed for someone to fix by hand, then the command can be
+// run again.
+func normalizeIsbns(args []string) {
+	flags := flag.NewFlagSet("normalize-isbns", flag.ExitOnError)
+	flags.Parse(args)
+
+	disconnect := connect()
+	defer disconnect()
+
+	ctx := context.Background()
+	result, err := books.NormalizeIsbns(ctx)
+	if err != nil {
+		log.Fatalf("ISBN migration failed: %v", err)
+	}
+	log.Printf("Normalized the ISBN of %d book(s)", result.Normalized)
+
+	for _, book := range result.Invalid {
+		log.Printf("Invalid ISBN %q: book %s %q%s", book.Isbn, book.ID, book.Title, deletedNote(book))
+	}
+	isbns := make([]string, 0, len(result.Duplicates))
+	for value := range result.Duplicates {
+		isbns = append(isbns, value)
+	}
+	sort.Strings(isbns)
+	for _, value := range isbns {
+		log.Printf("ISBN %s is shared by %d books:", value, len(result.Duplicates[value]))
+		for _, book := range result.Duplicates[value] {
+			log.Printf("  book %s %q stored as %q%s", book.ID, book.Title, book.Isbn, deletedNote(book))
+		}
+	}
+	if len(result.Duplicates) > 0 {
+		log.Fatalf("%d ISBN(s) are shared by several books, merge or correct them and run normalize-isbns again", len(result.Duplicates))
+	}
+
+	if err := books.EnsureIsbnIndex(ctx); err != nil {
+		log.Fatalf("%v", err)
+	}
+	log.Printf("ISBN index is in place")
+}
+
+func deletedNote(book books.IsbnBook) string {
+	if book.Deleted {
+		return " (deleted)"
+	}
+	return ""
+}
+
+// recommend runs the nightly recommendations refresh right away, e.g. after a
+// large import
+func recommend(args []string) {
+	flags := flag.NewFlagSet("recommend", flag.ExitOnError)
+	flags.Parse(args)
+
+	disconnect := connect()
+	defer disconnect()
+
+	run, err := recommendations.Refresh(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to refresh recommendations: %v", err)
+	}
+	log.Printf("Ranked %d book(s) for %d reader(s)", run.Books, run.Readers)
+}
@@ -0,0 +1,554 @@
+package collections
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/covers"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const dateLayout = "2006-01-02"
+
+// collectionOrder is the order admins arranged the collections in
+var collectionOrder = bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}}
+
+// Collections lists the collections in their curated order. Only admins can
+// list the ones that are scheduled or over.
+func Collections(ctx context.Context, activeOnly *bool) ([]*model.Collection, error) {
+	CollectionCollection := database.DB.Collection("Collections")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	accountType, _ := auth.GetAccountType(ctx)
+
+	filter := bson.M{}
+	if accountType != "ADMIN" || activeOnly == nil || *activeOnly {
+		filter = activeFilter(time.Now())
+	}
+	cursor, err := CollectionCollection.Find(ctx, filter, options.Find().SetSort(collectionOrder))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collections: %w", err)
+	}
+	collectionlist := []*model.Collection{}
+	if err := cursor.All(ctx, &collectionlist); err != nil {
+		return nil, fmt.Errorf("failed to decode collections: %w", err)
+	}
+	return collectionlist, nil
+}
+
+func Collection(ctx context.Context, id string) (*model.Collection, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	accountType, _ := auth.GetAccountType(ctx)
+
+	collectionId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collection ID")
+	}
+	collection, err := findCollection(ctx, collectionId)
+	if err != nil {
+		return nil, err
+	}
+	if accountType != "ADMIN" && !Active(collection) {
+		return nil, errcode.Errorf(errcode.NotFound, "collection not found")
+	}
+	return collection, nil
+}
+
+// CreateCollection adds a collection after all the others
+func CreateCollection(ctx context.Context, input model.CollectionInput) (*model.Collection, error) {
+	CollectionCollection := database.DB.Collection("Collections")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	insertedId := primitive.NewObjectID()
+	collection := &model.Collection{
+		ID:          insertedId.Hex(),
+		Title:       strings.Join(strings.Fields(input.Title), " "),
+		BookIDs:     []primitive.ObjectID{},
+		Description: "",
+	}
+	if collection.Title == "" {
+		return nil, errcode.Errorf(errcode.BadUserInput, "collection title is required")
+	}
+	if input.Description != nil {
+		collection.Description = *input.Description
+	}
+	if input.Featured != nil {
+		collection.Featured = *input.Featured
+	}
+	var err error
+	if collection.StartTime, err = parseTime("startsAt", input.StartsAt); err != nil {
+		return nil, err
+	}
+	if collection.EndTime, err = parseTime("endsAt", input.EndsAt); err != nil {
+		return nil, err
+	}
+	if err := checkWindow(collection.StartTime, collection.EndTime); err != nil {
+		return nil, err
+	}
+	if input.BookIds != nil {
+		if collection.BookIDs, err = checkBooks(ctx, input.BookIds); err != nil {
+			return nil, err
+		}
+	}
+
+	var last model.Collection
+	err = CollectionCollection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}})).Decode(&last)
+	if err == nil {
+		collection.Position = last.Position + 1
+	} else if err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to look up collections: %w", err)
+	}
+
+	if input.CoverImage != nil {
+		collection.CoverImage = *input.CoverImage
+	}
+	if input.Cover != nil {
+		if input.CoverImage != nil {
+			return nil, errcode.Errorf(errcode.BadUserInput, "give either coverImage or cover, not both")
+		}
+		if collection.CoverKey, err = covers.Save(ctx, insertedId, *input.Cover); err != nil {
+			return nil, err
+		}
+	}
+
+	newCollection := bson.M{
+		"_id":         insertedId,
+		"title":       collection.Title,
+		"description": collection.Description,
+		"coverImage":  collection.CoverImage,
+		"featured":    collection.Featured,
+		"position":    collection.Position,
+		"bookIds":     collection.BookIDs,
+	}
+	if collection.CoverKey != "" {
+		newCollection["coverKey"] = collection.CoverKey
+	}
+	if collection.StartTime != nil {
+		newCollection["startsAt"] = *collection.StartTime
+	}
+	if collection.EndTime != nil {
+		newCollection["endsAt"] = *collection.EndTime
+	}
+	_, err = CollectionCollection.InsertOne(ctx, newCollection)
+	if err != nil {
+		if collection.CoverKey != "" {
+			covers.Delete(ctx, collection.CoverKey)
+		}
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	audit.Track(ctx, "Collection", collection.ID, nil, collection)
+	return collection, nil
+}
+
+// EditCollection updates a collection. Giving bookIds replaces its books and
+// their order.
+func EditCollection(ctx context.Context, id string, input model.EditCollectionInput) (*model.Collection, error) {
+	CollectionCollection := database.DB.Collection("Collections")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	collectionId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collection ID")
+	}
+	before, err := findCollection(ctx, collectionId)
+	if err != nil {
+		return nil, err
+	}
+	if input.CoverImage != nil && input.Cover != nil {
+		return nil, errcode.Errorf(errcode.BadUserInput, "give either coverImage or cover, not both")
+	}
+
+	updateCollection := bson.M{}
+	unsetCollection := bson.M{}
+	if input.Title != nil {
+		title := strings.Join(strings.Fields(*input.Title), " ")
+		if title == "" {
+			return nil, errcode.Errorf(errcode.BadUserInput, "collection title is required")
+		}
+		updateCollection["title"] = title
+	}
+	if input.Description != nil {
+		updateCollection["description"] = input.Description
+	}
+	if input.Featured != nil {
+		updateCollection["featured"] = input.Featured
+	}
+	startsAt, endsAt := before.StartTime, before.EndTime
+	if input.StartsAt != nil {
+		if startsAt, err = parseTime("startsAt", input.StartsAt); err != nil {
+			return nil, err
+		}
+		if startsAt == nil {
+			unsetCollection["startsAt"] = ""
+		} else {
+			updateCollection["startsAt"] = *startsAt
+		}
+	}
+	if input.EndsAt != nil {
+		if endsAt, err = parseTime("endsAt", input.EndsAt); err != nil {
+			return nil, err
+		}
+		if endsAt == nil {
+			unsetCollection["endsAt"] = ""
+		} else {
+			updateCollection["endsAt"] = *endsAt
+		}
+	}
+	if err := checkWindow(startsAt, endsAt); err != nil {
+		return nil, err
+	}
+	if input.BookIds != nil {
+		bookIds, err := checkBooks(ctx, input.BookIds)
+		if err != nil {
+			return nil, err
+		}
+		updateCollection["bookIds"] = bookIds
+	}
+
+	// an external coverImage replaces uploaded cover art
+	var coverKey string
+	if input.CoverImage != nil {
+		updateCollection["coverImage"] = input.CoverImage
+		unsetCollection["coverKey"] = ""
+	}
+	if input.Cover != nil {
+		if coverKey, err = covers.Save(ctx, collectionId, *input.Cover); err != nil {
+			return nil, err
+		}
+		updateCollection["coverImage"] = ""
+		updateCollection["coverKey"] = coverKey
+	}
+
+	update := bson.M{}
+	if len(updateCollection) > 0 {
+		update["$set"] = updateCollection
+	}
+	if len(unsetCollection) > 0 {
+		update["$unset"] = unsetCollection
+	}
+	if len(update) == 0 {
+		return before, nil
+	}
+
+	var collection model.Collection
+	err = CollectionCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": collectionId},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&collection)
+	if err != nil {
+		if coverKey != "" && coverKey != before.CoverKey {
+			covers.Delete(ctx, coverKey)
+		}
+		if err == mongo.ErrNoDocuments {
+			return nil, errcode.Errorf(errcode.NotFound, "collection not found")
+		}
+		return nil, fmt.Errorf("failed to update collection: %w", err)
+	}
+	if before.CoverKey != "" && before.CoverKey != collection.CoverKey {
+		covers.Delete(ctx, before.CoverKey)
+	}
+
+	audit.Track(ctx, "Collection", id, before, &collection)
+	return &collection, nil
+}
+
+func DeleteCollection(ctx context.Context, id string) (bool, error) {
+	CollectionCollection := database.DB.Collection("Collections")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return false, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return false, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return false, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	collectionId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid collection ID")
+	}
+
+	var before model.Collection
+	err = CollectionCollection.FindOneAndDelete(ctx, bson.M{"_id": collectionId}).Decode(&before)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, errcode.Errorf(errcode.NotFound, "collection not found")
+		}
+		return false, fmt.Errorf("failed to delete collection: %w", err)
+	}
+	if before.CoverKey != "" {
+		covers.Delete(ctx, before.CoverKey)
+	}
+
+	audit.Track(ctx, "Collection", id, &before, nil)
+	return true, nil
+}
+
+// ReorderCollections moves the given collections to the front in the order
+// given, the others keep their order behind them
+func ReorderCollections(ctx context.Context, ids []string) ([]*model.Collection, error) {
+	CollectionCollection := database.DB.Collection("Collections")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	cursor, err := CollectionCollection.Find(ctx, bson.M{}, options.Find().SetSort(collectionOrder))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collections: %w", err)
+	}
+	var current []*model.Collection
+	if err := cursor.All(ctx, &current); err != nil {
+		return nil, fmt.Errorf("failed to decode collections: %w", err)
+	}
+	byId := make(map[string]*model.Collection, len(current))
+	for _, collection := range current {
+		byId[collection.ID] = collection
+	}
+
+	ordered := []*model.Collection{}
+	moved := map[string]bool{}
+	for _, id := range ids {
+		collection, ok := byId[id]
+		if !ok {
+			return nil, errcode.Errorf(errcode.NotFound, "collection %s not found", id)
+		}
+		if !moved[id] {
+			moved[id] = true
+			ordered = append(ordered, collection)
+		}
+	}
+	for _, collection := range current {
+		if !moved[collection.ID] {
+			ordered = append(ordered, collection)
+		}
+	}
+
+	var writes []mongo.WriteModel
+	for position, collection := range ordered {
+		if collection.Position == position {
+			continue
+		}
+		collectionId, _ := primitive.ObjectIDFromHex(collection.ID)
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": collectionId}).
+			SetUpdate(bson.M{"$set": bson.M{"position": position}}))
+		collection.Position = position
+	}
+	if len(writes) > 0 {
+		if _, err := CollectionCollection.BulkWrite(ctx, writes); err != nil {
+			return nil, fmt.Errorf("failed to reorder collections: %w", err)
+		}
+	}
+
+	audit.Track(ctx, "Collection", "order", nil, ids)
+	return ordered, nil
+}
+
+// Books lists the books of a collection in their curated order, leaving out
+// deleted ones
+func Books(ctx context.Context, collection *model.Collection) ([]*model.Book, error) {
+	return database.OrderedBooks(ctx, collection.BookIDs, nil)
+}
+
+// Featured returns the books of the first active featured collection that has
+// any, nil when there is none
+func Featured(ctx context.Context) ([]*model.Book, error) {
+	filter := activeFilter(time.Now())
+	filter["featured"] = true
+	filter["bookIds.0"] = bson.M{"$exists": true}
+
+	cursor, err := database.DB.Collection("Collections").Find(ctx, filter, options.Find().SetSort(collectionOrder))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch featured collections: %w", err)
+	}
+	var featured []*model.Collection
+	if err := cursor.All(ctx, &featured); err != nil {
+		return nil, fmt.Errorf("failed to decode featured collections: %w", err)
+	}
+	for _, collection := range featured {
+		books, err := Books(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		if len(books) > 0 {
+			return books, nil
+		}
+	}
+	return nil, nil
+}
+
+// Active reports whether a collection is shown right now
+func Active(collection *model.Collection) bool {
+	now := time.Now()
+	return (collection.StartTime == nil || !collection.StartTime.After(now)) &&
+		(collection.EndTime == nil || collection.EndTime.After(now))
+}
+
+// CoverURL is where the cover art of a collection can be fetched in the given
+// size, nil when it has none
+func CoverURL(collection *model.Collection, size model.CoverSize) *string {
+	url := covers.KeyURL(collection.CoverKey, collection.CoverImage, size)
+	if url == "" {
+		return nil
+	}
+	return &url
+}
+
+// FormatTime formats a collection date for the API
+func FormatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.Format(time.RFC3339)
+	return &formatted
+}
+
+// EnsureIndexes creates the index featured collection lookups rely on
+func EnsureIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("Collections").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "featured", Value: 1}, {Key: "position", Value: 1}},
+		Options: options.Index().SetName("featured_position"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection index: %w", err)
+	}
+	return nil
+}
+
+// activeFilter matches collections whose dates include now
+func activeFilter(now time.Time) bson.M {
+	return bson.M{"$and": bson.A{
+		bson.M{"$or": bson.A{bson.M{"startsAt": bson.M{"$exists": false}}, bson.M{"startsAt": bson.M{"$lte": now}}}},
+		bson.M{"$or": bson.A{bson.M{"endsAt": bson.M{"$exists": false}}, bson.M{"endsAt": bson.M{"$gt": now}}}},
+	}}
+}
+
+func findCollection(ctx context.Context, collectionId primitive.ObjectID) (*model.Collection, error) {
+	var collection model.Collection
+	err := database.DB.Collection("Collections").FindOne(ctx, bson.M{"_id": collectionId}).Decode(&collection)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errcode.Errorf(errcode.NotFound, "collection not found")
+		}
+		return nil, fmt.Errorf("failed to find collection: %w", err)
+	}
+	return &collection, nil
+}
+
+// checkBooks makes sure every book exists and returns their IDs in the order
+// given, without repeats
+func checkBooks(ctx context.Context, ids []string) ([]primitive.ObjectID, error) {
+	bookIds := []primitive.ObjectID{}
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		bookId, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.BadUserInput, "invalid book ID %q", id)
+		}
+		if !seen[bookId] {
+			seen[bookId] = true
+			bookIds = append(bookIds, bookId)
+		}
+	}
+	if len(bookIds) == 0 {
+		return bookIds, nil
+	}
+
+	count, err := database.DB.Collection("Books").CountDocuments(ctx, bson.M{"_id": bson.M{"$in": bookIds}, "deletedAt": database.NotDeleted})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up books: %w", err)
+	}
+	if int(count) != len(bookIds) {
+		return nil, errcode.Errorf(errcode.NotFound, "%d of the given books do not exist", len(bookIds)-int(count))
+	}
+	return bookIds, nil
+}
+
+// parseTime reads an optional RFC 3339 time or date, an empty value is no time
+func parseTime(field string, value *string) (*time.Time, error) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(*value))
+	if err != nil {
+		t, err = time.Parse(dateLayout, strings.TrimSpace(*value))
+	}
+	if err != nil {
+		return nil, errcode.Errorf(errcode.BadUserInput, "invalid %s %q: expected a date such as 2024-06-01 or an RFC 3339 time", field, *value)
+	}
+	return &t, nil
+}
+
+func checkWindow(startsAt, endsAt *time.Time) error {
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return errcode.Errorf(errcode.BadUserInput, "endsAt must be after startsAt")
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package covers
+
+import (
+	"bmsgql/blobstore"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultMaxBytes = 5 << 20
+	// maxPixels refuses images that are small files but huge once decoded
+	maxPixels   = 24_000_000
+	jpegQuality = 85
+)
+
+// thumbnails are the renditions made of every upload, each scaled to fit its
+// box. ORIGINAL is the upload itself.
+var thumbnails = []struct {
+	size          model.CoverSize
+	width, height int
+}{
+	{model.CoverSizeLarge, 600, 900},
+	{model.CoverSizeMedium, 300, 450},
+	{model.CoverSizeSmall, 120, 180},
+}
+
+// contentTypes are the accepted image types, sniffed from the data rather than
+// trusting the client
+var contentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+var blobStore = sync.OnceValues(blobstore.FromEnv)
+
+// Save validates an uploaded cover, stores it with its thumbnails and returns
+// the key to keep on the book, or on whatever else id names. Keys include a
+// hash of the image, a new upload never overwrites one a client may have
+// cached.
+func Save(ctx context.Context, id primitive.ObjectID, upload graphql.Upload) (string, error) {
+	store, err := blobStore()
+	if err != nil {
+		return "", fmt.Errorf("cover storage is not available: %w", err)
+	}
+
+	maxBytes := maxUploadBytes()
+	data, err := io.ReadAll(io.LimitReader(upload.File, maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read cover image: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return "", errcode.Errorf(errcode.BadUserInput, "cover image is larger than %d KB", maxBytes>>10)
+	}
+	contentType := http.DetectContentType(data)
+	if !contentTypes[contentType] {
+		return "", errcode.Errorf(errcode.BadUserInput, "cover image must be a JPEG, PNG or GIF, got %s", contentType)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", errcode.Errorf(errcode.BadUserInput, "cover image could not be read: %v", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return "", errcode.Errorf(errcode.BadUserInput, "cover image is too large at %dx%d pixels", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", errcode.Errorf(errcode.BadUserInput, "cover image could not be read: %v", err)
+	}
+
+	hash := sha256.Sum256(data)
+	key := "covers/" + id.Hex() + "/" + hex.EncodeToString(hash[:8])
+
+	if err := store.Put(ctx, objectKey(key, model.CoverSizeOriginal), bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return "", fmt.Errorf("failed to store cover image: %w", err)
+	}
+	// each thumbnail is scaled from the previous, larger one
+	scaled := flatten(img)
+	for _, thumbnail := range thumbnails {
+		width, height := fit(scaled.Bounds().Dx(), scaled.Bounds().Dy(), thumbnail.width, thumbnail.height)
+		scaled = resize(scaled, width, height)
+
+		var encoded bytes.Buffer
+		if err := jpeg.Encode(&encoded, scaled, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			Delete(ctx, key)
+			return "", fmt.Errorf("failed to encode cover thumbnail: %w", err)
+		}
+		if err := store.Put(ctx, objectKey(key, thumbnail.size), &encoded, int64(encoded.Len()), "image/jpeg"); err != nil {
+			Delete(ctx, key)
+			return "", fmt.Errorf("failed to store cover thumbnail: %w", err)
+		}
+	}
+	return key, nil
+}
+
+// Delete removes a stored cover and its thumbnails. Failures are logged, an
+// orphaned image is not worth failing the request over.
+func Delete(ctx context.Context, key string) {
+	store, err := blobStore()
+	if err != nil {
+		log.Printf("covers: failed to delete %s: %v", key, err)
+		return
+	}
+	for _, size := range model.AllCoverSize {
+		if err := store.Delete(ctx, objectKey(key, size)); err != nil {
+			log.Printf("covers: failed to delete %s: %v", objectKey(key, size), err)
+		}
+	}
+}
+
+// URL is where the cover of a book can be fetched in the given size. Books
+// whose coverImage is an external URL have no thumbnails, every size is that
+// URL.
+func URL(book *model.Book, size model.CoverSize) string {
+	return KeyURL(book.CoverKey, book.CoverImage, size)
+}
+
+// KeyURL is URL for anything else with a cover, such as collection cover art.
+// image is returned when there is no stored cover.
+func KeyURL(key, image string, size model.CoverSize) string {
+	if key == "" {
+		return image
+	}
+	store, err := blobStore()
+	if err != nil {
+		log.Printf("covers: %v", err)
+		return image
+	}
+	return store.URL(objectKey(key, size))
+}
+
+// Handler serves covers kept by the local filesystem store at
+// blobstore.LocalPrefix, other stores serve their objects themselves
+func Handler() http.Handler {
+	store, err := blobStore()
+	if local, ok := store.(*blobstore.Local); err == nil && ok {
+		return http.StripPrefix(blobstore.LocalPrefix, local)
+	}
+	return http.NotFoundHandler()
+}
+
+func objectKey(key string, size model.CoverSize) string {
+	return key + "/" + strings.ToLower(size.String())
+}
+
+func maxUploadBytes() int64 {
+	n, err := strconv.ParseInt(os.Getenv("COVER_MAX_BYTES"), 10, 64)
+	if err != nil || n <= 0 {
+		return defaultMaxBytes
+	}
+	return n
+}
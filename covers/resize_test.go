@@ -0,0 +1,83 @@
+package covers
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		width, height, maxWidth, maxHeight int
+		wantWidth, wantHeight              int
+	}{
+		{100, 150, 200, 300, 100, 150},
+		{200, 300, 200, 300, 200, 300},
+		{400, 600, 200, 300, 200, 300},
+		{1000, 500, 200, 300, 200, 100},
+		{500, 1000, 200, 300, 150, 300},
+		// a sliver keeps at least one pixel
+		{10000, 1, 200, 300, 200, 1},
+		{1, 10000, 200, 300, 1, 300},
+	}
+	for _, tt := range tests {
+		width, height := fit(tt.width, tt.height, tt.maxWidth, tt.maxHeight)
+		if width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("fit(%d, %d, %d, %d) = %dx%d, want %dx%d", tt.width, tt.height, tt.maxWidth, tt.maxHeight,
+				width, height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// left half black, right half white
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if x >= 2 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	if got := resize(src, 4, 2); got != src {
+		t.Error("resizing to the same size made a copy")
+	}
+
+	tests := []struct {
+		width, height int
+		want          []uint8
+	}{
+		{2, 1, []uint8{0, 255}},
+		{1, 1, []uint8{127}},
+		{2, 2, []uint8{0, 255, 0, 255}},
+	}
+	for _, tt := range tests {
+		dst := resize(src, tt.width, tt.height)
+		if dst.Bounds().Dx() != tt.width || dst.Bounds().Dy() != tt.height {
+			t.Errorf("resize to %dx%d gave %v", tt.width, tt.height, dst.Bounds())
+			continue
+		}
+		for i, want := range tt.want {
+			p := dst.RGBAAt(i%tt.width, i/tt.width)
+			if p.R != want || p.G != want || p.B != want || p.A != 255 {
+				t.Errorf("resize to %dx%d: pixel %d is %v, want grey %d", tt.width, tt.height, i, p, want)
+			}
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(10, 10, 12, 11))
+	src.SetNRGBA(10, 10, color.NRGBA{255, 0, 0, 255})
+	// the second pixel is fully transparent
+	dst := flatten(src)
+	if got := dst.RGBAAt(0, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("opaque pixel became %v", got)
+	}
+	if got := dst.RGBAAt(1, 0); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("transparent pixel became %v, want white", got)
+	}
+}
@@ -0,0 +1,283 @@
+package dashboard
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultCacheTTL = time.Minute
+	topListSize     = 10
+	// topListCandidates is how many of the top ranked books are looked up to
+	// fill a list, leaving room for deleted and purged ones without joining
+	// every book ever borrowed or viewed
+	topListCandidates = topListSize * 3
+)
+
+// cache keeps the last computed dashboard so that refreshing the admin page does
+// not run every aggregation again
+var cache struct {
+	sync.Mutex
+	dashboard *model.AdminDashboard
+	expiresAt time.Time
+}
+
+func AdminDashboard(ctx context.Context) (*model.AdminDashboard, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.dashboard != nil && time.Now().Before(cache.expiresAt) {
+		return cache.dashboard, nil
+	}
+
+	dashboard, err := compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.dashboard = dashboard
+	cache.expiresAt = time.Now().Add(cacheTTL())
+	return dashboard, nil
+}
+
+func compute(ctx context.Context) (*model.AdminDashboard, error) {
+	BookCollection := database.DB.Collection("Books")
+	UserCollection := database.DB.Collection("Users")
+	ReservationCollection := database.DB.Collection("Reservations")
+
+	totalBooks, err := BookCollection.CountDocuments(ctx, bson.M{"deletedAt": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count books: %w", err)
+	}
+	totalUsers, err := UserCollection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	activeReservations, err := ReservationCollection.CountDocuments(ctx, bson.M{"status": bson.M{"$in": bson.A{"PENDING", "READY"}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reservations: %w", err)
+	}
+
+	loanStats, err := loanStatistics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topReviewers, err := topReviewers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := categoryDistribution(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mostViewed, err := mostViewedBooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	books := int(totalBooks)
+	users := int(totalUsers)
+	reservations := int(activeReservations)
+	return &model.AdminDashboard{
+		TotalBooks:           &books,
+		TotalUsers:           &users,
+		BorrowStats:          loanStats.borrowStats,
+		OverdueLoans:         &loanStats.overdue,
+		ActiveReservations:   &reservations,
+		MostBorrowedBooks:    loanStats.mostBorrowed,
+		MostViewedBooks:      mostViewed,
+		TopReviewers:         topReviewers,
+		CategoryDistribution: categories,
+		GeneratedAt:          time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+type loanStats struct {
+	borrowStats  *model.BorrowStats
+	overdue      int
+	mostBorrowed []*model.BookBorrowCount
+}
+
+// loanStatistics computes every loan based metric in a single $facet pass over Loans
+func loanStatistics(ctx context.Context) (*loanStats, error) {
+	now := time.Now()
+	countSince := func(since time.Time) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{"borrowedAt": bson.M{"$gte": since}}},
+			bson.M{"$count": "count"},
+		}
+	}
+
+	cursor, err := database.DB.Collection("Loans").Aggregate(ctx, bson.A{
+		bson.M{"$facet": bson.M{
+			"daily":   countSince(now.AddDate(0, 0, -1)),
+			"weekly":  countSince(now.AddDate(0, 0, -7)),
+			"monthly": countSince(now.AddDate(0, -1, 0)),
+			"overdue": bson.A{
+				bson.M{"$match": bson.M{"$or": bson.A{
+					bson.M{"status": "OVERDUE"},
+					bson.M{"status": "ACTIVE", "dueDate": bson.M{"$lt": now}},
+				}}},
+				bson.M{"$count": "count"},
+			},
+			// loans outlive purged books and deleted books are not shown,
+			// both are dropped before the list is cut
+			"mostBorrowed": bson.A{
+				bson.M{"$group": bson.M{"_id": "$bookId", "borrowCount": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "borrowCount", Value: -1}, {Key: "_id", Value: 1}}},
+				bson.M{"$limit": topListCandidates},
+				bson.M{"$lookup": bson.M{
+					"from":         "Books",
+					"localField":   "_id",
+					"foreignField": "_id",
+					"as":           "book",
+				}},
+				bson.M{"$match": bson.M{"book": bson.M{"$ne": bson.A{}}, "book.deletedAt": bson.M{"$exists": false}}},
+				bson.M{"$project": bson.M{"book": 0}},
+				bson.M{"$limit": topListSize},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate loans: %w", err)
+	}
+
+	type count struct {
+		Count int `bson:"count"`
+	}
+	var facets []struct {
+		Daily        []count                  `bson:"daily"`
+		Weekly       []count                  `bson:"weekly"`
+		Monthly      []count                  `bson:"monthly"`
+		Overdue      []count                  `bson:"overdue"`
+		MostBorrowed []*model.BookBorrowCount `bson:"mostBorrowed"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		return nil, fmt.Errorf("failed to decode loan statistics: %w", err)
+	}
+
+	first := func(counts []count) int {
+		if len(counts) == 0 {
+			return 0
+		}
+		return counts[0].Count
+	}
+
+	stats := &loanStats{
+		borrowStats:  &model.BorrowStats{},
+		mostBorrowed: []*model.BookBorrowCount{},
+	}
+	if len(facets) > 0 {
+		daily, weekly, monthly := first(facets[0].Daily), first(facets[0].Weekly), first(facets[0].Monthly)
+		stats.borrowStats = &model.BorrowStats{Daily: &daily, Weekly: &weekly, Monthly: &monthly}
+		stats.overdue = first(facets[0].Overdue)
+		if facets[0].MostBorrowed != nil {
+			stats.mostBorrowed = facets[0].MostBorrowed
+		}
+	}
+	return stats, nil
+}
+
+// mostViewedBooks ranks books by their views in BookViews, which keeps the
+// last 30 days, one document per reader, book and day
+func mostViewedBooks(ctx context.Context) ([]*model.BookViewCount, error) {
+	cursor, err := database.DB.Collection("BookViews").Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{
+			"_id": "$bookId",
+			// events from before views were counted per day stand for one
+			"viewCount": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$views", 1}}},
+			"viewers":   bson.M{"$addToSet": "$userId"},
+		}},
+		bson.M{"$project": bson.M{"viewCount": 1, "viewers": bson.M{"$size": "$viewers"}}},
+		bson.M{"$sort": bson.D{{Key: "viewCount", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": topListCandidates},
+		bson.M{"$lookup": bson.M{
+			"from":         "Books",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "book",
+		}},
+		bson.M{"$match": bson.M{"book": bson.M{"$ne": bson.A{}}, "book.deletedAt": bson.M{"$exists": false}}},
+		bson.M{"$project": bson.M{"book": 0}},
+		bson.M{"$limit": topListSize},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate book views: %w", err)
+	}
+
+	books := []*model.BookViewCount{}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, fmt.Errorf("failed to decode most viewed books: %w", err)
+	}
+	return books, nil
+}
+
+func topReviewers(ctx context.Context) ([]*model.ReviewerStat, error) {
+	cursor, err := database.DB.Collection("Reviews").Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{
+			"_id":           "$userId",
+			"reviewCount":   bson.M{"$sum": 1},
+			"averageRating": bson.M{"$avg": "$rating"},
+		}},
+		bson.M{"$sort": bson.D{{Key: "reviewCount", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": topListSize},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate reviews: %w", err)
+	}
+
+	reviewers := []*model.ReviewerStat{}
+	if err := cursor.All(ctx, &reviewers); err != nil {
+		return nil, fmt.Errorf("failed to decode top reviewers: %w", err)
+	}
+	return reviewers, nil
+}
+
+func categoryDistribution(ctx context.Context) ([]*model.CategoryCount, error) {
+	cursor, err := database.DB.Collection("Books").Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"deletedAt": bson.M{"$exists": false}}},
+		bson.M{"$group": bson.M{"_id": "$category", "count": bson.M{"$sum": 1}}},
+		bson.M{"$project": bson.M{"_id": 0, "category": "$_id", "count": 1}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "category", Value: 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate categories: %w", err)
+	}
+
+	categories := []*model.CategoryCount{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, fmt.Errorf("failed to decode category distribution: %w", err)
+	}
+	return categories, nil
+}
+
+// cacheTTL reads DASHBOARD_CACHE_TTL as a duration such as "30s"
+func cacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("DASHBOARD_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultCacheTTL
+	}
+	return ttl
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotDeleted matches the deletedAt of books that were not soft deleted
+var NotDeleted = bson.M{"$exists": false}
+
+// OrderedBooks fetches books in the order of ids without repeats, leaving out
+// deleted ones and any that do not match filter, which may be nil
+func OrderedBooks(ctx context.Context, ids []primitive.ObjectID, filter bson.M) ([]*model.Book, error) {
+	if len(ids) == 0 {
+		return []*model.Book{}, nil
+	}
+	match := bson.M{"_id": bson.M{"$in": ids}, "deletedAt": NotDeleted}
+	for field, value := range filter {
+		match[field] = value
+	}
+	cursor, err := DB.Collection("Books").Find(ctx, match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	var found []*model.Book
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+	byId := make(map[string]*model.Book, len(found))
+	for _, book := range found {
+		byId[book.ID] = book
+	}
+
+	books := []*model.Book{}
+	for _, id := range ids {
+		if book, ok := byId[id.Hex()]; ok {
+			books = append(books, book)
+			delete(byId, id.Hex())
+		}
+	}
+	return books, nil
+}
@@ -0,0 +1,12 @@
+package database
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// VersionFilter matches documents at version for optimistic concurrency checks.
+// Documents written before versions were tracked have none and count as version 0.
+func VersionFilter(version int) bson.M {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return bson.M{"$eq": version}
+}
@@ -0,0 +1,97 @@
+package delivery
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one connection and answers it with serve
+func fakeSMTP(t *testing.T, serve func(conn net.Conn)) *EmailNotifier {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return &EmailNotifier{Host: host, Port: port, From: "library@example.com"}
+}
+
+func TestEmailSend(t *testing.T) {
+	received := make(chan string, 1)
+	n := fakeSMTP(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 fake ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 queued")
+				} else {
+					data.WriteString(line)
+				}
+				continue
+			}
+			switch strings.ToUpper(strings.Fields(line)[0]) {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 ok")
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("500 unknown")
+			}
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := n.Send(ctx, Message{To: "reader@example.com", Subject: "Due soon", Body: "Your book is due tomorrow."})
+	if err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+	body := <-received
+	if !strings.Contains(body, "Subject: Due soon") || !strings.Contains(body, "Your book is due tomorrow.") {
+		t.Errorf("unexpected message %q", body)
+	}
+}
+
+func TestEmailSendGivesUpOnSilentServer(t *testing.T) {
+	n := fakeSMTP(t, func(conn net.Conn) {
+		// never greet the client
+		time.Sleep(5 * time.Second)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Send(ctx, Message{To: "reader@example.com"}); err == nil {
+		t.Fatal("Send to a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %s, want it bounded by the context", elapsed)
+	}
+}
@@ -0,0 +1,52 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogNotifier is a development sink that appends messages to a file as JSON
+// lines, or writes them to the server log when no file is set
+type LogNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewLogNotifier(path string) *LogNotifier {
+	return &LogNotifier{path: path}
+}
+
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	entry, err := json.Marshal(struct {
+		Message
+		To     string `json:"to"`
+		SentAt string `json:"sentAt"`
+	}{msg, msg.To, time.Now().Format(time.RFC3339)})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	if n.path == "" {
+		log.Printf("notification: %s", entry)
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open notification log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(entry, '\n')); err != nil {
+		return fmt.Errorf("failed to write notification log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"bmsgql/graph/model"
+	"context"
+	"errors"
+	"os"
+)
+
+var ErrNoRecipient = errors.New("no recipient address for this channel")
+
+// Message is a rendered notification ready to be sent over a channel
+type Message struct {
+	NotificationID string `json:"id"`
+	Type           string `json:"type"`
+	To             string `json:"-"`
+	Subject        string `json:"subject"`
+	Body           string `json:"message"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+// Notifier sends messages over one delivery channel
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NotifiersFromEnv builds the notifier of every channel from the environment.
+// With NOTIFICATION_TRANSPORT=log, or without SMTP_HOST for email, messages go
+// to the development sink instead of leaving the machine.
+func NotifiersFromEnv() map[model.NotificationChannel]Notifier {
+	sink := NewLogNotifier(os.Getenv("NOTIFICATION_LOG_FILE"))
+	if os.Getenv("NOTIFICATION_TRANSPORT") == "log" {
+		return map[model.NotificationChannel]Notifier{
+			model.NotificationChannelEmail:   sink,
+			model.NotificationChannelWebhook: sink,
+		}
+	}
+
+	var email Notifier = sink
+	if os.Getenv("SMTP_HOST") != "" {
+		email = NewEmailNotifierFromEnv()
+	}
+
+	return map[model.NotificationChannel]Notifier{
+		model.NotificationChannelEmail:   email,
+		model.NotificationChannelWebhook: NewWebhookNotifier(os.Getenv("WEBHOOK_SECRET")),
+	}
+}
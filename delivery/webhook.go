@@ -0,0 +1,141 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenURL is returned for webhook URLs the server will not call. Readers
+// choose the URL, so only public HTTPS endpoints are allowed, anything else
+// would let them reach the server's own network.
+var ErrForbiddenURL = errors.New("webhook URL must be a public https URL")
+
+// WebhookNotifier posts messages as JSON to the recipient's URL. When a secret is
+// set the body is signed with HMAC-SHA256 in the X-Signature-256 header.
+type WebhookNotifier struct {
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(secret string) *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicOnly}
+	return &WebhookNotifier{
+		Secret: secret,
+		Client: &http.Client{
+			Timeout: 10 * time.Second,
+			// no proxy from the environment, the dialer has to see the
+			// address of the endpoint itself
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return errors.New("too many webhook redirects")
+				}
+				return CheckWebhookURL(req.URL.String())
+			},
+		},
+	}
+}
+
+// CheckWebhookURL rejects URLs that are not https or name a host that is
+// obviously internal. Hostnames can resolve anywhere, the notifier checks the
+// address again when it connects.
+func CheckWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return ErrForbiddenURL
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenURL
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !public(addr) {
+		return ErrForbiddenURL
+	}
+	return nil
+}
+
+// publicOnly is a net.Dialer Control function refusing to connect to anything
+// but public addresses. It runs after DNS resolution, so hostnames pointing
+// inside the network are caught too.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenURL, err)
+	}
+	if !public(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s is not a public address", ErrForbiddenURL, addrPort.Addr())
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, not routable on the
+// internet either
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+func (n *WebhookNotifier) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return ErrNoRecipient
+	}
+	// URLs saved before they were checked
+	if err := CheckWebhookURL(msg.To); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.To, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(payload)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,508 @@
+package discussions
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/notifications"
+	"bmsgql/paging"
+	"bmsgql/pubsub"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func CreateDiscussion(ctx context.Context, input model.DiscussionInput) (*model.Discussion, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+	BookCollection := database.DB.Collection("Books")
+
+	userObjId, _, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(input.Title) == "" || strings.TrimSpace(input.Content) == "" {
+		return nil, fmt.Errorf("title and content are required")
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	newDiscussion := bson.M{
+		"title":     input.Title,
+		"category":  input.Category,
+		"content":   input.Content,
+		"replies":   bson.A{},
+		"pinned":    false,
+		"locked":    false,
+		"createdBy": userObjId,
+		"createdAt": now,
+		"updatedAt": now,
+		"version":   1,
+	}
+
+	var bookId *primitive.ObjectID
+	if input.BookID != nil {
+		id, err := primitive.ObjectIDFromHex(*input.BookID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid book ID")
+		}
+		count, err := BookCollection.CountDocuments(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$exists": false}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up book: %w", err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("book not found")
+		}
+		bookId = &id
+		newDiscussion["bookId"] = id
+	}
+
+	result, err := DiscussionCollection.InsertOne(ctx, newDiscussion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discussion: %w", err)
+	}
+
+	insertedId := result.InsertedID.(primitive.ObjectID)
+
+	return &model.Discussion{
+		ID:          insertedId.Hex(),
+		Title:       input.Title,
+		Category:    input.Category,
+		Content:     input.Content,
+		Replies:     []*model.DiscussionReply{},
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+		BookID:      bookId,
+		CreatedByID: userObjId,
+		Version:     1,
+	}, nil
+}
+
+func GetDiscussion(ctx context.Context, id string) (*model.Discussion, error) {
+	if _, _, err := currentUser(ctx); err != nil {
+		return nil, err
+	}
+	return findDiscussion(ctx, id)
+}
+
+// CommunityDiscussions lists discussions, pinned threads first and then newest first
+func CommunityDiscussions(ctx context.Context, category *string, bookID *string, limit *int, offset *int) ([]*model.Discussion, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	if _, _, err := currentUser(ctx); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{}
+	if category != nil && *category != "" {
+		filter["category"] = *category
+	}
+	if bookID != nil {
+		bookId, err := primitive.ObjectIDFromHex(*bookID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid book ID")
+		}
+		filter["bookId"] = bookId
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "pinned", Value: -1}, {Key: "createdAt", Value: -1}}).
+		SetSkip(int64(paging.Offset(offset))).
+		SetLimit(int64(paging.Limit(limit)))
+
+	discussions := []*model.Discussion{}
+	cursor, err := DiscussionCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discussions: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var discussion model.Discussion
+		if err := cursor.Decode(&discussion); err != nil {
+			return nil, fmt.Errorf("failed to decode discussion: %w", err)
+		}
+		discussions = append(discussions, &discussion)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+	return discussions, nil
+}
+
+// EditDiscussion updates a thread, only its author can edit it. The edit fails with
+// a CONFLICT error unless the thread is still at expectedVersion.
+func EditDiscussion(ctx context.Context, id string, input model.EditDiscussionInput, expectedVersion int) (*model.Discussion, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	userObjId, accountType, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discussion, err := findDiscussion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if discussion.CreatedByID != userObjId {
+		return nil, fmt.Errorf("access denied: only the author can edit this discussion")
+	}
+	if discussion.Locked && accountType != "ADMIN" {
+		return nil, fmt.Errorf("discussion is locked")
+	}
+
+	updateDiscussion := bson.M{"updatedAt": time.Now().Format(time.RFC3339)}
+	if input.Title != nil {
+		updateDiscussion["title"] = input.Title
+	}
+	if input.Category != nil {
+		updateDiscussion["category"] = input.Category
+	}
+	if input.Content != nil {
+		updateDiscussion["content"] = input.Content
+	}
+
+	if discussion.Version != expectedVersion {
+		return nil, versionConflict(discussion.Version)
+	}
+
+	discussionId, _ := primitive.ObjectIDFromHex(id)
+	var updated model.Discussion
+	err = DiscussionCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": discussionId, "version": database.VersionFilter(expectedVersion)},
+		bson.M{"$set": updateDiscussion, "$inc": bson.M{"version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			current, err := findDiscussion(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return nil, versionConflict(current.Version)
+		}
+		return nil, fmt.Errorf("failed to update discussion: %w", err)
+	}
+
+	audit.Track(ctx, "Discussion", id, discussion, &updated)
+	return &updated, nil
+}
+
+// DeleteDiscussion removes a thread with all its replies, the author or an ADMIN can delete it
+func DeleteDiscussion(ctx context.Context, id string) (bool, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	userObjId, accountType, err := currentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	discussion, err := findDiscussion(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if discussion.CreatedByID != userObjId && accountType != "ADMIN" {
+		return false, fmt.Errorf("access denied: only the author can delete this discussion")
+	}
+
+	discussionId, _ := primitive.ObjectIDFromHex(id)
+	_, err = DiscussionCollection.DeleteOne(ctx, bson.M{"_id": discussionId})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete discussion: %w", err)
+	}
+
+	audit.Track(ctx, "Discussion", id, discussion, nil)
+	return true, nil
+}
+
+// ReplyToDiscussion adds a reply to a thread, parentID nests it under another reply
+func ReplyToDiscussion(ctx context.Context, discussionID string, content string, parentID *string) (*model.Discussion, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	userObjId, _, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("reply content is required")
+	}
+
+	discussion, err := findDiscussion(ctx, discussionID)
+	if err != nil {
+		return nil, err
+	}
+	if discussion.Locked {
+		return nil, fmt.Errorf("discussion is locked")
+	}
+	if parentID != nil {
+		parent := findReply(discussion, *parentID)
+		if parent == nil {
+			return nil, fmt.Errorf("parent reply not found")
+		}
+		if parent.Deleted {
+			return nil, fmt.Errorf("cannot reply to a deleted reply")
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	replyId := primitive.NewObjectID()
+	reply := bson.M{
+		"_id":       replyId,
+		"content":   content,
+		"parentId":  parentID,
+		"deleted":   false,
+		"createdBy": userObjId,
+		"createdAt": now,
+		"updatedAt": now,
+	}
+
+	discussionId, _ := primitive.ObjectIDFromHex(discussionID)
+	_, err = DiscussionCollection.UpdateOne(ctx, bson.M{"_id": discussionId}, bson.M{
+		"$push": bson.M{"replies": reply},
+		"$set":  bson.M{"updatedAt": now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add reply: %w", err)
+	}
+
+	pubsub.Publish(pubsub.DiscussionTopic(discussionID), &model.DiscussionReply{
+		ID:          replyId.Hex(),
+		Content:     content,
+		ParentID:    parentID,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+		CreatedByID: userObjId,
+	})
+	notifyParticipants(ctx, discussion, parentID, userObjId)
+
+	return findDiscussion(ctx, discussionID)
+}
+
+// ReplyAdded streams new replies to a discussion until ctx is done
+func ReplyAdded(ctx context.Context, discussionID string) (<-chan *model.DiscussionReply, error) {
+	if _, _, err := currentUser(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := findDiscussion(ctx, discussionID); err != nil {
+		return nil, err
+	}
+	return pubsub.Stream[*model.DiscussionReply](ctx, pubsub.DiscussionTopic(discussionID)), nil
+}
+
+// EditDiscussionReply updates a reply, only its author can edit it
+func EditDiscussionReply(ctx context.Context, discussionID string, replyID string, content string) (*model.Discussion, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	userObjId, accountType, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("reply content is required")
+	}
+
+	discussion, err := findDiscussion(ctx, discussionID)
+	if err != nil {
+		return nil, err
+	}
+	if discussion.Locked && accountType != "ADMIN" {
+		return nil, fmt.Errorf("discussion is locked")
+	}
+
+	reply := findReply(discussion, replyID)
+	if reply == nil || reply.Deleted {
+		return nil, fmt.Errorf("reply not found")
+	}
+	if reply.CreatedByID != userObjId {
+		return nil, fmt.Errorf("access denied: only the author can edit this reply")
+	}
+
+	discussionId, _ := primitive.ObjectIDFromHex(discussionID)
+	replyId, _ := primitive.ObjectIDFromHex(replyID)
+	now := time.Now().Format(time.RFC3339)
+	_, err = DiscussionCollection.UpdateOne(ctx,
+		bson.M{"_id": discussionId, "replies._id": replyId},
+		bson.M{"$set": bson.M{
+			"replies.$.content":   content,
+			"replies.$.updatedAt": now,
+		}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update reply: %w", err)
+	}
+
+	return findDiscussion(ctx, discussionID)
+}
+
+// DeleteDiscussionReply deletes a reply, the author or an ADMIN can delete it.
+// Replies that others answered are blanked out instead so the thread stays intact.
+func DeleteDiscussionReply(ctx context.Context, discussionID string, replyID string) (bool, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	userObjId, accountType, err := currentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	discussion, err := findDiscussion(ctx, discussionID)
+	if err != nil {
+		return false, err
+	}
+
+	reply := findReply(discussion, replyID)
+	if reply == nil || reply.Deleted {
+		return false, fmt.Errorf("reply not found")
+	}
+	if reply.CreatedByID != userObjId && accountType != "ADMIN" {
+		return false, fmt.Errorf("access denied: only the author can delete this reply")
+	}
+
+	hasChildren := false
+	for _, r := range discussion.Replies {
+		if r.ParentID != nil && *r.ParentID == replyID {
+			hasChildren = true
+			break
+		}
+	}
+
+	discussionId, _ := primitive.ObjectIDFromHex(discussionID)
+	replyId, _ := primitive.ObjectIDFromHex(replyID)
+	if hasChildren {
+		_, err = DiscussionCollection.UpdateOne(ctx,
+			bson.M{"_id": discussionId, "replies._id": replyId},
+			bson.M{"$set": bson.M{
+				"replies.$.content":   "[deleted]",
+				"replies.$.deleted":   true,
+				"replies.$.updatedAt": time.Now().Format(time.RFC3339),
+			}},
+		)
+	} else {
+		_, err = DiscussionCollection.UpdateOne(ctx,
+			bson.M{"_id": discussionId},
+			bson.M{"$pull": bson.M{"replies": bson.M{"_id": replyId}}},
+		)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete reply: %w", err)
+	}
+
+	return true, nil
+}
+
+// PinDiscussion pins or unpins a thread, only ADMIN can do this
+func PinDiscussion(ctx context.Context, id string, pinned bool) (*model.Discussion, error) {
+	return setModerationFlag(ctx, id, "pinned", pinned)
+}
+
+// LockDiscussion locks or unlocks a thread for new replies, only ADMIN can do this
+func LockDiscussion(ctx context.Context, id string, locked bool) (*model.Discussion, error) {
+	return setModerationFlag(ctx, id, "locked", locked)
+}
+
+func setModerationFlag(ctx context.Context, id string, flag string, value bool) (*model.Discussion, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	_, accountType, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	discussionId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discussion ID")
+	}
+
+	before, err := findDiscussion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := DiscussionCollection.UpdateOne(ctx, bson.M{"_id": discussionId}, bson.M{"$set": bson.M{flag: value}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update discussion: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("discussion not found")
+	}
+
+	discussion, err := findDiscussion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	audit.Track(ctx, "Discussion", id, before, discussion)
+	return discussion, nil
+}
+
+// notifyParticipants tells the thread author and the author of the reply being
+// answered about a new reply, failures are logged so they never fail the reply itself
+func notifyParticipants(ctx context.Context, discussion *model.Discussion, parentID *string, replier primitive.ObjectID) {
+	recipients := []primitive.ObjectID{discussion.CreatedByID}
+	if parentID != nil {
+		if parent := findReply(discussion, *parentID); parent != nil && parent.CreatedByID != discussion.CreatedByID {
+			recipients = append(recipients, parent.CreatedByID)
+		}
+	}
+
+	message := fmt.Sprintf("New reply in the discussion \"%s\"", discussion.Title)
+	for _, recipient := range recipients {
+		if recipient == replier {
+			continue
+		}
+		if _, err := notifications.Notify(ctx, recipient, notifications.TypeCommunityActivity, message); err != nil {
+			log.Printf("failed to notify %s about discussion %s: %v", recipient.Hex(), discussion.ID, err)
+		}
+	}
+}
+
+func currentUser(ctx context.Context) (primitive.ObjectID, string, error) {
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return primitive.NilObjectID, "", err
+	}
+	accountType, _ := auth.GetAccountType(ctx)
+	return userObjId, accountType, nil
+}
+
+func findDiscussion(ctx context.Context, id string) (*model.Discussion, error) {
+	DiscussionCollection := database.DB.Collection("Discussions")
+
+	discussionId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discussion ID")
+	}
+
+	var discussion model.Discussion
+	err = DiscussionCollection.FindOne(ctx, bson.M{"_id": discussionId}).Decode(&discussion)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("discussion not found")
+		}
+		return nil, fmt.Errorf("failed to find discussion: %w", err)
+	}
+	return &discussion, nil
+}
+
+func versionConflict(current int) error {
+	return errcode.Errorf(errcode.Conflict, "discussion was modified by someone else and is now at version %d, reload it and try again", current)
+}
+
+func findReply(discussion *model.Discussion, replyID string) *model.DiscussionReply {
+	for _, reply := range discussion.Replies {
+		if reply != nil && reply.ID == replyID {
+			return reply
+		}
+	}
+	return nil
+}
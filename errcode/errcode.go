@@ -0,0 +1,29 @@
+// Package errcode attaches machine readable codes to resolver errors. Clients
+// read them from the "code" extension of the GraphQL error.
+package errcode
+
+import (
+	"fmt"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+type Code string
+
+const (
+	// Conflict means the document changed since the client last read it, or
+	// would clash with another one
+	Conflict Code = "CONFLICT"
+	// BadUserInput means an argument failed validation
+	BadUserInput Code = "BAD_USER_INPUT"
+	// NotFound means the requested record does not exist
+	NotFound Code = "NOT_FOUND"
+)
+
+// Errorf formats an error carrying code
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &gqlerror.Error{
+		Message:    fmt.Sprintf(format, args...),
+		Extensions: map[string]interface{}{"code": string(code)},
+	}
+}
@@ -0,0 +1,251 @@
+package exports
+
+import (
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Format is a catalogue export format
+type Format string
+
+const (
+	FormatCSV       Format = "csv"
+	FormatJSONLines Format = "jsonl"
+	FormatONIX      Format = "onix"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	exportBatchSize = 500
+)
+
+// ErrUnknownFormat is returned for a format other than csv, jsonl or onix
+var ErrUnknownFormat = errors.New("unsupported format, use csv, jsonl or onix")
+
+// ParseFormat reads a format name, csv when empty
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatJSONLines, FormatONIX:
+		return format, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ContentType is the media type an export in this format is served as
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSONLines:
+		return "application/x-ndjson"
+	case FormatONIX:
+		return "application/xml"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension is the file extension of an export in this format
+func (f Format) Extension() string {
+	if f == FormatONIX {
+		return "xml"
+	}
+	return string(f)
+}
+
+// Filter restricts an export to a category and to books added in a date range
+type Filter struct {
+	Category *model.BookCategory
+	From     *time.Time
+	To       *time.Time
+}
+
+// ParseFilter reads the filter values of the export endpoint and command. Dates
+// are RFC3339 or plain dates, a plain to date includes the whole day.
+func ParseFilter(category, from, to string) (Filter, error) {
+	var filter Filter
+	if category != "" {
+		bookCategory := model.BookCategory(strings.ToUpper(category))
+		if !bookCategory.IsValid() {
+			return filter, fmt.Errorf("unknown category %q", category)
+		}
+		filter.Category = &bookCategory
+	}
+	if from != "" {
+		t, err := parseDate(from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date: %w", err)
+		}
+		filter.From = &t
+	}
+	if to != "" {
+		t, err := parseDate(to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date: %w", err)
+		}
+		if len(to) == len(dateLayout) {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		filter.To = &t
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return filter, fmt.Errorf("to date is before from date")
+	}
+	return filter, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, value)
+}
+
+// Record is a book as exported. Every book document is one copy on the shelf, so
+// Copies is 1 and AvailableCopies tells whether it can be borrowed right now.
+type Record struct {
+	ID              string                 `json:"id" bson:"-"`
+	ObjectID        primitive.ObjectID     `json:"-" bson:"_id"`
+	Isbn            string                 `json:"isbn" bson:"isbn"`
+	Title           string                 `json:"title" bson:"title"`
+	Author          string                 `json:"author" bson:"author"`
+	Contributors    []Contributor          `json:"contributors" bson:"-"`
+	Category        model.BookCategory     `json:"category" bson:"category"`
+	Description     string                 `json:"description" bson:"description"`
+	CoverImage      string                 `json:"coverImage" bson:"coverImage"`
+	Availability    model.BookAvailability `json:"availability" bson:"availability"`
+	Copies          int                    `json:"copies" bson:"-"`
+	AvailableCopies int                    `json:"availableCopies" bson:"-"`
+	AverageRating   float64                `json:"averageRating" bson:"averageRating"`
+	RatingCount     int                    `json:"ratingCount" bson:"ratingCount"`
+	AddedAt         string                 `json:"addedAt" bson:"-"`
+
+	BookContributors []*model.BookContributor `json:"-" bson:"contributors"`
+	AuthorNames      []struct {
+		ID   primitive.ObjectID `bson:"_id"`
+		Name string             `bson:"name"`
+	} `json:"-" bson:"authorNames"`
+}
+
+// Contributor is a person credited on an exported book
+type Contributor struct {
+	Name string                `json:"name"`
+	Role model.ContributorRole `json:"role"`
+}
+
+// recordWriter encodes records one at a time so exports stream instead of
+// building the whole catalogue in memory
+type recordWriter interface {
+	write(record *Record) error
+	close() error
+}
+
+// Export writes every book matching filter to w and returns how many were
+// written. Deleted books are never exported.
+func Export(ctx context.Context, w io.Writer, format Format, filter Filter) (int, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	var writer recordWriter
+	switch format {
+	case FormatCSV:
+		writer = newCSVWriter(w)
+	case FormatJSONLines:
+		writer = newJSONLinesWriter(w)
+	case FormatONIX:
+		writer = newONIXWriter(w)
+	default:
+		return 0, ErrUnknownFormat
+	}
+
+	cursor, err := BookCollection.Aggregate(ctx, pipeline(filter), options.Aggregate().SetBatchSize(exportBatchSize).SetAllowDiskUse(true))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query books: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var record Record
+		if err := cursor.Decode(&record); err != nil {
+			return count, fmt.Errorf("failed to decode book: %w", err)
+		}
+		record.ID = record.ObjectID.Hex()
+		record.AddedAt = record.ObjectID.Timestamp().UTC().Format(time.RFC3339)
+		record.Copies = 1
+		record.Contributors = []Contributor{}
+		for _, contributor := range record.BookContributors {
+			for _, author := range record.AuthorNames {
+				if author.ID == contributor.AuthorID {
+					record.Contributors = append(record.Contributors, Contributor{Name: author.Name, Role: contributor.Role})
+				}
+			}
+		}
+		if record.Availability == model.BookAvailabilityAvailable {
+			record.AvailableCopies = 1
+		}
+		if err := writer.write(&record); err != nil {
+			return count, fmt.Errorf("failed to write book %s: %w", record.ID, err)
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, fmt.Errorf("failed to read books: %w", err)
+	}
+	if err := writer.close(); err != nil {
+		return count, fmt.Errorf("failed to finish export: %w", err)
+	}
+	return count, nil
+}
+
+// pipeline selects the books to export in the order they were added, with the
+// names of their contributors and their ratings taken from the reviews
+func pipeline(filter Filter) mongo.Pipeline {
+	match := bson.M{"deletedAt": bson.M{"$exists": false}}
+	if filter.Category != nil {
+		match["category"] = *filter.Category
+	}
+	// books have no creation date of their own, the ObjectID records when they
+	// were added to the second
+	added := bson.M{}
+	if filter.From != nil {
+		added["$gte"] = primitive.NewObjectIDFromTimestamp(*filter.From)
+	}
+	if filter.To != nil {
+		added["$lt"] = primitive.NewObjectIDFromTimestamp(filter.To.Add(time.Second))
+	}
+	if len(added) > 0 {
+		match["_id"] = added
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "Authors",
+			"localField":   "contributors.authorId",
+			"foreignField": "_id",
+			"as":           "authorNames",
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "Reviews",
+			"localField":   "_id",
+			"foreignField": "bookId",
+			"as":           "ratings",
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"averageRating": bson.M{"$ifNull": bson.A{bson.M{"$avg": "$ratings.rating"}, 0}},
+			"ratingCount":   bson.M{"$size": "$ratings"},
+		}}},
+		{{Key: "$project", Value: bson.M{"ratings": 0, "reviews": 0, "authorNames.bio": 0, "authorNames.aliases": 0, "authorNames.nameKeys": 0}}},
+	}
+}
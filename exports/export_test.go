@@ -0,0 +1,76 @@
+package exports
+
+import (
+	"bmsgql/graph/model"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	date := func(value string) *time.Time {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			panic(err)
+		}
+		return &t
+	}
+	fantasy := model.BookCategoryFantasy
+	tests := []struct {
+		category, from, to string
+		want               Filter
+		wantErr            bool
+	}{
+		{"", "", "", Filter{}, false},
+		{"fantasy", "", "", Filter{Category: &fantasy}, false},
+		{"FANTASY", "", "", Filter{Category: &fantasy}, false},
+		{"poetry", "", "", Filter{}, true},
+		// a plain to date includes the whole day
+		{"", "2024-01-01", "2024-12-31", Filter{From: date("2024-01-01T00:00:00Z"), To: date("2024-12-31T23:59:59.999999999Z")}, false},
+		{"", "2024-01-01T10:00:00Z", "2024-01-01T12:00:00Z", Filter{From: date("2024-01-01T10:00:00Z"), To: date("2024-01-01T12:00:00Z")}, false},
+		{"", "2024-03-01", "", Filter{From: date("2024-03-01T00:00:00Z")}, false},
+		{"", "", "2024-03-01", Filter{To: date("2024-03-01T23:59:59.999999999Z")}, false},
+		// the same day is a valid range
+		{"", "2024-03-01", "2024-03-01", Filter{From: date("2024-03-01T00:00:00Z"), To: date("2024-03-01T23:59:59.999999999Z")}, false},
+		{"", "2024-12-31", "2024-01-01", Filter{}, true},
+		{"", "yesterday", "", Filter{}, true},
+		{"", "", "2024-13-01", Filter{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFilter(tt.category, tt.from, tt.to)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseFilter(%q, %q, %q) = %+v, want an error", tt.category, tt.from, tt.to, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFilter(%q, %q, %q) = %v", tt.category, tt.from, tt.to, err)
+			continue
+		}
+		if !sameCategory(got.Category, tt.want.Category) || !sameTime(got.From, tt.want.From) || !sameTime(got.To, tt.want.To) {
+			t.Errorf("ParseFilter(%q, %q, %q) = %s, want %s", tt.category, tt.from, tt.to, describe(got), describe(tt.want))
+		}
+	}
+}
+
+func sameCategory(a, b *model.BookCategory) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func sameTime(a, b *time.Time) bool {
+	return a == nil && b == nil || a != nil && b != nil && a.Equal(*b)
+}
+
+func describe(filter Filter) string {
+	s := "{"
+	if filter.Category != nil {
+		s += " category " + filter.Category.String()
+	}
+	if filter.From != nil {
+		s += " from " + filter.From.Format(time.RFC3339Nano)
+	}
+	if filter.To != nil {
+		s += " to " + filter.To.Format(time.RFC3339Nano)
+	}
+	return s + " }"
+}
@@ -0,0 +1,58 @@
+package exports
+
+import (
+	"bmsgql/auth"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Handler streams the catalogue at /export/books. The query takes format (csv,
+// jsonl or onix), category, and from and to dates bounding when books were
+// added. It must run behind auth.AuthMiddleware, only ADMIN may export.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		accountType, ok := auth.GetAccountType(r.Context())
+		if !ok || accountType != "ADMIN" {
+			writeError(w, http.StatusForbidden, "access denied: only users with an account type of ADMIN can access this")
+			return
+		}
+
+		query := r.URL.Query()
+		format, err := ParseFormat(query.Get("format"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter, err := ParseFilter(query.Get("category"), query.Get("from"), query.Get("to"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		filename := fmt.Sprintf("catalogue-%s.%s", time.Now().Format(dateLayout), format.Extension())
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		// the status is sent with the first record, a failure after that can only
+		// cut the download short
+		count, err := Export(r.Context(), w, format, filter)
+		if err != nil {
+			log.Printf("exports: export stopped after %d book(s): %v", count, err)
+		}
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+}
@@ -0,0 +1,239 @@
+package exports
+
+import (
+	"bmsgql/graph/model"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const onixNamespace = "http://ns.editeur.org/onix/3.0/reference"
+
+// ONIX 3.0 code list values used by the export
+const (
+	notificationConfirmed = "03"  // list 1, confirmed record
+	productIDISBN13       = "15"  // list 5
+	productSingleItem     = "00"  // list 2
+	productFormBook       = "BA"  // list 150
+	titleDistinctive      = "01"  // list 15
+	titleLevelProduct     = "01"  // list 149
+	contributorByAuthor   = "A01" // list 17
+	contributorEditedBy   = "B01"
+	contributorTranslated = "B06"
+	subjectKeywords       = "20" // list 27
+	textDescription       = "03" // list 153
+	audienceUnrestricted  = "00" // list 154
+	resourceFrontCover    = "01" // list 158
+	resourceImage         = "03" // list 159
+	resourceLinkable      = "02" // list 161
+	supplierPublisher     = "00" // list 93, unspecified
+	availableInStock      = "21" // list 65
+	outOfStock            = "31"
+	notAvailable          = "40"
+	unpricedContact       = "04" // list 57, contact supplier
+)
+
+type onixProduct struct {
+	XMLName           xml.Name `xml:"Product"`
+	RecordReference   string   `xml:"RecordReference"`
+	NotificationType  string   `xml:"NotificationType"`
+	ProductIdentifier struct {
+		ProductIDType string `xml:"ProductIDType"`
+		IDValue       string `xml:"IDValue"`
+	} `xml:"ProductIdentifier"`
+	DescriptiveDetail struct {
+		ProductComposition string `xml:"ProductComposition"`
+		ProductForm        string `xml:"ProductForm"`
+		TitleDetail        struct {
+			TitleType    string `xml:"TitleType"`
+			TitleElement struct {
+				TitleElementLevel string `xml:"TitleElementLevel"`
+				TitleText         string `xml:"TitleText"`
+			} `xml:"TitleElement"`
+		} `xml:"TitleDetail"`
+		Contributors []onixContributor `xml:"Contributor"`
+		Subject      struct {
+			SubjectSchemeIdentifier string `xml:"SubjectSchemeIdentifier"`
+			SubjectHeadingText      string `xml:"SubjectHeadingText"`
+		} `xml:"Subject"`
+	} `xml:"DescriptiveDetail"`
+	CollateralDetail *onixCollateral `xml:"CollateralDetail,omitempty"`
+	ProductSupply    struct {
+		SupplyDetail struct {
+			Supplier struct {
+				SupplierRole string `xml:"SupplierRole"`
+				SupplierName string `xml:"SupplierName"`
+			} `xml:"Supplier"`
+			ProductAvailability string `xml:"ProductAvailability"`
+			Stock               struct {
+				OnHand int `xml:"OnHand"`
+			} `xml:"Stock"`
+			UnpricedItemType string `xml:"UnpricedItemType"`
+		} `xml:"SupplyDetail"`
+	} `xml:"ProductSupply"`
+}
+
+type onixContributor struct {
+	SequenceNumber  int    `xml:"SequenceNumber"`
+	ContributorRole string `xml:"ContributorRole"`
+	PersonName      string `xml:"PersonName"`
+}
+
+type onixCollateral struct {
+	TextContent        *onixTextContent        `xml:"TextContent,omitempty"`
+	SupportingResource *onixSupportingResource `xml:"SupportingResource,omitempty"`
+}
+
+type onixTextContent struct {
+	TextType        string `xml:"TextType"`
+	ContentAudience string `xml:"ContentAudience"`
+	Text            string `xml:"Text"`
+}
+
+type onixSupportingResource struct {
+	ResourceContentType string `xml:"ResourceContentType"`
+	ContentAudience     string `xml:"ContentAudience"`
+	ResourceMode        string `xml:"ResourceMode"`
+	ResourceVersion     struct {
+		ResourceForm string `xml:"ResourceForm"`
+		ResourceLink string `xml:"ResourceLink"`
+	} `xml:"ResourceVersion"`
+}
+
+// onixWriter writes an ONIX 3.0 reference message, one Product per book. ONIX
+// has no place for reader ratings, they are only in the CSV and JSON Lines
+// exports.
+type onixWriter struct {
+	w       io.Writer
+	encoder *xml.Encoder
+	sender  string
+	started bool
+}
+
+func newONIXWriter(w io.Writer) *onixWriter {
+	sender := os.Getenv("ONIX_SENDER_NAME")
+	if sender == "" {
+		sender = "Library"
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &onixWriter{w: w, encoder: encoder, sender: sender}
+}
+
+func (o *onixWriter) start() error {
+	o.started = true
+	var header struct {
+		XMLName xml.Name `xml:"Header"`
+		Sender  struct {
+			SenderName string `xml:"SenderName"`
+		} `xml:"Sender"`
+		SentDateTime string `xml:"SentDateTime"`
+	}
+	header.Sender.SenderName = o.sender
+	header.SentDateTime = time.Now().UTC().Format("20060102T150405Z")
+
+	if _, err := io.WriteString(o.w, xml.Header+`<ONIXMessage release="3.0" xmlns="`+onixNamespace+`">`+"\n"); err != nil {
+		return err
+	}
+	return o.encoder.Encode(header)
+}
+
+func (o *onixWriter) write(record *Record) error {
+	if !o.started {
+		if err := o.start(); err != nil {
+			return err
+		}
+	}
+
+	var product onixProduct
+	product.RecordReference = record.ID
+	product.NotificationType = notificationConfirmed
+	product.ProductIdentifier.ProductIDType = productIDISBN13
+	product.ProductIdentifier.IDValue = record.Isbn
+
+	detail := &product.DescriptiveDetail
+	detail.ProductComposition = productSingleItem
+	detail.ProductForm = productFormBook
+	detail.TitleDetail.TitleType = titleDistinctive
+	detail.TitleDetail.TitleElement.TitleElementLevel = titleLevelProduct
+	detail.TitleDetail.TitleElement.TitleText = record.Title
+	for i, contributor := range record.Contributors {
+		detail.Contributors = append(detail.Contributors, onixContributor{
+			SequenceNumber:  i + 1,
+			ContributorRole: contributorRole(contributor.Role),
+			PersonName:      contributor.Name,
+		})
+	}
+	// books not linked to authors yet only have the author string
+	if len(detail.Contributors) == 0 && record.Author != "" {
+		detail.Contributors = append(detail.Contributors, onixContributor{SequenceNumber: 1, ContributorRole: contributorByAuthor, PersonName: record.Author})
+	}
+	detail.Subject.SubjectSchemeIdentifier = subjectKeywords
+	detail.Subject.SubjectHeadingText = categoryName(record.Category)
+
+	if record.Description != "" || record.CoverImage != "" {
+		product.CollateralDetail = &onixCollateral{}
+		if record.Description != "" {
+			product.CollateralDetail.TextContent = &onixTextContent{textDescription, audienceUnrestricted, record.Description}
+		}
+		if record.CoverImage != "" {
+			resource := &onixSupportingResource{ResourceContentType: resourceFrontCover, ContentAudience: audienceUnrestricted, ResourceMode: resourceImage}
+			resource.ResourceVersion.ResourceForm = resourceLinkable
+			resource.ResourceVersion.ResourceLink = record.CoverImage
+			product.CollateralDetail.SupportingResource = resource
+		}
+	}
+
+	supply := &product.ProductSupply.SupplyDetail
+	supply.Supplier.SupplierRole = supplierPublisher
+	supply.Supplier.SupplierName = o.sender
+	supply.ProductAvailability = productAvailability(record.Availability)
+	supply.Stock.OnHand = record.AvailableCopies
+	supply.UnpricedItemType = unpricedContact
+
+	return o.encoder.Encode(product)
+}
+
+func (o *onixWriter) close() error {
+	if !o.started {
+		if err := o.start(); err != nil {
+			return err
+		}
+	}
+	if err := o.encoder.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(o.w, "\n</ONIXMessage>\n")
+	return err
+}
+
+func contributorRole(role model.ContributorRole) string {
+	switch role {
+	case model.ContributorRoleEditor:
+		return contributorEditedBy
+	case model.ContributorRoleTranslator:
+		return contributorTranslated
+	}
+	return contributorByAuthor
+}
+
+func productAvailability(availability model.BookAvailability) string {
+	switch availability {
+	case model.BookAvailabilityAvailable:
+		return availableInStock
+	case model.BookAvailabilitySoldOut:
+		return notAvailable
+	}
+	return outOfStock
+}
+
+// categoryName turns SCIENCE_FICTION into "Science fiction"
+func categoryName(category model.BookCategory) string {
+	name := strings.ToLower(strings.ReplaceAll(category.String(), "_", " "))
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,79 @@
+package exports
+
+import (
+	"bmsgql/spreadsheet"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var csvHeader = []string{
+	"id", "isbn", "title", "author", "contributors", "category", "description", "coverImage",
+	"availability", "copies", "availableCopies", "averageRating", "ratingCount", "addedAt",
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeader)
+	return &csvWriter{writer: writer}
+}
+
+// write escapes every cell, titles, descriptions and names come from users
+// and could otherwise run as formulas in the partner's spreadsheet
+func (c *csvWriter) write(record *Record) error {
+	c.writer.Write(spreadsheet.Row([]string{
+		record.ID,
+		record.Isbn,
+		record.Title,
+		record.Author,
+		contributorList(record.Contributors),
+		record.Category.String(),
+		record.Description,
+		record.CoverImage,
+		record.Availability.String(),
+		strconv.Itoa(record.Copies),
+		strconv.Itoa(record.AvailableCopies),
+		strconv.FormatFloat(record.AverageRating, 'f', 2, 64),
+		strconv.Itoa(record.RatingCount),
+		record.AddedAt,
+	}))
+	// flush every record so the response streams as it is built
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// contributorList formats contributors as "Name (ROLE); Name (ROLE)"
+func contributorList(contributors []Contributor) string {
+	parts := make([]string, len(contributors))
+	for i, contributor := range contributors {
+		parts[i] = contributor.Name + " (" + contributor.Role.String() + ")"
+	}
+	return strings.Join(parts, "; ")
+}
+
+type jsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLinesWriter(w io.Writer) *jsonLinesWriter {
+	return &jsonLinesWriter{encoder: json.NewEncoder(w)}
+}
+
+func (j *jsonLinesWriter) write(record *Record) error {
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonLinesWriter) close() error {
+	return nil
+}
@@ -6,15 +6,28 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type AddBookInput struct {
-	Title       string       `json:"title" bson:"title"`
-	Author      string       `json:"author" bson:"author"`
-	Category    BookCategory `json:"category" bson:"category"`
-	Description string       `json:"description" bson:"description"`
-	Isbn        string       `json:"isbn" bson:"isbn"`
-	CoverImage  string       `json:"coverImage" bson:"coverImage"`
+	Title           string              `json:"title" bson:"title"`
+	Author          *string             `json:"author,omitempty" bson:"author"`
+	Contributors    []*ContributorInput `json:"contributors,omitempty" bson:"contributors,omitempty"`
+	Category        BookCategory        `json:"category" bson:"category"`
+	Description     string              `json:"description" bson:"description"`
+	Isbn            string              `json:"isbn" bson:"isbn"`
+	CoverImage      *string             `json:"coverImage,omitempty" bson:"coverImage"`
+	Cover           *graphql.Upload     `json:"cover,omitempty" bson:"cover,omitempty"`
+	Format          *EditionFormat      `json:"format,omitempty" bson:"format,omitempty"`
+	Language        *string             `json:"language,omitempty" bson:"language,omitempty"`
+	Publisher       *string             `json:"publisher,omitempty" bson:"publisher,omitempty"`
+	PublicationYear *int                `json:"publicationYear,omitempty" bson:"publicationYear,omitempty"`
+	WorkID          *string             `json:"workId,omitempty" bson:"workId,omitempty"`
+	TagIds          []string            `json:"tagIds,omitempty" bson:"tagIds,omitempty"`
+	GenreIds        []string            `json:"genreIds,omitempty" bson:"genreIds,omitempty"`
 }
 
 type Admin struct {
@@ -24,9 +37,16 @@ type Admin struct {
 }
 
 type AdminDashboard struct {
-	TotalBooks  *int         `json:"totalBooks,omitempty" bson:"totalBooks"`
-	TotalUsers  *int         `json:"totalUsers,omitempty" bson:"totalUsers"`
-	BorrowStats *BorrowStats `json:"borrowStats,omitempty" bson:"borrowStats"`
+	TotalBooks           *int               `json:"totalBooks,omitempty" bson:"totalBooks"`
+	TotalUsers           *int               `json:"totalUsers,omitempty" bson:"totalUsers"`
+	BorrowStats          *BorrowStats       `json:"borrowStats,omitempty" bson:"borrowStats"`
+	OverdueLoans         *int               `json:"overdueLoans,omitempty" bson:"overdueLoans"`
+	ActiveReservations   *int               `json:"activeReservations,omitempty" bson:"activeReservations"`
+	MostBorrowedBooks    []*BookBorrowCount `json:"mostBorrowedBooks" bson:"mostBorrowedBooks"`
+	MostViewedBooks      []*BookViewCount   `json:"mostViewedBooks" bson:"mostViewedBooks"`
+	TopReviewers         []*ReviewerStat    `json:"topReviewers" bson:"topReviewers"`
+	CategoryDistribution []*CategoryCount   `json:"categoryDistribution" bson:"categoryDistribution"`
+	GeneratedAt          string             `json:"generatedAt" bson:"generatedAt"`
 }
 
 type AdminInput struct {
@@ -35,22 +55,82 @@ type AdminInput struct {
 	Password string `json:"password" bson:"password"`
 }
 
+type AuditEvent struct {
+	ID        string              `json:"id" bson:"_id"`
+	ActorRole *string             `json:"actorRole,omitempty" bson:"actorRole"`
+	Operation string              `json:"operation" bson:"operation"`
+	Entity    *string             `json:"entity,omitempty" bson:"entity"`
+	EntityID  *string             `json:"entityId,omitempty" bson:"entityId"`
+	Changes   []*FieldChange      `json:"changes" bson:"changes"`
+	Arguments *string             `json:"arguments,omitempty" bson:"arguments"`
+	Success   bool                `json:"success" bson:"success"`
+	Error     *string             `json:"error,omitempty" bson:"error"`
+	IP        *string             `json:"ip,omitempty" bson:"ip"`
+	RequestID *string             `json:"requestId,omitempty" bson:"requestId"`
+	Timestamp string              `json:"timestamp" bson:"timestamp"`
+	ActorID   *primitive.ObjectID `json:"-" bson:"actorId,omitempty"`
+}
+
+type AuditLogFilterInput struct {
+	ActorID   *string         `json:"actorId,omitempty" bson:"actorId,omitempty"`
+	Entity    *string         `json:"entity,omitempty" bson:"entity,omitempty"`
+	EntityID  *string         `json:"entityId,omitempty" bson:"entityId,omitempty"`
+	Operation *string         `json:"operation,omitempty" bson:"operation,omitempty"`
+	DateRange *DateRangeInput `json:"dateRange,omitempty" bson:"dateRange,omitempty"`
+}
+
 type AuthPayload struct {
 	Token string `json:"token" bson:"token"`
 	User  *User  `json:"user" bson:"user"`
 }
 
+type Author struct {
+	ID       string   `json:"id" bson:"_id"`
+	Name     string   `json:"name" bson:"name"`
+	Bio      string   `json:"bio" bson:"bio"`
+	Aliases  []string `json:"aliases" bson:"aliases"`
+	Photo    string   `json:"photo" bson:"photo"`
+	NameKeys []string `json:"-" bson:"nameKeys"`
+}
+
+type AuthorInput struct {
+	Name    string   `json:"name" bson:"name"`
+	Bio     *string  `json:"bio,omitempty" bson:"bio,omitempty"`
+	Aliases []string `json:"aliases,omitempty" bson:"aliases,omitempty"`
+	Photo   *string  `json:"photo,omitempty" bson:"photo,omitempty"`
+}
+
 type Book struct {
-	ID           string           `json:"id" bson:"_id"`
-	Title        string           `json:"title" bson:"title"`
-	Author       string           `json:"author" bson:"author"`
-	Category     BookCategory     `json:"category" bson:"category"`
-	Description  string           `json:"description" bson:"description"`
-	Isbn         string           `json:"isbn" bson:"isbn"`
-	CoverImage   string           `json:"coverImage" bson:"coverImage"`
-	Availability BookAvailability `json:"availability" bson:"availability"`
-	Rating       float64          `json:"rating" bson:"rating"`
-	Reviews      []*Review        `json:"reviews,omitempty" bson:"reviews"`
+	ID              string               `json:"id" bson:"_id"`
+	Title           string               `json:"title" bson:"title"`
+	Author          string               `json:"author" bson:"author"`
+	Category        BookCategory         `json:"category" bson:"category"`
+	Description     string               `json:"description" bson:"description"`
+	Isbn            string               `json:"isbn" bson:"isbn"`
+	Availability    BookAvailability     `json:"availability" bson:"availability"`
+	Rating          float64              `json:"rating" bson:"rating"`
+	Version         int                  `json:"version" bson:"version"`
+	Contributors    []*BookContributor   `json:"contributors" bson:"contributors"`
+	Format          *EditionFormat       `json:"format,omitempty" bson:"format"`
+	Language        *string              `json:"language,omitempty" bson:"language"`
+	Publisher       *string              `json:"publisher,omitempty" bson:"publisher"`
+	PublicationYear *int                 `json:"publicationYear,omitempty" bson:"publicationYear"`
+	CoverImage      string               `json:"coverImage" bson:"coverImage"`
+	CoverKey        string               `json:"-" bson:"coverKey,omitempty"`
+	GenreIDs        []primitive.ObjectID `json:"-" bson:"genreIds,omitempty"`
+	ReviewIDs       []primitive.ObjectID `json:"-" bson:"reviews"`
+	TagIDs          []primitive.ObjectID `json:"-" bson:"tagIds,omitempty"`
+	WorkID          *primitive.ObjectID  `json:"-" bson:"workId,omitempty"`
+}
+
+type BookBorrowCount struct {
+	BorrowCount int                `json:"borrowCount" bson:"borrowCount"`
+	BookID      primitive.ObjectID `json:"-" bson:"_id"`
+}
+
+type BookContributor struct {
+	Role     ContributorRole    `json:"role" bson:"role"`
+	AuthorID primitive.ObjectID `json:"-" bson:"authorId"`
 }
 
 type BookHistory struct {
@@ -59,6 +139,23 @@ type BookHistory struct {
 	ReturnedDate *string `json:"returnedDate,omitempty" bson:"returnedDate"`
 }
 
+type BookMetadata struct {
+	Isbn        string       `json:"isbn" bson:"isbn"`
+	Title       string       `json:"title" bson:"title"`
+	Author      string       `json:"author" bson:"author"`
+	Description string       `json:"description" bson:"description"`
+	Category    BookCategory `json:"category" bson:"category"`
+	CoverImage  string       `json:"coverImage" bson:"coverImage"`
+	Subjects    []string     `json:"subjects" bson:"subjects"`
+	Source      string       `json:"source" bson:"source"`
+}
+
+type BookViewCount struct {
+	ViewCount int                `json:"viewCount" bson:"viewCount"`
+	Viewers   int                `json:"viewers" bson:"viewers"`
+	BookID    primitive.ObjectID `json:"-" bson:"_id"`
+}
+
 type Bookmark struct {
 	Book *Book `json:"book" bson:"book"`
 	Page int   `json:"page" bson:"page"`
@@ -75,40 +172,180 @@ type BorrowStats struct {
 	Monthly *int `json:"monthly,omitempty" bson:"monthly"`
 }
 
+type CategoryCount struct {
+	Category BookCategory `json:"category" bson:"category"`
+	Count    int          `json:"count" bson:"count"`
+}
+
+type Collection struct {
+	ID          string               `json:"id" bson:"_id"`
+	Title       string               `json:"title" bson:"title"`
+	Description string               `json:"description" bson:"description"`
+	Featured    bool                 `json:"featured" bson:"featured"`
+	Position    int                  `json:"position" bson:"position"`
+	BookIDs     []primitive.ObjectID `json:"-" bson:"bookIds"`
+	CoverImage  string               `json:"coverImage" bson:"coverImage"`
+	CoverKey    string               `json:"-" bson:"coverKey,omitempty"`
+	EndTime     *time.Time           `json:"-" bson:"endsAt,omitempty"`
+	StartTime   *time.Time           `json:"-" bson:"startsAt,omitempty"`
+}
+
+type CollectionInput struct {
+	Title       string          `json:"title" bson:"title"`
+	Description *string         `json:"description,omitempty" bson:"description,omitempty"`
+	CoverImage  *string         `json:"coverImage,omitempty" bson:"coverImage,omitempty"`
+	Cover       *graphql.Upload `json:"cover,omitempty" bson:"cover,omitempty"`
+	Featured    *bool           `json:"featured,omitempty" bson:"featured,omitempty"`
+	StartsAt    *string         `json:"startsAt,omitempty" bson:"startsAt,omitempty"`
+	EndsAt      *string         `json:"endsAt,omitempty" bson:"endsAt,omitempty"`
+	BookIds     []string        `json:"bookIds,omitempty" bson:"bookIds,omitempty"`
+}
+
+type ContributorInput struct {
+	AuthorID string           `json:"authorId" bson:"authorId"`
+	Role     *ContributorRole `json:"role,omitempty" bson:"role,omitempty"`
+}
+
 type DateRangeInput struct {
 	StartDate string `json:"startDate" bson:"startDate"`
 	EndDate   string `json:"endDate" bson:"endDate"`
 }
 
 type Discussion struct {
-	ID        string             `json:"id" bson:"_id"`
-	Title     string             `json:"title" bson:"title"`
-	Category  string             `json:"category" bson:"category"`
-	Replies   []*DiscussionReply `json:"replies,omitempty" bson:"replies"`
-	CreatedAt *string            `json:"createdAt,omitempty" bson:"createdAt"`
-	CreatedBy *User              `json:"createdBy" bson:"createdBy"`
+	ID          string              `json:"id" bson:"_id"`
+	Title       string              `json:"title" bson:"title"`
+	Category    string              `json:"category" bson:"category"`
+	Content     string              `json:"content" bson:"content"`
+	Replies     []*DiscussionReply  `json:"replies,omitempty" bson:"replies"`
+	Pinned      bool                `json:"pinned" bson:"pinned"`
+	Locked      bool                `json:"locked" bson:"locked"`
+	CreatedAt   *string             `json:"createdAt,omitempty" bson:"createdAt"`
+	UpdatedAt   *string             `json:"updatedAt,omitempty" bson:"updatedAt"`
+	Version     int                 `json:"version" bson:"version"`
+	BookID      *primitive.ObjectID `json:"-" bson:"bookId,omitempty"`
+	CreatedByID primitive.ObjectID  `json:"-" bson:"createdBy"`
 }
 
 type DiscussionInput struct {
-	Title    string `json:"title" bson:"title"`
-	Category string `json:"category" bson:"category"`
-	Content  string `json:"content" bson:"content"`
+	Title    string  `json:"title" bson:"title"`
+	Category string  `json:"category" bson:"category"`
+	Content  string  `json:"content" bson:"content"`
+	BookID   *string `json:"bookId,omitempty" bson:"bookId,omitempty"`
 }
 
 type DiscussionReply struct {
-	ID        string  `json:"id" bson:"_id"`
-	Content   string  `json:"content" bson:"content"`
-	CreatedAt *string `json:"createdAt,omitempty" bson:"createdAt"`
-	CreatedBy *User   `json:"createdBy" bson:"createdBy"`
+	ID          string             `json:"id" bson:"_id"`
+	Content     string             `json:"content" bson:"content"`
+	ParentID    *string            `json:"parentId,omitempty" bson:"parentId"`
+	Deleted     bool               `json:"deleted" bson:"deleted"`
+	CreatedAt   *string            `json:"createdAt,omitempty" bson:"createdAt"`
+	UpdatedAt   *string            `json:"updatedAt,omitempty" bson:"updatedAt"`
+	CreatedByID primitive.ObjectID `json:"-" bson:"createdBy"`
+}
+
+type EditAuthorInput struct {
+	Name    *string  `json:"name,omitempty" bson:"name,omitempty"`
+	Bio     *string  `json:"bio,omitempty" bson:"bio,omitempty"`
+	Aliases []string `json:"aliases,omitempty" bson:"aliases,omitempty"`
+	Photo   *string  `json:"photo,omitempty" bson:"photo,omitempty"`
 }
 
 type EditBookInput struct {
-	Title       *string       `json:"title,omitempty" bson:"title"`
-	Author      *string       `json:"author,omitempty" bson:"author"`
-	Category    *BookCategory `json:"category,omitempty" bson:"category"`
-	Description *string       `json:"description,omitempty" bson:"description"`
-	Isbn        *string       `json:"isbn,omitempty" bson:"isbn"`
-	CoverImage  *string       `json:"coverImage,omitempty" bson:"coverImage"`
+	Title           *string             `json:"title,omitempty" bson:"title"`
+	Author          *string             `json:"author,omitempty" bson:"author"`
+	Contributors    []*ContributorInput `json:"contributors,omitempty" bson:"contributors,omitempty"`
+	Category        *BookCategory       `json:"category,omitempty" bson:"category"`
+	Description     *string             `json:"description,omitempty" bson:"description"`
+	Isbn            *string             `json:"isbn,omitempty" bson:"isbn"`
+	CoverImage      *string             `json:"coverImage,omitempty" bson:"coverImage"`
+	Cover           *graphql.Upload     `json:"cover,omitempty" bson:"cover,omitempty"`
+	Format          *EditionFormat      `json:"format,omitempty" bson:"format,omitempty"`
+	Language        *string             `json:"language,omitempty" bson:"language,omitempty"`
+	Publisher       *string             `json:"publisher,omitempty" bson:"publisher,omitempty"`
+	PublicationYear *int                `json:"publicationYear,omitempty" bson:"publicationYear,omitempty"`
+	WorkID          *string             `json:"workId,omitempty" bson:"workId,omitempty"`
+	TagIds          []string            `json:"tagIds,omitempty" bson:"tagIds,omitempty"`
+	GenreIds        []string            `json:"genreIds,omitempty" bson:"genreIds,omitempty"`
+}
+
+type EditCollectionInput struct {
+	Title       *string         `json:"title,omitempty" bson:"title,omitempty"`
+	Description *string         `json:"description,omitempty" bson:"description,omitempty"`
+	CoverImage  *string         `json:"coverImage,omitempty" bson:"coverImage,omitempty"`
+	Cover       *graphql.Upload `json:"cover,omitempty" bson:"cover,omitempty"`
+	Featured    *bool           `json:"featured,omitempty" bson:"featured,omitempty"`
+	StartsAt    *string         `json:"startsAt,omitempty" bson:"startsAt,omitempty"`
+	EndsAt      *string         `json:"endsAt,omitempty" bson:"endsAt,omitempty"`
+	BookIds     []string        `json:"bookIds,omitempty" bson:"bookIds,omitempty"`
+}
+
+type EditDiscussionInput struct {
+	Title    *string `json:"title,omitempty" bson:"title,omitempty"`
+	Category *string `json:"category,omitempty" bson:"category,omitempty"`
+	Content  *string `json:"content,omitempty" bson:"content,omitempty"`
+}
+
+type EditGenreInput struct {
+	Code     *string `json:"code,omitempty" bson:"code,omitempty"`
+	Name     *string `json:"name,omitempty" bson:"name,omitempty"`
+	ParentID *string `json:"parentId,omitempty" bson:"parentId,omitempty"`
+}
+
+type EditSeriesInput struct {
+	Title       *string `json:"title,omitempty" bson:"title,omitempty"`
+	Description *string `json:"description,omitempty" bson:"description,omitempty"`
+}
+
+type EditWorkInput struct {
+	Title    *string  `json:"title,omitempty" bson:"title,omitempty"`
+	SeriesID *string  `json:"seriesId,omitempty" bson:"seriesId,omitempty"`
+	Volume   *float64 `json:"volume,omitempty" bson:"volume,omitempty"`
+}
+
+type FieldChange struct {
+	Field  string  `json:"field" bson:"field"`
+	Before *string `json:"before,omitempty" bson:"before"`
+	After  *string `json:"after,omitempty" bson:"after"`
+}
+
+type Genre struct {
+	ID        string               `json:"id" bson:"_id"`
+	Scheme    GenreScheme          `json:"scheme" bson:"scheme"`
+	Code      string               `json:"code" bson:"code"`
+	Name      string               `json:"name" bson:"name"`
+	Ancestors []primitive.ObjectID `json:"-" bson:"ancestors"`
+	ParentID  *primitive.ObjectID  `json:"-" bson:"parentId,omitempty"`
+}
+
+type GenreInput struct {
+	Scheme   GenreScheme `json:"scheme" bson:"scheme"`
+	Code     string      `json:"code" bson:"code"`
+	Name     string      `json:"name" bson:"name"`
+	ParentID *string     `json:"parentId,omitempty" bson:"parentId,omitempty"`
+}
+
+type ImportJob struct {
+	ID         string            `json:"id" bson:"_id"`
+	Filename   string            `json:"filename" bson:"filename"`
+	Format     ImportFormat      `json:"format" bson:"format"`
+	Status     ImportJobStatus   `json:"status" bson:"status"`
+	Progress   int               `json:"progress" bson:"progress"`
+	Total      int               `json:"total" bson:"total"`
+	Processed  int               `json:"processed" bson:"processed"`
+	Created    int               `json:"created" bson:"created"`
+	Updated    int               `json:"updated" bson:"updated"`
+	Failed     int               `json:"failed" bson:"failed"`
+	RowErrors  []*ImportRowError `json:"rowErrors" bson:"rowErrors"`
+	Error      *string           `json:"error,omitempty" bson:"error"`
+	CreatedAt  string            `json:"createdAt" bson:"createdAt"`
+	StartedAt  *string           `json:"startedAt,omitempty" bson:"startedAt"`
+	FinishedAt *string           `json:"finishedAt,omitempty" bson:"finishedAt"`
+}
+
+type ImportRowError struct {
+	Row     int     `json:"row" bson:"row"`
+	Isbn    *string `json:"isbn,omitempty" bson:"isbn"`
+	Message string  `json:"message" bson:"message"`
 }
 
 type Library struct {
@@ -122,23 +359,30 @@ type Mutation struct {
 }
 
 type Notification struct {
-	ID        string  `json:"id" bson:"_id"`
-	Type      string  `json:"type" bson:"type"`
-	Message   string  `json:"message" bson:"message"`
-	CreatedAt *string `json:"createdAt,omitempty" bson:"createdAt"`
-	Read      *bool   `json:"read,omitempty" bson:"read"`
+	ID        string             `json:"id" bson:"_id"`
+	Type      string             `json:"type" bson:"type"`
+	Message   string             `json:"message" bson:"message"`
+	CreatedAt *string            `json:"createdAt,omitempty" bson:"createdAt"`
+	Read      *bool              `json:"read,omitempty" bson:"read"`
+	UserID    primitive.ObjectID `json:"-" bson:"userId"`
 }
 
 type NotificationSettings struct {
-	DueDateReminders  *bool `json:"dueDateReminders,omitempty" bson:"dueDateReminders"`
-	NewArrivals       *bool `json:"newArrivals,omitempty" bson:"newArrivals"`
-	CommunityActivity *bool `json:"communityActivity,omitempty" bson:"communityActivity"`
+	DueDateReminders  *bool                 `json:"dueDateReminders,omitempty" bson:"dueDateReminders"`
+	NewArrivals       *bool                 `json:"newArrivals,omitempty" bson:"newArrivals"`
+	NewArrivalsDigest *bool                 `json:"newArrivalsDigest,omitempty" bson:"newArrivalsDigest"`
+	CommunityActivity *bool                 `json:"communityActivity,omitempty" bson:"communityActivity"`
+	Channels          []NotificationChannel `json:"channels" bson:"channels"`
+	WebhookURL        *string               `json:"webhookUrl,omitempty" bson:"webhookUrl"`
 }
 
 type NotificationSettingsInput struct {
-	DueDateReminders  *bool `json:"dueDateReminders,omitempty" bson:"dueDateReminders,omitempty"`
-	NewArrivals       *bool `json:"newArrivals,omitempty" bson:"newArrivals,omitempty"`
-	CommunityActivity *bool `json:"communityActivity,omitempty" bson:"communityActivity,omitempty"`
+	DueDateReminders  *bool                 `json:"dueDateReminders,omitempty" bson:"dueDateReminders,omitempty"`
+	NewArrivals       *bool                 `json:"newArrivals,omitempty" bson:"newArrivals,omitempty"`
+	NewArrivalsDigest *bool                 `json:"newArrivalsDigest,omitempty" bson:"newArrivalsDigest,omitempty"`
+	CommunityActivity *bool                 `json:"communityActivity,omitempty" bson:"communityActivity,omitempty"`
+	Channels          []NotificationChannel `json:"channels,omitempty" bson:"channels,omitempty"`
+	WebhookURL        *string               `json:"webhookUrl,omitempty" bson:"webhookUrl,omitempty"`
 }
 
 type PaymentDetails struct {
@@ -163,30 +407,44 @@ type Query struct {
 }
 
 type Report struct {
-	ID          string `json:"id" bson:"_id,omitempty"`
-	Title       string `json:"title" bson:"title"`
-	GeneratedAt string `json:"generatedAt" bson:"generatedAt"`
-	Data        string `json:"data" bson:"data"`
+	ID          string     `json:"id" bson:"_id,omitempty"`
+	Title       string     `json:"title" bson:"title"`
+	Type        ReportType `json:"type" bson:"type"`
+	GeneratedAt string     `json:"generatedAt" bson:"generatedAt"`
+	Columns     []string   `json:"columns" bson:"columns"`
+	Rows        [][]string `json:"rows" bson:"rows"`
 }
 
 type ReportFilterInput struct {
+	Type         *ReportType     `json:"type,omitempty" bson:"type,omitempty"`
 	DateRange    *DateRangeInput `json:"dateRange,omitempty" bson:"dateRange,omitempty"`
 	Category     *BookCategory   `json:"category,omitempty" bson:"category,omitempty"`
 	UserActivity *string         `json:"userActivity,omitempty" bson:"userActivity,omitempty"`
 }
 
+type ReportJob struct {
+	ID         string               `json:"id" bson:"_id"`
+	Status     ReportJobStatus      `json:"status" bson:"status"`
+	Progress   int                  `json:"progress" bson:"progress"`
+	Error      *string              `json:"error,omitempty" bson:"error"`
+	CreatedAt  string               `json:"createdAt" bson:"createdAt"`
+	StartedAt  *string              `json:"startedAt,omitempty" bson:"startedAt"`
+	FinishedAt *string              `json:"finishedAt,omitempty" bson:"finishedAt"`
+	ReportIDs  []primitive.ObjectID `json:"-" bson:"reportIds"`
+}
+
 type ReserveReceipt struct {
 	Book            *Book  `json:"book" bson:"book"`
 	ReservationDate string `json:"reservationDate" bson:"reservationDate"`
 }
 
 type Review struct {
-	ID        string  `json:"id" bson:"_id,omitempty"`
-	User      *User   `json:"user" bson:"user"`
-	Book      *Book   `json:"book" bson:"book"`
-	Rating    float64 `json:"rating" bson:"rating"`
-	Content   *string `json:"content,omitempty" bson:"content,omitempty"`
-	CreatedAt *string `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+	ID        string             `json:"id" bson:"_id,omitempty"`
+	Rating    float64            `json:"rating" bson:"rating"`
+	Content   *string            `json:"content,omitempty" bson:"content,omitempty"`
+	CreatedAt *string            `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+	BookID    primitive.ObjectID `json:"-" bson:"bookId"`
+	UserID    primitive.ObjectID `json:"-" bson:"userId"`
 }
 
 type ReviewInput struct {
@@ -194,6 +452,23 @@ type ReviewInput struct {
 	Content *string `json:"content,omitempty" bson:"content,omitempty"`
 }
 
+type ReviewerStat struct {
+	ReviewCount   int                `json:"reviewCount" bson:"reviewCount"`
+	AverageRating float64            `json:"averageRating" bson:"averageRating"`
+	UserID        primitive.ObjectID `json:"-" bson:"_id"`
+}
+
+type Series struct {
+	ID          string `json:"id" bson:"_id"`
+	Title       string `json:"title" bson:"title"`
+	Description string `json:"description" bson:"description"`
+}
+
+type SeriesInput struct {
+	Title       string  `json:"title" bson:"title"`
+	Description *string `json:"description,omitempty" bson:"description,omitempty"`
+}
+
 type SignUpInput struct {
 	Name           string          `json:"name" bson:"name"`
 	Email          string          `json:"email" bson:"email"`
@@ -201,6 +476,15 @@ type SignUpInput struct {
 	FavoriteGenres []*BookCategory `json:"favoriteGenres,omitempty" bson:"favoriteGenres,omitempty"`
 }
 
+type Subscription struct {
+}
+
+type Tag struct {
+	ID   string `json:"id" bson:"_id"`
+	Name string `json:"name" bson:"name"`
+	Slug string `json:"slug" bson:"slug"`
+}
+
 type UpdateProfileInput struct {
 	Name           *string         `json:"name,omitempty" bson:"name,omitempty"`
 	Email          *string         `json:"email,omitempty" bson:"email,omitempty"`
@@ -228,6 +512,19 @@ type UserProfile struct {
 	ActivityStats  *UserActivityStats `json:"activityStats,omitempty" bson:"activityStats,omitempty"`
 }
 
+type Work struct {
+	ID       string              `json:"id" bson:"_id"`
+	Title    string              `json:"title" bson:"title"`
+	Volume   *float64            `json:"volume,omitempty" bson:"volume"`
+	SeriesID *primitive.ObjectID `json:"-" bson:"seriesId,omitempty"`
+}
+
+type WorkInput struct {
+	Title    string   `json:"title" bson:"title"`
+	SeriesID *string  `json:"seriesId,omitempty" bson:"seriesId,omitempty"`
+	Volume   *float64 `json:"volume,omitempty" bson:"volume,omitempty"`
+}
+
 type BookAvailability string
 
 const (
@@ -334,6 +631,403 @@ func (e BookCategory) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+type ContributorRole string
+
+const (
+	ContributorRoleAuthor     ContributorRole = "AUTHOR"
+	ContributorRoleEditor     ContributorRole = "EDITOR"
+	ContributorRoleTranslator ContributorRole = "TRANSLATOR"
+)
+
+var AllContributorRole = []ContributorRole{
+	ContributorRoleAuthor,
+	ContributorRoleEditor,
+	ContributorRoleTranslator,
+}
+
+func (e ContributorRole) IsValid() bool {
+	switch e {
+	case ContributorRoleAuthor, ContributorRoleEditor, ContributorRoleTranslator:
+		return true
+	}
+	return false
+}
+
+func (e ContributorRole) String() string {
+	return string(e)
+}
+
+func (e *ContributorRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContributorRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContributorRole", str)
+	}
+	return nil
+}
+
+func (e ContributorRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type CoverSize string
+
+const (
+	CoverSizeSmall    CoverSize = "SMALL"
+	CoverSizeMedium   CoverSize = "MEDIUM"
+	CoverSizeLarge    CoverSize = "LARGE"
+	CoverSizeOriginal CoverSize = "ORIGINAL"
+)
+
+var AllCoverSize = []CoverSize{
+	CoverSizeSmall,
+	CoverSizeMedium,
+	CoverSizeLarge,
+	CoverSizeOriginal,
+}
+
+func (e CoverSize) IsValid() bool {
+	switch e {
+	case CoverSizeSmall, CoverSizeMedium, CoverSizeLarge, CoverSizeOriginal:
+		return true
+	}
+	return false
+}
+
+func (e CoverSize) String() string {
+	return string(e)
+}
+
+func (e *CoverSize) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CoverSize(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CoverSize", str)
+	}
+	return nil
+}
+
+func (e CoverSize) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type EditionFormat string
+
+const (
+	EditionFormatHardcover EditionFormat = "HARDCOVER"
+	EditionFormatPaperback EditionFormat = "PAPERBACK"
+	EditionFormatEbook     EditionFormat = "EBOOK"
+	EditionFormatAudiobook EditionFormat = "AUDIOBOOK"
+	EditionFormatOther     EditionFormat = "OTHER"
+)
+
+var AllEditionFormat = []EditionFormat{
+	EditionFormatHardcover,
+	EditionFormatPaperback,
+	EditionFormatEbook,
+	EditionFormatAudiobook,
+	EditionFormatOther,
+}
+
+func (e EditionFormat) IsValid() bool {
+	switch e {
+	case EditionFormatHardcover, EditionFormatPaperback, EditionFormatEbook, EditionFormatAudiobook, EditionFormatOther:
+		return true
+	}
+	return false
+}
+
+func (e EditionFormat) String() string {
+	return string(e)
+}
+
+func (e *EditionFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = EditionFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid EditionFormat", str)
+	}
+	return nil
+}
+
+func (e EditionFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type GenreScheme string
+
+const (
+	GenreSchemeBisac  GenreScheme = "BISAC"
+	GenreSchemeDewey  GenreScheme = "DEWEY"
+	GenreSchemeCustom GenreScheme = "CUSTOM"
+)
+
+var AllGenreScheme = []GenreScheme{
+	GenreSchemeBisac,
+	GenreSchemeDewey,
+	GenreSchemeCustom,
+}
+
+func (e GenreScheme) IsValid() bool {
+	switch e {
+	case GenreSchemeBisac, GenreSchemeDewey, GenreSchemeCustom:
+		return true
+	}
+	return false
+}
+
+func (e GenreScheme) String() string {
+	return string(e)
+}
+
+func (e *GenreScheme) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = GenreScheme(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid GenreScheme", str)
+	}
+	return nil
+}
+
+func (e GenreScheme) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ImportFormat string
+
+const (
+	ImportFormatCSV     ImportFormat = "CSV"
+	ImportFormatMarc    ImportFormat = "MARC"
+	ImportFormatMarcxml ImportFormat = "MARCXML"
+)
+
+var AllImportFormat = []ImportFormat{
+	ImportFormatCSV,
+	ImportFormatMarc,
+	ImportFormatMarcxml,
+}
+
+func (e ImportFormat) IsValid() bool {
+	switch e {
+	case ImportFormatCSV, ImportFormatMarc, ImportFormatMarcxml:
+		return true
+	}
+	return false
+}
+
+func (e ImportFormat) String() string {
+	return string(e)
+}
+
+func (e *ImportFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportFormat", str)
+	}
+	return nil
+}
+
+func (e ImportFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ImportJobStatus string
+
+const (
+	ImportJobStatusQueued    ImportJobStatus = "QUEUED"
+	ImportJobStatusRunning   ImportJobStatus = "RUNNING"
+	ImportJobStatusCompleted ImportJobStatus = "COMPLETED"
+	ImportJobStatusFailed    ImportJobStatus = "FAILED"
+)
+
+var AllImportJobStatus = []ImportJobStatus{
+	ImportJobStatusQueued,
+	ImportJobStatusRunning,
+	ImportJobStatusCompleted,
+	ImportJobStatusFailed,
+}
+
+func (e ImportJobStatus) IsValid() bool {
+	switch e {
+	case ImportJobStatusQueued, ImportJobStatusRunning, ImportJobStatusCompleted, ImportJobStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ImportJobStatus) String() string {
+	return string(e)
+}
+
+func (e *ImportJobStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportJobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportJobStatus", str)
+	}
+	return nil
+}
+
+func (e ImportJobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail   NotificationChannel = "EMAIL"
+	NotificationChannelWebhook NotificationChannel = "WEBHOOK"
+)
+
+var AllNotificationChannel = []NotificationChannel{
+	NotificationChannelEmail,
+	NotificationChannelWebhook,
+}
+
+func (e NotificationChannel) IsValid() bool {
+	switch e {
+	case NotificationChannelEmail, NotificationChannelWebhook:
+		return true
+	}
+	return false
+}
+
+func (e NotificationChannel) String() string {
+	return string(e)
+}
+
+func (e *NotificationChannel) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationChannel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationChannel", str)
+	}
+	return nil
+}
+
+func (e NotificationChannel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportJobStatus string
+
+const (
+	ReportJobStatusQueued    ReportJobStatus = "QUEUED"
+	ReportJobStatusRunning   ReportJobStatus = "RUNNING"
+	ReportJobStatusCompleted ReportJobStatus = "COMPLETED"
+	ReportJobStatusFailed    ReportJobStatus = "FAILED"
+)
+
+var AllReportJobStatus = []ReportJobStatus{
+	ReportJobStatusQueued,
+	ReportJobStatusRunning,
+	ReportJobStatusCompleted,
+	ReportJobStatusFailed,
+}
+
+func (e ReportJobStatus) IsValid() bool {
+	switch e {
+	case ReportJobStatusQueued, ReportJobStatusRunning, ReportJobStatusCompleted, ReportJobStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ReportJobStatus) String() string {
+	return string(e)
+}
+
+func (e *ReportJobStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportJobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportJobStatus", str)
+	}
+	return nil
+}
+
+func (e ReportJobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportType string
+
+const (
+	ReportTypeCirculation        ReportType = "CIRCULATION"
+	ReportTypeCategoryPopularity ReportType = "CATEGORY_POPULARITY"
+	ReportTypeUserActivity       ReportType = "USER_ACTIVITY"
+	ReportTypeOverdueItems       ReportType = "OVERDUE_ITEMS"
+)
+
+var AllReportType = []ReportType{
+	ReportTypeCirculation,
+	ReportTypeCategoryPopularity,
+	ReportTypeUserActivity,
+	ReportTypeOverdueItems,
+}
+
+func (e ReportType) IsValid() bool {
+	switch e {
+	case ReportTypeCirculation, ReportTypeCategoryPopularity, ReportTypeUserActivity, ReportTypeOverdueItems:
+		return true
+	}
+	return false
+}
+
+func (e ReportType) String() string {
+	return string(e)
+}
+
+func (e *ReportType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportType", str)
+	}
+	return nil
+}
+
+func (e ReportType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 type UserRole string
 
 const (
@@ -5,14 +5,165 @@ package graph
 // Code generated by github.com/99designs/gqlgen version v0.17.55
 
 import (
+	"bmsgql/audit"
+	"bmsgql/authors"
 	"bmsgql/books"
+	"bmsgql/collections"
+	"bmsgql/covers"
+	"bmsgql/dashboard"
+	"bmsgql/discussions"
 	"bmsgql/graph/model"
+	"bmsgql/imports"
+	"bmsgql/loaders"
+	"bmsgql/notifications"
+	"bmsgql/recommendations"
+	"bmsgql/reports"
 	"bmsgql/reviews"
+	"bmsgql/tags"
 	"bmsgql/user"
+	"bmsgql/works"
 	"context"
+	"errors"
 	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
 )
 
+// Actor is the resolver for the actor field.
+func (r *auditEventResolver) Actor(ctx context.Context, obj *model.AuditEvent) (*model.User, error) {
+	if obj.ActorID == nil {
+		return nil, nil
+	}
+	actor, err := loaders.GetUser(ctx, obj.ActorID.Hex())
+	if errors.Is(err, loaders.ErrNotFound) {
+		return nil, nil
+	}
+	return actor, err
+}
+
+// Books is the resolver for the books field.
+func (r *authorResolver) Books(ctx context.Context, obj *model.Author, limit *int, offset *int) ([]*model.Book, error) {
+	return authors.Books(ctx, obj, limit, offset)
+}
+
+// CoverImage is the resolver for the coverImage field.
+func (r *bookResolver) CoverImage(ctx context.Context, obj *model.Book, size *model.CoverSize) (string, error) {
+	if size == nil {
+		return covers.URL(obj, model.CoverSizeOriginal), nil
+	}
+	return covers.URL(obj, *size), nil
+}
+
+// Reviews is the resolver for the reviews field.
+func (r *bookResolver) Reviews(ctx context.Context, obj *model.Book) ([]*model.Review, error) {
+	reviewIDs := make([]string, len(obj.ReviewIDs))
+	for i, reviewID := range obj.ReviewIDs {
+		reviewIDs[i] = reviewID.Hex()
+	}
+	return loaders.GetReviews(ctx, reviewIDs)
+}
+
+// Work is the resolver for the work field.
+func (r *bookResolver) Work(ctx context.Context, obj *model.Book) (*model.Work, error) {
+	return works.BookWork(ctx, obj)
+}
+
+// OtherEditions is the resolver for the otherEditions field.
+func (r *bookResolver) OtherEditions(ctx context.Context, obj *model.Book) ([]*model.Book, error) {
+	return works.OtherEditions(ctx, obj)
+}
+
+// NextInSeries is the resolver for the nextInSeries field.
+func (r *bookResolver) NextInSeries(ctx context.Context, obj *model.Book) (*model.Work, error) {
+	return works.NextInSeries(ctx, obj)
+}
+
+// Tags is the resolver for the tags field.
+func (r *bookResolver) Tags(ctx context.Context, obj *model.Book) ([]*model.Tag, error) {
+	return tags.BookTags(ctx, obj)
+}
+
+// Genres is the resolver for the genres field.
+func (r *bookResolver) Genres(ctx context.Context, obj *model.Book) ([]*model.Genre, error) {
+	return tags.BookGenres(ctx, obj)
+}
+
+// AlsoBorrowed is the resolver for the alsoBorrowed field.
+func (r *bookResolver) AlsoBorrowed(ctx context.Context, obj *model.Book, limit *int) ([]*model.Book, error) {
+	return recommendations.AlsoBorrowed(ctx, obj, limit)
+}
+
+// Book is the resolver for the book field.
+func (r *bookBorrowCountResolver) Book(ctx context.Context, obj *model.BookBorrowCount) (*model.Book, error) {
+	return loaders.GetBook(ctx, obj.BookID.Hex())
+}
+
+// Author is the resolver for the author field.
+func (r *bookContributorResolver) Author(ctx context.Context, obj *model.BookContributor) (*model.Author, error) {
+	return loaders.GetAuthor(ctx, obj.AuthorID.Hex())
+}
+
+// Book is the resolver for the book field.
+func (r *bookViewCountResolver) Book(ctx context.Context, obj *model.BookViewCount) (*model.Book, error) {
+	return loaders.GetBook(ctx, obj.BookID.Hex())
+}
+
+// CoverImage is the resolver for the coverImage field.
+func (r *collectionResolver) CoverImage(ctx context.Context, obj *model.Collection, size *model.CoverSize) (*string, error) {
+	if size == nil {
+		return collections.CoverURL(obj, model.CoverSizeOriginal), nil
+	}
+	return collections.CoverURL(obj, *size), nil
+}
+
+// StartsAt is the resolver for the startsAt field.
+func (r *collectionResolver) StartsAt(ctx context.Context, obj *model.Collection) (*string, error) {
+	return collections.FormatTime(obj.StartTime), nil
+}
+
+// EndsAt is the resolver for the endsAt field.
+func (r *collectionResolver) EndsAt(ctx context.Context, obj *model.Collection) (*string, error) {
+	return collections.FormatTime(obj.EndTime), nil
+}
+
+// Active is the resolver for the active field.
+func (r *collectionResolver) Active(ctx context.Context, obj *model.Collection) (bool, error) {
+	return collections.Active(obj), nil
+}
+
+// Books is the resolver for the books field.
+func (r *collectionResolver) Books(ctx context.Context, obj *model.Collection) ([]*model.Book, error) {
+	return collections.Books(ctx, obj)
+}
+
+// Book is the resolver for the book field.
+func (r *discussionResolver) Book(ctx context.Context, obj *model.Discussion) (*model.Book, error) {
+	if obj.BookID == nil {
+		return nil, nil
+	}
+	return loaders.GetBook(ctx, obj.BookID.Hex())
+}
+
+// CreatedBy is the resolver for the createdBy field.
+func (r *discussionResolver) CreatedBy(ctx context.Context, obj *model.Discussion) (*model.User, error) {
+	return loaders.GetUser(ctx, obj.CreatedByID.Hex())
+}
+
+// CreatedBy is the resolver for the createdBy field.
+func (r *discussionReplyResolver) CreatedBy(ctx context.Context, obj *model.DiscussionReply) (*model.User, error) {
+	return loaders.GetUser(ctx, obj.CreatedByID.Hex())
+}
+
+// Parent is the resolver for the parent field.
+func (r *genreResolver) Parent(ctx context.Context, obj *model.Genre) (*model.Genre, error) {
+	return tags.Parent(ctx, obj)
+}
+
+// Children is the resolver for the children field.
+func (r *genreResolver) Children(ctx context.Context, obj *model.Genre) ([]*model.Genre, error) {
+	return tags.Children(ctx, obj)
+}
+
 // Login is the resolver for the login field.
 func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*model.AuthPayload, error) {
 	return user.Login(email, password)
@@ -43,8 +194,8 @@ func (r *mutationResolver) AddBook(ctx context.Context, input model.AddBookInput
 }
 
 // EditBook is the resolver for the editBook field.
-func (r *mutationResolver) EditBook(ctx context.Context, id string, input model.EditBookInput) (*model.Book, error) {
-	editbook, err := books.EditBook(ctx, id, input)
+func (r *mutationResolver) EditBook(ctx context.Context, id string, input model.EditBookInput, expectedVersion int) (*model.Book, error) {
+	editbook, err := books.EditBook(ctx, id, input, expectedVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -60,14 +211,207 @@ func (r *mutationResolver) DeleteBook(ctx context.Context, id string) (bool, err
 	return deletebook, nil
 }
 
+// RestoreBook is the resolver for the restoreBook field.
+func (r *mutationResolver) RestoreBook(ctx context.Context, id string) (*model.Book, error) {
+	restorebook, err := books.RestoreBook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return restorebook, nil
+}
+
+// ImportBookByIsbn is the resolver for the importBookByIsbn field.
+func (r *mutationResolver) ImportBookByIsbn(ctx context.Context, isbn string) (*model.BookMetadata, error) {
+	metadata, err := books.ImportBookByIsbn(ctx, isbn)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// ImportCatalogue is the resolver for the importCatalogue field.
+func (r *mutationResolver) ImportCatalogue(ctx context.Context, file graphql.Upload, format *model.ImportFormat) (string, error) {
+	return imports.ImportCatalogue(ctx, file, format)
+}
+
+// AddAuthor is the resolver for the addAuthor field.
+func (r *mutationResolver) AddAuthor(ctx context.Context, input model.AuthorInput) (*model.Author, error) {
+	author, err := authors.AddAuthor(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// EditAuthor is the resolver for the editAuthor field.
+func (r *mutationResolver) EditAuthor(ctx context.Context, id string, input model.EditAuthorInput) (*model.Author, error) {
+	author, err := authors.EditAuthor(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// CreateCollection is the resolver for the createCollection field.
+func (r *mutationResolver) CreateCollection(ctx context.Context, input model.CollectionInput) (*model.Collection, error) {
+	collection, err := collections.CreateCollection(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// EditCollection is the resolver for the editCollection field.
+func (r *mutationResolver) EditCollection(ctx context.Context, id string, input model.EditCollectionInput) (*model.Collection, error) {
+	collection, err := collections.EditCollection(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// DeleteCollection is the resolver for the deleteCollection field.
+func (r *mutationResolver) DeleteCollection(ctx context.Context, id string) (bool, error) {
+	deleted, err := collections.DeleteCollection(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// ReorderCollections is the resolver for the reorderCollections field.
+func (r *mutationResolver) ReorderCollections(ctx context.Context, ids []string) ([]*model.Collection, error) {
+	collectionlist, err := collections.ReorderCollections(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return collectionlist, nil
+}
+
+// AddTag is the resolver for the addTag field.
+func (r *mutationResolver) AddTag(ctx context.Context, name string) (*model.Tag, error) {
+	tag, err := tags.AddTag(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// RenameTag is the resolver for the renameTag field.
+func (r *mutationResolver) RenameTag(ctx context.Context, id string, name string) (*model.Tag, error) {
+	tag, err := tags.RenameTag(ctx, id, name)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// DeleteTag is the resolver for the deleteTag field.
+func (r *mutationResolver) DeleteTag(ctx context.Context, id string) (bool, error) {
+	deletetag, err := tags.DeleteTag(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return deletetag, nil
+}
+
+// AddGenre is the resolver for the addGenre field.
+func (r *mutationResolver) AddGenre(ctx context.Context, input model.GenreInput) (*model.Genre, error) {
+	genre, err := tags.AddGenre(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return genre, nil
+}
+
+// EditGenre is the resolver for the editGenre field.
+func (r *mutationResolver) EditGenre(ctx context.Context, id string, input model.EditGenreInput) (*model.Genre, error) {
+	genre, err := tags.EditGenre(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	return genre, nil
+}
+
+// DeleteGenre is the resolver for the deleteGenre field.
+func (r *mutationResolver) DeleteGenre(ctx context.Context, id string) (bool, error) {
+	deletegenre, err := tags.DeleteGenre(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return deletegenre, nil
+}
+
+// AddWork is the resolver for the addWork field.
+func (r *mutationResolver) AddWork(ctx context.Context, input model.WorkInput) (*model.Work, error) {
+	work, err := works.AddWork(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return work, nil
+}
+
+// EditWork is the resolver for the editWork field.
+func (r *mutationResolver) EditWork(ctx context.Context, id string, input model.EditWorkInput) (*model.Work, error) {
+	work, err := works.EditWork(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	return work, nil
+}
+
+// AddSeries is the resolver for the addSeries field.
+func (r *mutationResolver) AddSeries(ctx context.Context, input model.SeriesInput) (*model.Series, error) {
+	series, err := works.AddSeries(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// EditSeries is the resolver for the editSeries field.
+func (r *mutationResolver) EditSeries(ctx context.Context, id string, input model.EditSeriesInput) (*model.Series, error) {
+	series, err := works.EditSeries(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
 // BorrowBook is the resolver for the borrowBook field.
 func (r *mutationResolver) BorrowBook(ctx context.Context, bookID string) (*model.BorrowReceipt, error) {
-	panic(fmt.Errorf("not implemented: BorrowBook - borrowBook"))
+	borrowbook, err := books.BorrowBook(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return borrowbook, nil
 }
 
 // ReserveBook is the resolver for the reserveBook field.
 func (r *mutationResolver) ReserveBook(ctx context.Context, bookID string) (*model.ReserveReceipt, error) {
-	panic(fmt.Errorf("not implemented: ReserveBook - reserveBook"))
+	reservebook, err := books.ReserveBook(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return reservebook, nil
+}
+
+// ReserveWork is the resolver for the reserveWork field.
+func (r *mutationResolver) ReserveWork(ctx context.Context, workID string) (*model.ReserveReceipt, error) {
+	receipt, err := books.ReserveWork(ctx, workID)
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// ReturnBook is the resolver for the returnBook field.
+func (r *mutationResolver) ReturnBook(ctx context.Context, bookID string) (*model.Book, error) {
+	returnbook, err := books.ReturnBook(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return returnbook, nil
 }
 
 // PurchaseBook is the resolver for the purchaseBook field.
@@ -80,6 +424,33 @@ func (r *mutationResolver) AddBookmark(ctx context.Context, bookID string, page
 	panic(fmt.Errorf("not implemented: AddBookmark - addBookmark"))
 }
 
+// ClearRecentlyViewed is the resolver for the clearRecentlyViewed field.
+func (r *mutationResolver) ClearRecentlyViewed(ctx context.Context) (bool, error) {
+	cleared, err := books.ClearRecentlyViewed(ctx)
+	if err != nil {
+		return false, err
+	}
+	return cleared, nil
+}
+
+// AddFavorite is the resolver for the addFavorite field.
+func (r *mutationResolver) AddFavorite(ctx context.Context, bookID string) (*model.Book, error) {
+	book, err := books.AddFavorite(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// RemoveFavorite is the resolver for the removeFavorite field.
+func (r *mutationResolver) RemoveFavorite(ctx context.Context, bookID string) (bool, error) {
+	removed, err := books.RemoveFavorite(ctx, bookID)
+	if err != nil {
+		return false, err
+	}
+	return removed, nil
+}
+
 // AddReview is the resolver for the addReview field.
 func (r *mutationResolver) AddReview(ctx context.Context, bookID string, input model.ReviewInput) (*model.Review, error) {
 	addreview, err := reviews.AddReview(ctx, bookID, input)
@@ -101,12 +472,74 @@ func (r *mutationResolver) DeleteReview(ctx context.Context, reviewID string) (b
 
 // CreateDiscussion is the resolver for the createDiscussion field.
 func (r *mutationResolver) CreateDiscussion(ctx context.Context, input model.DiscussionInput) (*model.Discussion, error) {
-	panic(fmt.Errorf("not implemented: CreateDiscussion - createDiscussion"))
+	creatediscussion, err := discussions.CreateDiscussion(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return creatediscussion, nil
+}
+
+// EditDiscussion is the resolver for the editDiscussion field.
+func (r *mutationResolver) EditDiscussion(ctx context.Context, id string, input model.EditDiscussionInput, expectedVersion int) (*model.Discussion, error) {
+	editdiscussion, err := discussions.EditDiscussion(ctx, id, input, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return editdiscussion, nil
+}
+
+// DeleteDiscussion is the resolver for the deleteDiscussion field.
+func (r *mutationResolver) DeleteDiscussion(ctx context.Context, id string) (bool, error) {
+	deletediscussion, err := discussions.DeleteDiscussion(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return deletediscussion, nil
 }
 
 // ReplyToDiscussion is the resolver for the replyToDiscussion field.
-func (r *mutationResolver) ReplyToDiscussion(ctx context.Context, discussionID string, content string) (*model.Discussion, error) {
-	panic(fmt.Errorf("not implemented: ReplyToDiscussion - replyToDiscussion"))
+func (r *mutationResolver) ReplyToDiscussion(ctx context.Context, discussionID string, content string, parentID *string) (*model.Discussion, error) {
+	reply, err := discussions.ReplyToDiscussion(ctx, discussionID, content, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// EditDiscussionReply is the resolver for the editDiscussionReply field.
+func (r *mutationResolver) EditDiscussionReply(ctx context.Context, discussionID string, replyID string, content string) (*model.Discussion, error) {
+	editreply, err := discussions.EditDiscussionReply(ctx, discussionID, replyID, content)
+	if err != nil {
+		return nil, err
+	}
+	return editreply, nil
+}
+
+// DeleteDiscussionReply is the resolver for the deleteDiscussionReply field.
+func (r *mutationResolver) DeleteDiscussionReply(ctx context.Context, discussionID string, replyID string) (bool, error) {
+	deletereply, err := discussions.DeleteDiscussionReply(ctx, discussionID, replyID)
+	if err != nil {
+		return false, err
+	}
+	return deletereply, nil
+}
+
+// PinDiscussion is the resolver for the pinDiscussion field.
+func (r *mutationResolver) PinDiscussion(ctx context.Context, id string, pinned bool) (*model.Discussion, error) {
+	pindiscussion, err := discussions.PinDiscussion(ctx, id, pinned)
+	if err != nil {
+		return nil, err
+	}
+	return pindiscussion, nil
+}
+
+// LockDiscussion is the resolver for the lockDiscussion field.
+func (r *mutationResolver) LockDiscussion(ctx context.Context, id string, locked bool) (*model.Discussion, error) {
+	lockdiscussion, err := discussions.LockDiscussion(ctx, id, locked)
+	if err != nil {
+		return nil, err
+	}
+	return lockdiscussion, nil
 }
 
 // UpdateProfile is the resolver for the updateProfile field.
@@ -121,7 +554,34 @@ func (r *mutationResolver) ChangePassword(ctx context.Context, currentPassword s
 
 // UpdateNotificationSettings is the resolver for the updateNotificationSettings field.
 func (r *mutationResolver) UpdateNotificationSettings(ctx context.Context, input model.NotificationSettingsInput) (*model.NotificationSettings, error) {
-	panic(fmt.Errorf("not implemented: UpdateNotificationSettings - updateNotificationSettings"))
+	settings, err := notifications.UpdateNotificationSettings(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// MarkNotificationRead is the resolver for the markNotificationRead field.
+func (r *mutationResolver) MarkNotificationRead(ctx context.Context, id string) (*model.Notification, error) {
+	notification, err := notifications.MarkNotificationRead(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// MarkAllNotificationsRead is the resolver for the markAllNotificationsRead field.
+func (r *mutationResolver) MarkAllNotificationsRead(ctx context.Context) (bool, error) {
+	markedread, err := notifications.MarkAllNotificationsRead(ctx)
+	if err != nil {
+		return false, err
+	}
+	return markedread, nil
+}
+
+// GenerateReport is the resolver for the generateReport field.
+func (r *mutationResolver) GenerateReport(ctx context.Context, filter *model.ReportFilterInput) (string, error) {
+	return reports.GenerateReport(ctx, filter)
 }
 
 // CurrentUser is the resolver for the currentUser field.
@@ -142,9 +602,40 @@ func (r *queryResolver) FeaturedBooks(ctx context.Context) ([]*model.Book, error
 	return featuredbooks, nil
 }
 
+// RecommendedBooks is the resolver for the recommendedBooks field.
+func (r *queryResolver) RecommendedBooks(ctx context.Context, limit *int) ([]*model.Book, error) {
+	booklist, err := recommendations.RecommendedBooks(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	return booklist, nil
+}
+
+// Collections is the resolver for the collections field.
+func (r *queryResolver) Collections(ctx context.Context, activeOnly *bool) ([]*model.Collection, error) {
+	collectionlist, err := collections.Collections(ctx, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	return collectionlist, nil
+}
+
+// Collection is the resolver for the collection field.
+func (r *queryResolver) Collection(ctx context.Context, id string) (*model.Collection, error) {
+	collection, err := collections.Collection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
 // RecentlyViewedBooks is the resolver for the recentlyViewedBooks field.
-func (r *queryResolver) RecentlyViewedBooks(ctx context.Context) ([]*model.Book, error) {
-	panic(fmt.Errorf("not implemented: RecentlyViewedBooks - recentlyViewedBooks"))
+func (r *queryResolver) RecentlyViewedBooks(ctx context.Context, limit *int) ([]*model.Book, error) {
+	recentlyviewed, err := books.RecentlyViewedBooks(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	return recentlyviewed, nil
 }
 
 // SearchBooks is the resolver for the searchBooks field.
@@ -161,24 +652,139 @@ func (r *queryResolver) BookDetails(ctx context.Context, id string) (*model.Book
 	return bookdetails, nil
 }
 
+// BookByIsbn is the resolver for the bookByIsbn field.
+func (r *queryResolver) BookByIsbn(ctx context.Context, isbn string) (*model.Book, error) {
+	book, err := books.BookByIsbn(ctx, isbn)
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Author is the resolver for the author field.
+func (r *queryResolver) Author(ctx context.Context, id string) (*model.Author, error) {
+	author, err := authors.Author(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// SearchAuthors is the resolver for the searchAuthors field.
+func (r *queryResolver) SearchAuthors(ctx context.Context, query string, limit *int, offset *int) ([]*model.Author, error) {
+	authorlist, err := authors.SearchAuthors(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return authorlist, nil
+}
+
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context) ([]*model.Tag, error) {
+	taglist, err := tags.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return taglist, nil
+}
+
+// Genres is the resolver for the genres field.
+func (r *queryResolver) Genres(ctx context.Context, scheme *model.GenreScheme, parentID *string) ([]*model.Genre, error) {
+	genrelist, err := tags.Genres(ctx, scheme, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return genrelist, nil
+}
+
+// Genre is the resolver for the genre field.
+func (r *queryResolver) Genre(ctx context.Context, id string) (*model.Genre, error) {
+	genre, err := tags.Genre(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return genre, nil
+}
+
+// BooksByTag is the resolver for the booksByTag field.
+func (r *queryResolver) BooksByTag(ctx context.Context, slug string, limit *int, offset *int) ([]*model.Book, error) {
+	booklist, err := tags.BooksByTag(ctx, slug, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return booklist, nil
+}
+
+// BooksByGenre is the resolver for the booksByGenre field.
+func (r *queryResolver) BooksByGenre(ctx context.Context, id string, includeSubgenres *bool, limit *int, offset *int) ([]*model.Book, error) {
+	booklist, err := tags.BooksByGenre(ctx, id, includeSubgenres, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return booklist, nil
+}
+
+// Work is the resolver for the work field.
+func (r *queryResolver) Work(ctx context.Context, id string) (*model.Work, error) {
+	work, err := works.Work(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return work, nil
+}
+
+// Series is the resolver for the series field.
+func (r *queryResolver) Series(ctx context.Context, id string) (*model.Series, error) {
+	series, err := works.Series(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
 // MyLibrary is the resolver for the myLibrary field.
 func (r *queryResolver) MyLibrary(ctx context.Context) (*model.Library, error) {
-	panic(fmt.Errorf("not implemented: MyLibrary - myLibrary"))
+	library, err := books.MyLibrary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return library, nil
 }
 
 // BookHistory is the resolver for the bookHistory field.
-func (r *queryResolver) BookHistory(ctx context.Context) ([]*model.BookHistory, error) {
-	panic(fmt.Errorf("not implemented: BookHistory - bookHistory"))
+func (r *queryResolver) BookHistory(ctx context.Context, year *int, limit *int, offset *int) ([]*model.BookHistory, error) {
+	history, err := books.BookHistory(ctx, year, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
 }
 
 // BookReviews is the resolver for the bookReviews field.
 func (r *queryResolver) BookReviews(ctx context.Context, bookID string) ([]*model.Review, error) {
-	panic(fmt.Errorf("not implemented: BookReviews - bookReviews"))
+	bookreviews, err := reviews.BookReviews(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return bookreviews, nil
 }
 
 // CommunityDiscussions is the resolver for the communityDiscussions field.
-func (r *queryResolver) CommunityDiscussions(ctx context.Context) ([]*model.Discussion, error) {
-	panic(fmt.Errorf("not implemented: CommunityDiscussions - communityDiscussions"))
+func (r *queryResolver) CommunityDiscussions(ctx context.Context, category *string, bookID *string, limit *int, offset *int) ([]*model.Discussion, error) {
+	communitydiscussions, err := discussions.CommunityDiscussions(ctx, category, bookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return communitydiscussions, nil
+}
+
+// Discussion is the resolver for the discussion field.
+func (r *queryResolver) Discussion(ctx context.Context, id string) (*model.Discussion, error) {
+	discussion, err := discussions.GetDiscussion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return discussion, nil
 }
 
 // UserProfile is the resolver for the userProfile field.
@@ -187,13 +793,39 @@ func (r *queryResolver) UserProfile(ctx context.Context) (*model.UserProfile, er
 }
 
 // Notifications is the resolver for the notifications field.
-func (r *queryResolver) Notifications(ctx context.Context) ([]*model.Notification, error) {
-	panic(fmt.Errorf("not implemented: Notifications - notifications"))
+func (r *queryResolver) Notifications(ctx context.Context, unreadOnly *bool, limit *int, offset *int) ([]*model.Notification, error) {
+	usernotifications, err := notifications.Notifications(ctx, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return usernotifications, nil
+}
+
+// UnreadNotificationCount is the resolver for the unreadNotificationCount field.
+func (r *queryResolver) UnreadNotificationCount(ctx context.Context) (int, error) {
+	unreadcount, err := notifications.UnreadNotificationCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return unreadcount, nil
+}
+
+// NotificationSettings is the resolver for the notificationSettings field.
+func (r *queryResolver) NotificationSettings(ctx context.Context) (*model.NotificationSettings, error) {
+	settings, err := notifications.NotificationSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
 }
 
 // AdminDashboard is the resolver for the adminDashboard field.
 func (r *queryResolver) AdminDashboard(ctx context.Context) (*model.AdminDashboard, error) {
-	panic(fmt.Errorf("not implemented: AdminDashboard - adminDashboard"))
+	admindashboard, err := dashboard.AdminDashboard(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return admindashboard, nil
 }
 
 // UserList is the resolver for the userList field.
@@ -202,15 +834,174 @@ func (r *queryResolver) UserList(ctx context.Context) ([]*model.User, error) {
 }
 
 // Reports is the resolver for the reports field.
-func (r *queryResolver) Reports(ctx context.Context, filter *model.ReportFilterInput) ([]*model.Report, error) {
-	panic(fmt.Errorf("not implemented: Reports - reports"))
+func (r *queryResolver) Reports(ctx context.Context, filter *model.ReportFilterInput, limit *int, offset *int) ([]*model.Report, error) {
+	reportlist, err := reports.Reports(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return reportlist, nil
+}
+
+// ImportJob is the resolver for the importJob field.
+func (r *queryResolver) ImportJob(ctx context.Context, id string) (*model.ImportJob, error) {
+	importjob, err := imports.ImportJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return importjob, nil
+}
+
+// ReportJob is the resolver for the reportJob field.
+func (r *queryResolver) ReportJob(ctx context.Context, id string) (*model.ReportJob, error) {
+	reportjob, err := reports.ReportJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return reportjob, nil
+}
+
+// AuditLog is the resolver for the auditLog field.
+func (r *queryResolver) AuditLog(ctx context.Context, filter *model.AuditLogFilterInput, limit *int, offset *int) ([]*model.AuditEvent, error) {
+	auditlog, err := audit.AuditLog(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return auditlog, nil
+}
+
+// Data is the resolver for the data field.
+func (r *reportResolver) Data(ctx context.Context, obj *model.Report) (string, error) {
+	return reports.Data(obj)
+}
+
+// DownloadURL is the resolver for the downloadUrl field.
+func (r *reportResolver) DownloadURL(ctx context.Context, obj *model.Report) (string, error) {
+	return reports.DownloadURL(obj), nil
+}
+
+// Reports is the resolver for the reports field.
+func (r *reportJobResolver) Reports(ctx context.Context, obj *model.ReportJob) ([]*model.Report, error) {
+	return reports.JobReports(ctx, obj)
+}
+
+// User is the resolver for the user field.
+func (r *reviewResolver) User(ctx context.Context, obj *model.Review) (*model.User, error) {
+	return loaders.GetUser(ctx, obj.UserID.Hex())
+}
+
+// Book is the resolver for the book field.
+func (r *reviewResolver) Book(ctx context.Context, obj *model.Review) (*model.Book, error) {
+	return loaders.GetBook(ctx, obj.BookID.Hex())
+}
+
+// User is the resolver for the user field.
+func (r *reviewerStatResolver) User(ctx context.Context, obj *model.ReviewerStat) (*model.User, error) {
+	return loaders.GetUser(ctx, obj.UserID.Hex())
+}
+
+// Works is the resolver for the works field.
+func (r *seriesResolver) Works(ctx context.Context, obj *model.Series) ([]*model.Work, error) {
+	return works.Works(ctx, obj)
+}
+
+// DiscussionReplyAdded is the resolver for the discussionReplyAdded field.
+func (r *subscriptionResolver) DiscussionReplyAdded(ctx context.Context, discussionID string) (<-chan *model.DiscussionReply, error) {
+	return discussions.ReplyAdded(ctx, discussionID)
+}
+
+// NotificationReceived is the resolver for the notificationReceived field.
+func (r *subscriptionResolver) NotificationReceived(ctx context.Context) (<-chan *model.Notification, error) {
+	return notifications.Received(ctx)
+}
+
+// BookAvailabilityChanged is the resolver for the bookAvailabilityChanged field.
+func (r *subscriptionResolver) BookAvailabilityChanged(ctx context.Context, bookID string) (<-chan *model.Book, error) {
+	return books.AvailabilityChanged(ctx, bookID)
+}
+
+// Editions is the resolver for the editions field.
+func (r *workResolver) Editions(ctx context.Context, obj *model.Work) ([]*model.Book, error) {
+	return works.Editions(ctx, obj)
+}
+
+// Series is the resolver for the series field.
+func (r *workResolver) Series(ctx context.Context, obj *model.Work) (*model.Series, error) {
+	return works.WorkSeries(ctx, obj)
 }
 
+// AuditEvent returns AuditEventResolver implementation.
+func (r *Resolver) AuditEvent() AuditEventResolver { return &auditEventResolver{r} }
+
+// Author returns AuthorResolver implementation.
+func (r *Resolver) Author() AuthorResolver { return &authorResolver{r} }
+
+// Book returns BookResolver implementation.
+func (r *Resolver) Book() BookResolver { return &bookResolver{r} }
+
+// BookBorrowCount returns BookBorrowCountResolver implementation.
+func (r *Resolver) BookBorrowCount() BookBorrowCountResolver { return &bookBorrowCountResolver{r} }
+
+// BookContributor returns BookContributorResolver implementation.
+func (r *Resolver) BookContributor() BookContributorResolver { return &bookContributorResolver{r} }
+
+// BookViewCount returns BookViewCountResolver implementation.
+func (r *Resolver) BookViewCount() BookViewCountResolver { return &bookViewCountResolver{r} }
+
+// Collection returns CollectionResolver implementation.
+func (r *Resolver) Collection() CollectionResolver { return &collectionResolver{r} }
+
+// Discussion returns DiscussionResolver implementation.
+func (r *Resolver) Discussion() DiscussionResolver { return &discussionResolver{r} }
+
+// DiscussionReply returns DiscussionReplyResolver implementation.
+func (r *Resolver) DiscussionReply() DiscussionReplyResolver { return &discussionReplyResolver{r} }
+
+// Genre returns GenreResolver implementation.
+func (r *Resolver) Genre() GenreResolver { return &genreResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Report returns ReportResolver implementation.
+func (r *Resolver) Report() ReportResolver { return &reportResolver{r} }
+
+// ReportJob returns ReportJobResolver implementation.
+func (r *Resolver) ReportJob() ReportJobResolver { return &reportJobResolver{r} }
+
+// Review returns ReviewResolver implementation.
+func (r *Resolver) Review() ReviewResolver { return &reviewResolver{r} }
+
+// ReviewerStat returns ReviewerStatResolver implementation.
+func (r *Resolver) ReviewerStat() ReviewerStatResolver { return &reviewerStatResolver{r} }
+
+// Series returns SeriesResolver implementation.
+func (r *Resolver) Series() SeriesResolver { return &seriesResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// Work returns WorkResolver implementation.
+func (r *Resolver) Work() WorkResolver { return &workResolver{r} }
+
+type auditEventResolver struct{ *Resolver }
+type authorResolver struct{ *Resolver }
+type bookResolver struct{ *Resolver }
+type bookBorrowCountResolver struct{ *Resolver }
+type bookContributorResolver struct{ *Resolver }
+type bookViewCountResolver struct{ *Resolver }
+type collectionResolver struct{ *Resolver }
+type discussionResolver struct{ *Resolver }
+type discussionReplyResolver struct{ *Resolver }
+type genreResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type reportResolver struct{ *Resolver }
+type reportJobResolver struct{ *Resolver }
+type reviewResolver struct{ *Resolver }
+type reviewerStatResolver struct{ *Resolver }
+type seriesResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type workResolver struct{ *Resolver }
@@ -0,0 +1,193 @@
+package imports
+
+import (
+	"bmsgql/audit"
+	"bmsgql/authors"
+	"bmsgql/catalogue"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bmsgql/isbn"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// progressEvery is how many rows are processed between progress reports
+	progressEvery = 100
+	// maxRowErrors caps the errors kept per import, a file that is wrong
+	// throughout would otherwise produce one error per row
+	maxRowErrors = 1000
+)
+
+// Result summarises an import as it runs
+type Result struct {
+	Total     int
+	Processed int
+	Created   int
+	Updated   int
+	Failed    int
+	RowErrors []*model.ImportRowError
+	// CreatedIDs and UpdatedIDs are the books the import saved, for the audit
+	// log
+	CreatedIDs []primitive.ObjectID
+	UpdatedIDs []primitive.ObjectID
+}
+
+// Progress receives the result so far while an import runs
+type Progress func(result Result)
+
+// Import validates every row and upserts it by ISBN. total is the number of
+// rows, as given by Count, for progress. Invalid rows are reported in the
+// result and do not stop the import. Imported books are not announced as new
+// arrivals, onboarding a branch would otherwise flood readers with
+// notifications.
+func Import(ctx context.Context, rows Rows, total int, progress Progress) (*Result, error) {
+	result := &Result{Total: total, RowErrors: []*model.ImportRowError{}}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		bookId, created, err := upsert(ctx, row)
+		result.Processed++
+		switch {
+		case err != nil:
+			result.Failed++
+			if len(result.RowErrors) < maxRowErrors {
+				rowError := &model.ImportRowError{Row: row.Line, Message: err.Error()}
+				if row.Isbn != "" {
+					rowError.Isbn = &row.Isbn
+				}
+				result.RowErrors = append(result.RowErrors, rowError)
+			}
+		case created:
+			result.Created++
+			result.CreatedIDs = append(result.CreatedIDs, bookId)
+		default:
+			result.Updated++
+			result.UpdatedIDs = append(result.UpdatedIDs, bookId)
+		}
+
+		if progress != nil && result.Processed%progressEvery == 0 {
+			progress(*result)
+		}
+	}
+	if result.Total < result.Processed {
+		result.Total = result.Processed
+	}
+	if progress != nil && result.Processed%progressEvery != 0 {
+		progress(*result)
+	}
+	return result, nil
+}
+
+// Audit records an import in the audit log as one event listing the books it
+// created and updated, the rows do not go through the mutations that audit
+// books one by one. entityID names the import job or file.
+func Audit(ctx context.Context, entity string, entityID string, actorID *primitive.ObjectID, result *Result, importErr error) {
+	summary := struct {
+		Created []primitive.ObjectID `bson:"created"`
+		Updated []primitive.ObjectID `bson:"updated"`
+		Failed  int                  `bson:"failed"`
+	}{result.CreatedIDs, result.UpdatedIDs, result.Failed}
+	if summary.Created == nil {
+		summary.Created = []primitive.ObjectID{}
+	}
+	if summary.Updated == nil {
+		summary.Updated = []primitive.ObjectID{}
+	}
+	audit.Summary(ctx, "importCatalogue", entity, entityID, actorID, &summary, importErr)
+}
+
+// upsert saves a row, returning the book and whether it was created
+func upsert(ctx context.Context, row Row) (primitive.ObjectID, bool, error) {
+	BookCollection := database.DB.Collection("Books")
+
+	if row.Title == "" {
+		return primitive.NilObjectID, false, fmt.Errorf("title is required")
+	}
+	if row.Author == "" && len(row.Credits) == 0 {
+		return primitive.NilObjectID, false, fmt.Errorf("author is required")
+	}
+	if row.Isbn == "" {
+		return primitive.NilObjectID, false, fmt.Errorf("ISBN is required")
+	}
+	normalized, err := isbn.Normalize(row.Isbn)
+	if err != nil {
+		return primitive.NilObjectID, false, fmt.Errorf("invalid ISBN %q", row.Isbn)
+	}
+	category, err := parseCategory(row)
+	if err != nil {
+		return primitive.NilObjectID, false, err
+	}
+
+	// an anthology may only credit its editors
+	credits := append(authors.Authored(authors.SplitNames(row.Author)), row.Credits...)
+	contributors, author, err := authors.ResolveCredits(ctx, credits)
+	if err != nil {
+		return primitive.NilObjectID, false, err
+	}
+
+	set := bson.M{"title": row.Title, "author": author, "contributors": contributors, "category": category}
+	// an ID of our own tells a created book from an updated one
+	newId := primitive.NewObjectID()
+	setOnInsert := bson.M{"_id": newId, "availability": model.BookAvailabilityAvailable}
+	// empty optional columns keep what an existing book already has
+	for field, value := range map[string]string{"description": row.Description, "coverImage": row.CoverImage} {
+		if value != "" {
+			set[field] = value
+		} else {
+			setOnInsert[field] = ""
+		}
+	}
+
+	update := bson.M{"$set": set, "$setOnInsert": setOnInsert, "$inc": bson.M{"version": 1}}
+	// an external cover replaces an uploaded one, as it does in editBook
+	if row.CoverImage != "" {
+		update["$unset"] = bson.M{"coverKey": ""}
+	}
+
+	var saved struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err = BookCollection.FindOneAndUpdate(ctx,
+		bson.M{"isbn": normalized, "deletedAt": bson.M{"$exists": false}},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After).SetProjection(bson.M{"_id": 1}),
+	).Decode(&saved)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return primitive.NilObjectID, false, fmt.Errorf("a deleted book with ISBN %s exists, restore it first", normalized)
+		}
+		return primitive.NilObjectID, false, fmt.Errorf("failed to save book: %w", err)
+	}
+	return saved.ID, saved.ID == newId, nil
+}
+
+// parseCategory reads the category column, "Science fiction" and
+// "SCIENCE_FICTION" alike, or guesses it from the subjects when it is empty
+func parseCategory(row Row) (model.BookCategory, error) {
+	if row.Category == "" {
+		return catalogue.Category(row.Subjects), nil
+	}
+	category := model.BookCategory(strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_").Replace(row.Category)))
+	if !category.IsValid() {
+		return "", fmt.Errorf("unknown category %q", row.Category)
+	}
+	return category, nil
+}
@@ -0,0 +1,443 @@
+package imports
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultWorkers       = 1
+	defaultRetentionDays = 30
+	pollInterval         = 5 * time.Second
+	// jobTimeout bounds a single run, a RUNNING job older than this is assumed to
+	// belong to a worker that died and is picked up again
+	jobTimeout = 2 * time.Hour
+	// uploadBucket is the GridFS bucket holding files waiting to be imported, so
+	// that any replica's workers can process them
+	uploadBucket = "imports"
+)
+
+// job is a document of the ImportJobs collection
+type job struct {
+	ID          primitive.ObjectID      `bson:"_id,omitempty"`
+	Filename    string                  `bson:"filename"`
+	Format      model.ImportFormat      `bson:"format"`
+	FileID      primitive.ObjectID      `bson:"fileId"`
+	Status      model.ImportJobStatus   `bson:"status"`
+	Progress    int                     `bson:"progress"`
+	Total       int                     `bson:"total"`
+	Processed   int                     `bson:"processed"`
+	Created     int                     `bson:"created"`
+	Updated     int                     `bson:"updated"`
+	Failed      int                     `bson:"failed"`
+	RowErrors   []*model.ImportRowError `bson:"rowErrors"`
+	Error       string                  `bson:"error,omitempty"`
+	RequestedBy primitive.ObjectID      `bson:"requestedBy"`
+	CreatedAt   time.Time               `bson:"createdAt"`
+	StartedAt   *time.Time              `bson:"startedAt,omitempty"`
+	FinishedAt  *time.Time              `bson:"finishedAt,omitempty"`
+}
+
+func (j *job) toModel() *model.ImportJob {
+	format := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		formatted := t.Format(time.RFC3339)
+		return &formatted
+	}
+
+	importJob := &model.ImportJob{
+		ID:         j.ID.Hex(),
+		Filename:   j.Filename,
+		Format:     j.Format,
+		Status:     j.Status,
+		Progress:   j.Progress,
+		Total:      j.Total,
+		Processed:  j.Processed,
+		Created:    j.Created,
+		Updated:    j.Updated,
+		Failed:     j.Failed,
+		RowErrors:  j.RowErrors,
+		CreatedAt:  j.CreatedAt.Format(time.RFC3339),
+		StartedAt:  format(j.StartedAt),
+		FinishedAt: format(j.FinishedAt),
+	}
+	if importJob.RowErrors == nil {
+		importJob.RowErrors = []*model.ImportRowError{}
+	}
+	if j.Error != "" {
+		importJob.Error = &j.Error
+	}
+	return importJob
+}
+
+// wake lets ImportCatalogue start an idle worker without waiting for the next poll
+var wake = make(chan struct{}, 1)
+
+// ImportCatalogue stores an uploaded CSV, MARC or MARCXML file and queues it for
+// import. The format is detected from the file when not given.
+func ImportCatalogue(ctx context.Context, file graphql.Upload, format *model.ImportFormat) (string, error) {
+	ImportJobCollection := database.DB.Collection("ImportJobs")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return "", fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return "", fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return "", fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID")
+	}
+
+	reader := bufio.NewReader(file.File)
+	var importFormat model.ImportFormat
+	if format != nil {
+		importFormat = *format
+	} else {
+		head, _ := reader.Peek(512)
+		importFormat = DetectFormat(file.Filename, head)
+	}
+
+	bucket, err := uploads()
+	if err != nil {
+		return "", err
+	}
+	fileId, err := bucket.UploadFromStream(file.Filename, reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to store import file: %w", err)
+	}
+
+	result, err := ImportJobCollection.InsertOne(ctx, job{
+		Filename:    file.Filename,
+		Format:      importFormat,
+		FileID:      fileId,
+		Status:      model.ImportJobStatusQueued,
+		RowErrors:   []*model.ImportRowError{},
+		RequestedBy: userObjId,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		bucket.Delete(fileId)
+		return "", fmt.Errorf("failed to queue import: %w", err)
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// ImportJob returns the progress of a queued import
+func ImportJob(ctx context.Context, id string) (*model.ImportJob, error) {
+	ImportJobCollection := database.DB.Collection("ImportJobs")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	jobId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid import job ID")
+	}
+
+	var j job
+	err = ImportJobCollection.FindOne(ctx, bson.M{"_id": jobId}).Decode(&j)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("import job not found")
+		}
+		return nil, fmt.Errorf("failed to find import job: %w", err)
+	}
+	return j.toModel(), nil
+}
+
+// RunWorkers processes queued imports with IMPORT_WORKERS workers until ctx is
+// cancelled. Every replica runs workers, a job is claimed by exactly one.
+func RunWorkers(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < envInt("IMPORT_WORKERS", defaultWorkers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx)
+		}()
+	}
+	wg.Wait()
+	log.Println("imports: workers stopped")
+}
+
+func work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// drain the queue before waiting again
+		for ctx.Err() == nil {
+			j, err := claim(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("imports: %v", err)
+				}
+				break
+			}
+			if j == nil {
+				break
+			}
+			run(ctx, j)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks the oldest queued job as RUNNING and returns it, or nil when the
+// queue is empty
+func claim(ctx context.Context) (*job, error) {
+	ImportJobCollection := database.DB.Collection("ImportJobs")
+
+	now := time.Now()
+	var j job
+	err := ImportJobCollection.FindOneAndUpdate(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"status": model.ImportJobStatusQueued},
+			bson.M{"status": model.ImportJobStatusRunning, "startedAt": bson.M{"$lt": now.Add(-jobTimeout)}},
+		}},
+		bson.M{"$set": bson.M{"status": model.ImportJobStatusRunning, "startedAt": now}},
+		options.FindOneAndUpdate().SetSort(bson.M{"createdAt": 1}).SetReturnDocument(options.After),
+	).Decode(&j)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim import job: %w", err)
+	}
+	return &j, nil
+}
+
+// run reads the job's file twice, first to check and count its records, then
+// to import them, recording progress as it goes. Rows are upserted by ISBN, so
+// a job picked up again after a crash simply repeats the rows it had already
+// done.
+func run(ctx context.Context, j *job) {
+	ImportJobCollection := database.DB.Collection("ImportJobs")
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	var total int
+	err := readUpload(j.FileID, func(stream io.Reader) error {
+		var err error
+		total, err = Count(stream, j.Format)
+		return err
+	})
+	if err != nil {
+		finish(j, model.ImportJobStatusFailed, bson.M{"error": err.Error()})
+		return
+	}
+	_, err = ImportJobCollection.UpdateOne(ctx, bson.M{"_id": j.ID}, bson.M{"$set": bson.M{"total": total}})
+	if err != nil {
+		log.Printf("imports: failed to record size of job %s: %v", j.ID.Hex(), err)
+	}
+
+	var result *Result
+	err = readUpload(j.FileID, func(stream io.Reader) error {
+		rows, err := NewReader(stream, j.Format)
+		if err != nil {
+			return err
+		}
+		result, err = Import(ctx, rows, total, func(result Result) {
+			_, err := ImportJobCollection.UpdateOne(ctx, bson.M{"_id": j.ID}, bson.M{"$set": progressFields(result)})
+			if err != nil {
+				log.Printf("imports: failed to record progress of job %s: %v", j.ID.Hex(), err)
+			}
+		})
+		return err
+	})
+	if result != nil && result.Processed > 0 {
+		Audit(ctx, "ImportJob", j.ID.Hex(), &j.RequestedBy, result, err)
+	}
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			// shutting down, leave the job for the next worker
+			requeue(j.ID)
+			return
+		}
+		fields := bson.M{}
+		if result != nil {
+			fields = progressFields(*result)
+		}
+		fields["error"] = err.Error()
+		finish(j, model.ImportJobStatusFailed, fields)
+		return
+	}
+
+	fields := progressFields(*result)
+	fields["progress"] = 100
+	finish(j, model.ImportJobStatusCompleted, fields)
+	log.Printf("imports: job %s imported %d row(s), %d failed", j.ID.Hex(), result.Processed, result.Failed)
+}
+
+func progressFields(result Result) bson.M {
+	progress := 100
+	if result.Total > 0 {
+		progress = result.Processed * 100 / result.Total
+	}
+	return bson.M{
+		"progress":  progress,
+		"total":     result.Total,
+		"processed": result.Processed,
+		"created":   result.Created,
+		"updated":   result.Updated,
+		"failed":    result.Failed,
+		"rowErrors": result.RowErrors,
+	}
+}
+
+// readUpload passes the stored file of a job to read
+func readUpload(fileId primitive.ObjectID, read func(io.Reader) error) error {
+	bucket, err := uploads()
+	if err != nil {
+		return err
+	}
+	stream, err := bucket.OpenDownloadStream(fileId)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer stream.Close()
+	return read(stream)
+}
+
+// finish records the outcome of a job and drops its file, even when the worker
+// is shutting down
+func finish(j *job, status model.ImportJobStatus, fields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fields["status"] = status
+	fields["finishedAt"] = time.Now()
+	_, err := database.DB.Collection("ImportJobs").UpdateOne(ctx, bson.M{"_id": j.ID}, bson.M{"$set": fields})
+	if err != nil {
+		log.Printf("imports: failed to finish job %s: %v", j.ID.Hex(), err)
+		return
+	}
+	if err := deleteUpload(ctx, j.FileID); err != nil {
+		log.Printf("imports: %v", err)
+	}
+}
+
+// requeue puts a job interrupted by shutdown back in the queue
+func requeue(jobId primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection("ImportJobs").UpdateOne(ctx, bson.M{"_id": jobId}, bson.M{
+		"$set":   bson.M{"status": model.ImportJobStatusQueued},
+		"$unset": bson.M{"startedAt": ""},
+	})
+	if err != nil {
+		log.Printf("imports: failed to requeue job %s: %v", jobId.Hex(), err)
+	}
+}
+
+// CleanupImports removes finished jobs older than IMPORT_RETENTION_DAYS with any
+// file left behind
+func CleanupImports(ctx context.Context) error {
+	ImportJobCollection := database.DB.Collection("ImportJobs")
+
+	filter := bson.M{
+		"status":     bson.M{"$in": bson.A{model.ImportJobStatusCompleted, model.ImportJobStatusFailed}},
+		"finishedAt": bson.M{"$lt": time.Now().AddDate(0, 0, -envInt("IMPORT_RETENTION_DAYS", defaultRetentionDays))},
+	}
+	cursor, err := ImportJobCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"fileId": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to fetch old import jobs: %w", err)
+	}
+	var jobs []job
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return fmt.Errorf("failed to decode old import jobs: %w", err)
+	}
+	for _, j := range jobs {
+		if err := deleteUpload(ctx, j.FileID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := ImportJobCollection.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("failed to remove old import jobs: %w", err)
+	}
+	return nil
+}
+
+func deleteUpload(ctx context.Context, fileId primitive.ObjectID) error {
+	bucket, err := uploads()
+	if err != nil {
+		return err
+	}
+	if err := bucket.DeleteContext(ctx, fileId); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return fmt.Errorf("failed to delete import file %s: %w", fileId.Hex(), err)
+	}
+	return nil
+}
+
+func uploads() (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(database.DB, options.GridFSBucket().SetName(uploadBucket))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import bucket: %w", err)
+	}
+	return bucket, nil
+}
+
+func envInt(name string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
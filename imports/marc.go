@@ -0,0 +1,252 @@
+package imports
+
+import (
+	"bmsgql/authors"
+	"bmsgql/graph/model"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ISO 2709 delimiters
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+	leaderLength      = 24
+	directoryEntry    = 12
+)
+
+// marcRecord holds the data fields of a MARC record as tag -> occurrences,
+// each occurrence mapping subfield codes to their values
+type marcRecord map[string][]map[byte][]string
+
+func (m marcRecord) first(tag string, code byte) string {
+	for _, field := range m[tag] {
+		if values := field[code]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func (m marcRecord) all(tag string, code byte) []string {
+	var values []string
+	for _, field := range m[tag] {
+		values = append(values, field[code]...)
+	}
+	return values
+}
+
+// relators maps the relator terms ($e) and codes ($4) of added entries to the
+// roles we credit, cataloguers abbreviate the terms
+var relators = map[string]model.ContributorRole{
+	"aut":        model.ContributorRoleAuthor,
+	"author":     model.ContributorRoleAuthor,
+	"edt":        model.ContributorRoleEditor,
+	"ed":         model.ContributorRoleEditor,
+	"editor":     model.ContributorRoleEditor,
+	"trl":        model.ContributorRoleTranslator,
+	"tr":         model.ContributorRoleTranslator,
+	"trans":      model.ContributorRoleTranslator,
+	"translator": model.ContributorRoleTranslator,
+}
+
+// row maps the bibliographic fields we catalogue: 020 ISBN, 100/110 main entry,
+// 245 title, 520 summary, 650/655 subjects and genres, 700/710 added entries,
+// 856 electronic location
+func (m marcRecord) row(line int) Row {
+	row := Row{Line: line}
+
+	// 020$a may carry a qualifier, "9780306406157 (pbk.)"
+	for _, isbn := range m.all("020", 'a') {
+		if fields := strings.Fields(isbn); len(fields) > 0 {
+			row.Isbn = fields[0]
+			break
+		}
+	}
+
+	row.Author = trimPunctuation(m.first("100", 'a'))
+	if row.Author == "" {
+		row.Author = trimPunctuation(m.first("110", 'a'))
+	}
+
+	row.Credits = m.credits()
+
+	title := trimPunctuation(m.first("245", 'a'))
+	if subtitle := trimPunctuation(m.first("245", 'b')); subtitle != "" {
+		title += ": " + subtitle
+	}
+	row.Title = title
+
+	row.Description = strings.TrimSpace(m.first("520", 'a'))
+	for _, subject := range append(m.all("650", 'a'), m.all("655", 'a')...) {
+		if subject = trimPunctuation(subject); subject != "" {
+			row.Subjects = append(row.Subjects, subject)
+		}
+	}
+	row.CoverImage = strings.TrimSpace(m.first("856", 'u'))
+	return row
+}
+
+// credits reads the 700/710 added entries. An entry without a relator is a
+// co-author, one whose relators are all roles we do not credit, such as an
+// illustrator, is left out.
+func (m marcRecord) credits() []authors.Credit {
+	var credits []authors.Credit
+	for _, tag := range []string{"700", "710"} {
+		for _, field := range m[tag] {
+			if len(field['a']) == 0 {
+				continue
+			}
+			name := trimPunctuation(field['a'][0])
+			terms := append(append([]string{}, field['e']...), field['4']...)
+			if len(terms) == 0 {
+				credits = append(credits, authors.Credit{Name: name, Role: model.ContributorRoleAuthor})
+				continue
+			}
+			seen := map[model.ContributorRole]bool{}
+			for _, term := range terms {
+				role, ok := relators[strings.ToLower(trimPunctuation(term))]
+				if ok && !seen[role] {
+					seen[role] = true
+					credits = append(credits, authors.Credit{Name: name, Role: role})
+				}
+			}
+		}
+	}
+	return credits
+}
+
+// trimPunctuation drops the ISBD punctuation MARC cataloguers end fields with
+func trimPunctuation(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,."))
+}
+
+type marcReader struct {
+	reader *bufio.Reader
+	n      int
+}
+
+// NewMARCReader reads binary MARC21 (ISO 2709) records
+func NewMARCReader(r io.Reader) Rows {
+	return &marcReader{reader: bufio.NewReader(r)}
+}
+
+func (m *marcReader) Next() (Row, error) {
+	m.n++
+	data, err := m.reader.ReadBytes(recordTerminator)
+	if err == io.EOF && len(strings.TrimSpace(string(data))) == 0 {
+		return Row{}, io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return Row{}, fmt.Errorf("failed to read MARC record %d: %w", m.n, err)
+	}
+
+	record, err := decodeISO2709(data)
+	if err != nil {
+		return Row{}, fmt.Errorf("invalid MARC record %d: %w", m.n, err)
+	}
+	return record.row(m.n), nil
+}
+
+func decodeISO2709(data []byte) (marcRecord, error) {
+	if len(data) < leaderLength {
+		return nil, fmt.Errorf("record is shorter than its leader")
+	}
+	baseAddress, err := strconv.Atoi(string(data[12:17]))
+	if err != nil || baseAddress <= leaderLength || baseAddress > len(data) {
+		return nil, fmt.Errorf("invalid base address of data")
+	}
+
+	record := marcRecord{}
+	directory := data[leaderLength : baseAddress-1]
+	for i := 0; i+directoryEntry <= len(directory); i += directoryEntry {
+		entry := directory[i : i+directoryEntry]
+		tag := string(entry[0:3])
+		length, err := strconv.Atoi(string(entry[3:7]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid length for field %s", tag)
+		}
+		start, err := strconv.Atoi(string(entry[7:12]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid start for field %s", tag)
+		}
+		if baseAddress+start+length > len(data) {
+			return nil, fmt.Errorf("field %s runs past the end of the record", tag)
+		}
+
+		// control fields (00X) have no indicators or subfields
+		if strings.HasPrefix(tag, "00") {
+			continue
+		}
+		field := strings.TrimRight(string(data[baseAddress+start:baseAddress+start+length]), string(rune(fieldTerminator)))
+		subfields := map[byte][]string{}
+		// the first part holds the two indicators
+		for _, subfield := range strings.Split(field, string(rune(subfieldDelimiter)))[1:] {
+			if subfield == "" {
+				continue
+			}
+			subfields[subfield[0]] = append(subfields[subfield[0]], subfield[1:])
+		}
+		record[tag] = append(record[tag], subfields)
+	}
+	return record, nil
+}
+
+type marcXMLReader struct {
+	decoder *xml.Decoder
+	n       int
+}
+
+// NewMARCXMLReader reads MARC21 slim XML, either a <collection> of records or
+// a single <record>
+func NewMARCXMLReader(r io.Reader) Rows {
+	return &marcXMLReader{decoder: xml.NewDecoder(r)}
+}
+
+func (m *marcXMLReader) Next() (Row, error) {
+	for {
+		token, err := m.decoder.Token()
+		if err == io.EOF {
+			return Row{}, io.EOF
+		}
+		if err != nil {
+			return Row{}, fmt.Errorf("failed to read MARCXML: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		m.n++
+
+		var element struct {
+			DataFields []struct {
+				Tag       string `xml:"tag,attr"`
+				Subfields []struct {
+					Code  string `xml:"code,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"subfield"`
+			} `xml:"datafield"`
+		}
+		if err := m.decoder.DecodeElement(&element, &start); err != nil {
+			return Row{}, fmt.Errorf("invalid MARCXML record %d: %w", m.n, err)
+		}
+
+		record := marcRecord{}
+		for _, datafield := range element.DataFields {
+			subfields := map[byte][]string{}
+			for _, subfield := range datafield.Subfields {
+				if subfield.Code == "" {
+					continue
+				}
+				subfields[subfield.Code[0]] = append(subfields[subfield.Code[0]], subfield.Value)
+			}
+			record[datafield.Tag] = append(record[datafield.Tag], subfields)
+		}
+		return record.row(m.n), nil
+	}
+}
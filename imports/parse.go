@@ -0,0 +1,197 @@
+package imports
+
+import (
+	"bmsgql/authors"
+	"bmsgql/graph/model"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Row is one catalogue record read from an import file, before validation
+type Row struct {
+	// Line is the 1-based position of the record in the file, the line for CSV
+	// and the record number for MARC
+	Line        int
+	Title       string
+	Author      string
+	Isbn        string
+	Category    string
+	Description string
+	CoverImage  string
+	Subjects    []string
+	// Credits are the names credited besides Author, the added entries of
+	// MARC records
+	Credits []authors.Credit
+}
+
+// csvColumns maps accepted CSV header names to Row fields
+var csvColumns = map[string]string{
+	"title":       "title",
+	"author":      "author",
+	"authors":     "author",
+	"isbn":        "isbn",
+	"isbn13":      "isbn",
+	"category":    "category",
+	"genre":       "category",
+	"description": "description",
+	"summary":     "description",
+	"coverimage":  "coverImage",
+	"cover":       "coverImage",
+	"subjects":    "subjects",
+}
+
+// Rows reads the records of an import file one at a time, so a large file
+// is never held in memory
+type Rows interface {
+	// Next returns the next record, or io.EOF after the last one
+	Next() (Row, error)
+}
+
+// NewReader reads the records of an import file in format
+func NewReader(r io.Reader, format model.ImportFormat) (Rows, error) {
+	switch format {
+	case model.ImportFormatCSV:
+		return NewCSVReader(r)
+	case model.ImportFormatMarc:
+		return NewMARCReader(r), nil
+	case model.ImportFormatMarcxml:
+		return NewMARCXMLReader(r), nil
+	}
+	return nil, fmt.Errorf("unsupported import format %s", format)
+}
+
+// Count reads an import file through to check every record can be parsed and
+// returns how many there are
+func Count(r io.Reader, format model.ImportFormat) (int, error) {
+	rows, err := NewReader(r, format)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		_, err := rows.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// DetectFormat guesses the format of a file from its extension, then from its
+// first bytes
+func DetectFormat(filename string, head []byte) model.ImportFormat {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return model.ImportFormatCSV
+	case ".mrc", ".marc":
+		return model.ImportFormatMarc
+	case ".xml":
+		return model.ImportFormatMarcxml
+	}
+
+	head = bytes.TrimLeft(head, "\ufeff \t\r\n")
+	if bytes.HasPrefix(head, []byte("<")) {
+		return model.ImportFormatMarcxml
+	}
+	// an ISO 2709 record starts with its five digit length
+	if len(head) >= 5 && isDigits(head[:5]) {
+		return model.ImportFormatMarc
+	}
+	return model.ImportFormatCSV
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+// NewCSVReader reads a CSV file with a header row. Columns are matched by
+// name, case and separators ignored, unknown columns are skipped. The header
+// is read right away.
+func NewCSVReader(r io.Reader) (Rows, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("CSV file is empty")
+		}
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		key := strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "", "\ufeff", "").Replace(name))
+		columns[i] = csvColumns[key]
+	}
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+// Next skips blank lines
+func (c *csvReader) Next() (Row, error) {
+	for {
+		record, err := c.reader.Read()
+		if err == io.EOF {
+			return Row{}, io.EOF
+		}
+		if err != nil {
+			// a csv.ParseError names the line itself
+			return Row{}, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := c.reader.FieldPos(0)
+
+		row := Row{Line: line}
+		for i, value := range record {
+			if i >= len(c.columns) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			switch c.columns[i] {
+			case "title":
+				row.Title = value
+			case "author":
+				row.Author = value
+			case "isbn":
+				row.Isbn = value
+			case "category":
+				row.Category = value
+			case "description":
+				row.Description = value
+			case "coverImage":
+				row.CoverImage = value
+			case "subjects":
+				for _, subject := range strings.Split(value, ";") {
+					if subject = strings.TrimSpace(subject); subject != "" {
+						row.Subjects = append(row.Subjects, subject)
+					}
+				}
+			}
+		}
+		if !row.blank() {
+			return row, nil
+		}
+	}
+}
+
+func (row Row) blank() bool {
+	return row.Title == "" && row.Author == "" && row.Isbn == "" && row.Category == "" &&
+		row.Description == "" && row.CoverImage == "" && len(row.Subjects) == 0
+}
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
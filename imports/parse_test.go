@@ -0,0 +1,235 @@
+package imports
+
+import (
+	"bmsgql/authors"
+	"bmsgql/graph/model"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll collects the rows of rows, or the first error
+func readAll(rows Rows) ([]Row, error) {
+	var all []Row
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return all, nil
+		}
+		if err != nil {
+			return all, err
+		}
+		all = append(all, row)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	file := "\ufeffTitle,Authors,ISBN-13,Genre,Summary,Cover,Subjects,Shelf\n" +
+		"Dune,Frank Herbert,978-0-441-17271-9,Science fiction,Spice and sand,https://example.com/dune.jpg,\"Arrakis; Ecology\",A3\n" +
+		",,,,,,,\n" +
+		"\n" +
+		"\"Good Omens\",\"Terry Pratchett & Neil Gaiman\",0-575-04800-X\n"
+	rows, err := NewCSVReader(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readAll(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{
+			Line: 2, Title: "Dune", Author: "Frank Herbert", Isbn: "978-0-441-17271-9", Category: "Science fiction",
+			Description: "Spice and sand", CoverImage: "https://example.com/dune.jpg", Subjects: []string{"Arrakis", "Ecology"},
+		},
+		{Line: 5, Title: "Good Omens", Author: "Terry Pratchett & Neil Gaiman", Isbn: "0-575-04800-X"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestCSVReaderErrors(t *testing.T) {
+	if _, err := NewCSVReader(strings.NewReader("")); err == nil {
+		t.Error("empty file: no error")
+	}
+	rows, err := NewCSVReader(strings.NewReader("title,isbn\nDune,\"9780441172719\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAll(rows); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("unterminated quote: got %v, want an error on line 2", err)
+	}
+}
+
+// marcField is a data field of a test record, indicators then subfields
+type marcField struct {
+	tag       string
+	subfields []string
+}
+
+// iso2709 encodes fields as a binary MARC record
+func iso2709(fields ...marcField) string {
+	var directory, data strings.Builder
+	for _, field := range fields {
+		value := "  "
+		for _, subfield := range field.subfields {
+			value += string(rune(subfieldDelimiter)) + subfield
+		}
+		value += string(rune(fieldTerminator))
+		fmt.Fprintf(&directory, "%s%04d%05d", field.tag, len(value), data.Len())
+		data.WriteString(value)
+	}
+	directory.WriteByte(fieldTerminator)
+	base := leaderLength + directory.Len()
+	length := base + data.Len() + 1
+	leader := fmt.Sprintf("%05dnam a22%05d a 4500", length, base)
+	return leader + directory.String() + data.String() + string(rune(recordTerminator))
+}
+
+func TestMARCReader(t *testing.T) {
+	file := iso2709(
+		marcField{"020", []string{"a9780441172719 (pbk.)"}},
+		marcField{"100", []string{"aHerbert, Frank,"}},
+		marcField{"245", []string{"aDune /", "cFrank Herbert."}},
+		marcField{"520", []string{"aSpice and sand."}},
+		marcField{"650", []string{"aScience fiction."}},
+		marcField{"655", []string{"aAdventure stories."}},
+		marcField{"700", []string{"aAnderson, Kevin J."}},
+		marcField{"700", []string{"aHerbert, Brian,", "eeditor,", "etranslator."}},
+		marcField{"700", []string{"aSchoenherr, John,", "eillustrator."}},
+		marcField{"700", []string{"aMoreau, Jeanne,", "4trl"}},
+		marcField{"710", []string{"aFolio Society,", "eed."}},
+		marcField{"856", []string{"uhttps://example.com/dune.jpg"}},
+	) + iso2709(
+		marcField{"020", []string{"a0575048006"}},
+		marcField{"110", []string{"aMonty Python (Comedy troupe)."}},
+		marcField{"245", []string{"aGood omens :", "bthe nice and accurate prophecies."}},
+	) + "\n"
+
+	rows, err := readAll(NewMARCReader(strings.NewReader(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{
+			Line: 1, Title: "Dune", Author: "Herbert, Frank", Isbn: "9780441172719", Description: "Spice and sand.",
+			Subjects: []string{"Science fiction", "Adventure stories"}, CoverImage: "https://example.com/dune.jpg",
+			Credits: []authors.Credit{
+				{Name: "Anderson, Kevin J", Role: model.ContributorRoleAuthor},
+				{Name: "Herbert, Brian", Role: model.ContributorRoleEditor},
+				{Name: "Herbert, Brian", Role: model.ContributorRoleTranslator},
+				{Name: "Moreau, Jeanne", Role: model.ContributorRoleTranslator},
+				{Name: "Folio Society", Role: model.ContributorRoleEditor},
+			},
+		},
+		{Line: 2, Title: "Good omens: the nice and accurate prophecies", Author: "Monty Python (Comedy troupe)", Isbn: "0575048006"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %+v\nwant %+v", rows, want)
+	}
+}
+
+func TestMARCReaderErrors(t *testing.T) {
+	tests := []string{
+		"short" + string(rune(recordTerminator)),
+		"00050nam a22abcde a 4500" + strings.Repeat(" ", 26) + string(rune(recordTerminator)),
+	}
+	for _, file := range tests {
+		if _, err := readAll(NewMARCReader(strings.NewReader(file))); err == nil || !strings.Contains(err.Error(), "record 1") {
+			t.Errorf("%q: got %v, want an error for record 1", file, err)
+		}
+	}
+}
+
+func TestMARCXMLReader(t *testing.T) {
+	file := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <controlfield tag="001">1</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780441172719</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Herbert, Frank.</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Dune.</subfield></datafield>
+    <datafield tag="650" ind1=" " ind2="0"><subfield code="a">Science fiction</subfield></datafield>
+    <datafield tag="700" ind1="1" ind2=" "><subfield code="a">Herbert, Brian,</subfield><subfield code="e">editor.</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Untitled</subfield></datafield>
+  </record>
+</collection>`
+	rows, err := readAll(NewMARCXMLReader(strings.NewReader(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{
+			Line: 1, Title: "Dune", Author: "Herbert, Frank", Isbn: "9780441172719", Subjects: []string{"Science fiction"},
+			Credits: []authors.Credit{{Name: "Herbert, Brian", Role: model.ContributorRoleEditor}},
+		},
+		{Line: 2, Title: "Untitled"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %+v\nwant %+v", rows, want)
+	}
+
+	if _, err := readAll(NewMARCXMLReader(strings.NewReader("<collection><record>"))); err == nil {
+		t.Error("truncated MARCXML: no error")
+	}
+}
+
+func TestCount(t *testing.T) {
+	n, err := Count(strings.NewReader("title\nA\n\nB\nC\n"), model.ImportFormatCSV)
+	if err != nil || n != 3 {
+		t.Errorf("Count = %d, %v, want 3", n, err)
+	}
+	if _, err := Count(strings.NewReader("title\n\"A\n"), model.ImportFormatCSV); err == nil {
+		t.Error("Count of a broken file: no error")
+	}
+	if _, err := Count(strings.NewReader(""), model.ImportFormat("XLSX")); err == nil {
+		t.Error("Count of an unknown format: no error")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		head     string
+		want     model.ImportFormat
+	}{
+		{"books.CSV", "<record>", model.ImportFormatCSV},
+		{"books.mrc", "title,author", model.ImportFormatMarc},
+		{"books.xml", "", model.ImportFormatMarcxml},
+		{"upload", "\ufeff  <?xml version=\"1.0\"?>", model.ImportFormatMarcxml},
+		{"upload", "01234nam a2200", model.ImportFormatMarc},
+		{"upload", "title,author", model.ImportFormatCSV},
+		{"upload", "", model.ImportFormatCSV},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename, []byte(tt.head)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %s, want %s", tt.filename, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestParseCategory(t *testing.T) {
+	tests := []struct {
+		row     Row
+		want    model.BookCategory
+		wantErr bool
+	}{
+		{Row{Category: "Science fiction"}, model.BookCategoryScienceFiction, false},
+		{Row{Category: "SCIENCE_FICTION"}, model.BookCategoryScienceFiction, false},
+		{Row{Category: "self-help"}, model.BookCategorySelfHelp, false},
+		{Row{Subjects: []string{"Detective and mystery stories"}}, model.BookCategoryMystery, false},
+		{Row{}, model.BookCategoryNonFiction, false},
+		{Row{Category: "Poetry"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseCategory(tt.row)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseCategory(%+v) = %s, %v, want %s", tt.row, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package isbn validates ISBN-10 and ISBN-13 numbers and converts them to the
+// canonical form books are stored with: ISBN-13 digits without separators.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid ISBN")
+
+// Normalize checks the checksum of an ISBN-10 or ISBN-13, ignoring hyphens and
+// spaces, and returns it as ISBN-13
+func Normalize(value string) (string, error) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(value)))
+
+	switch len(digits) {
+	case 10:
+		if !valid10(digits) {
+			return "", ErrInvalid
+		}
+		isbn13 := "978" + digits[:9]
+		return isbn13 + string(checkDigit13(isbn13)), nil
+	case 13:
+		if !valid13(digits) {
+			return "", ErrInvalid
+		}
+		return digits, nil
+	}
+	return "", ErrInvalid
+}
+
+func valid10(digits string) bool {
+	sum := 0
+	for i, c := range digits {
+		var d int
+		switch {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += d * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+func valid13(digits string) bool {
+	if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return checkDigit13(digits[:12]) == digits[12]
+}
+
+// checkDigit13 computes the check digit for the first 12 digits of an ISBN-13
+func checkDigit13(digits string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,45 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"9780306406157", "9780306406157"},
+		{"978-0-306-40615-7", "9780306406157"},
+		{" 978 0 306 40615 7 ", "9780306406157"},
+		{"0306406152", "9780306406157"},
+		{"0-306-40615-2", "9780306406157"},
+		{"080442957X", "9780804429573"},
+		{"080442957x", "9780804429573"},
+		{"9791234567896", "9791234567896"},
+		// wrong check digits
+		{"9780306406158", ""},
+		{"0306406153", ""},
+		// X only stands for ten as the ISBN-10 check digit
+		{"X306406152", ""},
+		// not a Bookland prefix
+		{"1234567890128", ""},
+		{"97803064061", ""},
+		{"97803064061570", ""},
+		{"978030640615A", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.value)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Normalize(%q) = %q, %v, want ErrInvalid", tt.value, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package loaders
+
+import (
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type contextKey string
+
+const loadersKey contextKey = "dataloaders"
+
+// Loaders batches lookups of related documents made while resolving a single request
+type Loaders struct {
+	UserLoader   *dataloader.Loader[string, *model.User]
+	BookLoader   *dataloader.Loader[string, *model.Book]
+	ReviewLoader *dataloader.Loader[string, *model.Review]
+	AuthorLoader *dataloader.Loader[string, *model.Author]
+	WorkLoader   *dataloader.Loader[string, *model.Work]
+	SeriesLoader *dataloader.Loader[string, *model.Series]
+	TagLoader    *dataloader.Loader[string, *model.Tag]
+	GenreLoader  *dataloader.Loader[string, *model.Genre]
+}
+
+// NewLoaders creates a fresh set of loaders, their caches live as long as the request
+func NewLoaders() *Loaders {
+	return &Loaders{
+		UserLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.User] {
+			return fetchByIDs(ctx, "Users", keys, func(u *model.User) string { return u.ID })
+		}),
+		BookLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.Book] {
+			return fetchByIDs(ctx, "Books", keys, func(b *model.Book) string { return b.ID })
+		}),
+		ReviewLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.Review] {
+			return fetchByIDs(ctx, "Reviews", keys, func(r *model.Review) string { return r.ID })
+		}),
+		AuthorLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.Author] {
+			return fetchByIDs(ctx, "Authors", keys, func(a *model.Author) string { return a.ID })
+		}),
+		WorkLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.Work] {
+			return fetchByIDs(ctx, "Works", keys, func(w *model.Work) string { return w.ID })
+		}),
+		SeriesLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.Series] {
+			return fetchByIDs(ctx, "Series", keys, func(s *model.Series) string { return s.ID })
+		}),
+		TagLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.Tag] {
+			return fetchByIDs(ctx, "Tags", keys, func(t *model.Tag) string { return t.ID })
+		}),
+		GenreLoader: dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[*model.Genre] {
+			return fetchByIDs(ctx, "Genres", keys, func(g *model.Genre) string { return g.ID })
+		}),
+	}
+}
+
+// Middleware attaches a new set of loaders to every request. WebSocket
+// connections are skipped so long-lived subscriptions never serve stale cache entries.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), loadersKey, NewLoaders())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// For retrieves the loaders from context, falling back to unshared loaders
+// when the request did not pass through Middleware
+func For(ctx context.Context) *Loaders {
+	loaders, ok := ctx.Value(loadersKey).(*Loaders)
+	if !ok {
+		return NewLoaders()
+	}
+	return loaders
+}
+
+// GetUser loads a user by ID
+func GetUser(ctx context.Context, userID string) (*model.User, error) {
+	return For(ctx).UserLoader.Load(ctx, userID)()
+}
+
+// GetBook loads a book by ID
+func GetBook(ctx context.Context, bookID string) (*model.Book, error) {
+	return For(ctx).BookLoader.Load(ctx, bookID)()
+}
+
+// GetAuthor loads an author by ID
+func GetAuthor(ctx context.Context, authorID string) (*model.Author, error) {
+	return For(ctx).AuthorLoader.Load(ctx, authorID)()
+}
+
+// GetWork loads a work by ID
+func GetWork(ctx context.Context, workID string) (*model.Work, error) {
+	return For(ctx).WorkLoader.Load(ctx, workID)()
+}
+
+// GetSeries loads a series by ID
+func GetSeries(ctx context.Context, seriesID string) (*model.Series, error) {
+	return For(ctx).SeriesLoader.Load(ctx, seriesID)()
+}
+
+// GetGenre loads a genre by ID
+func GetGenre(ctx context.Context, genreID string) (*model.Genre, error) {
+	return For(ctx).GenreLoader.Load(ctx, genreID)()
+}
+
+// GetReviews loads several reviews by ID, skipping the ones that no longer exist
+func GetReviews(ctx context.Context, reviewIDs []string) ([]*model.Review, error) {
+	return loadMany(ctx, For(ctx).ReviewLoader, reviewIDs)
+}
+
+// GetTags loads several tags by ID, skipping the ones that no longer exist
+func GetTags(ctx context.Context, tagIDs []string) ([]*model.Tag, error) {
+	return loadMany(ctx, For(ctx).TagLoader, tagIDs)
+}
+
+// GetGenres loads several genres by ID, skipping the ones that no longer exist
+func GetGenres(ctx context.Context, genreIDs []string) ([]*model.Genre, error) {
+	return loadMany(ctx, For(ctx).GenreLoader, genreIDs)
+}
+
+func loadMany[T any](ctx context.Context, loader *dataloader.Loader[string, *T], keys []string) ([]*T, error) {
+	docs, errs := loader.LoadMany(ctx, keys)()
+
+	found := []*T{}
+	for i, doc := range docs {
+		if i < len(errs) && errs[i] != nil {
+			if errors.Is(errs[i], ErrNotFound) {
+				continue
+			}
+			return nil, errs[i]
+		}
+		found = append(found, doc)
+	}
+	return found, nil
+}
+
+// fetchByIDs runs a single $in query for all keys and returns the results in key order
+func fetchByIDs[T any](ctx context.Context, collection string, keys []string, idOf func(*T) string) []*dataloader.Result[*T] {
+	results := make([]*dataloader.Result[*T], len(keys))
+
+	objIDs := make([]primitive.ObjectID, 0, len(keys))
+	for i, key := range keys {
+		objID, err := primitive.ObjectIDFromHex(key)
+		if err != nil {
+			results[i] = &dataloader.Result[*T]{Error: fmt.Errorf("invalid ID %q", key)}
+			continue
+		}
+		objIDs = append(objIDs, objID)
+	}
+
+	docs := make(map[string]*T, len(objIDs))
+	cursor, err := database.DB.Collection(collection).Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+	if err == nil {
+		defer cursor.Close(ctx)
+		for cursor.Next(ctx) {
+			var doc T
+			if err = cursor.Decode(&doc); err != nil {
+				break
+			}
+			docs[idOf(&doc)] = &doc
+		}
+		if err == nil {
+			err = cursor.Err()
+		}
+	}
+
+	for i, key := range keys {
+		if results[i] != nil {
+			continue
+		}
+		if err != nil {
+			results[i] = &dataloader.Result[*T]{Error: fmt.Errorf("failed to fetch %s: %w", collection, err)}
+			continue
+		}
+		doc, ok := docs[key]
+		if !ok {
+			results[i] = &dataloader.Result[*T]{Error: fmt.Errorf("%s %s: %w", collection, key, ErrNotFound)}
+			continue
+		}
+		results[i] = &dataloader.Result[*T]{Data: doc}
+	}
+	return results
+}
@@ -0,0 +1,188 @@
+package notifications
+
+import (
+	"bmsgql/database"
+	"bmsgql/delivery"
+	"bmsgql/graph/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Delivery statuses of the NotificationDeliveries log
+const (
+	DeliveryPending = "PENDING"
+	DeliverySent    = "SENT"
+	DeliveryFailed  = "FAILED"
+)
+
+const deliveryTimeout = 30 * time.Second
+
+// notifiers is built lazily so the environment from .env is loaded first
+var notifiers = sync.OnceValue(delivery.NotifiersFromEnv)
+
+// deliveryEntry is a document of the NotificationDeliveries collection, one per
+// notification and channel, holding the rendered message and its retry state
+type deliveryEntry struct {
+	ID             primitive.ObjectID        `bson:"_id"`
+	NotificationID primitive.ObjectID        `bson:"notificationId"`
+	UserID         primitive.ObjectID        `bson:"userId"`
+	Channel        model.NotificationChannel `bson:"channel"`
+	Type           string                    `bson:"type"`
+	To             string                    `bson:"to"`
+	Subject        string                    `bson:"subject"`
+	Body           string                    `bson:"body"`
+	Status         string                    `bson:"status"`
+	Attempts       int                       `bson:"attempts"`
+	LastError      string                    `bson:"lastError,omitempty"`
+	NextAttemptAt  time.Time                 `bson:"nextAttemptAt"`
+	SentAt         *time.Time                `bson:"sentAt,omitempty"`
+	CreatedAt      time.Time                 `bson:"createdAt"`
+}
+
+// dispatch logs a delivery for every channel the user opted into and sends them
+// in the background, the in-app notification is already stored at this point
+func dispatch(ctx context.Context, notification *model.Notification, settings *model.NotificationSettings) error {
+	DeliveryCollection := database.DB.Collection("NotificationDeliveries")
+
+	if len(settings.Channels) == 0 {
+		return nil
+	}
+
+	var user model.User
+	err := database.DB.Collection("Users").FindOne(ctx, bson.M{"_id": notification.UserID}).Decode(&user)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	subject, body, err := render(notification.Type, templateData{Name: user.Name, Message: notification.Message})
+	if err != nil {
+		return fmt.Errorf("failed to render notification: %w", err)
+	}
+
+	notificationId, _ := primitive.ObjectIDFromHex(notification.ID)
+	now := time.Now()
+	for _, channel := range settings.Channels {
+		entry := deliveryEntry{
+			ID:             primitive.NewObjectID(),
+			NotificationID: notificationId,
+			UserID:         notification.UserID,
+			Channel:        channel,
+			Type:           notification.Type,
+			Subject:        subject,
+			Body:           body,
+			Status:         DeliveryPending,
+			// the immediate attempt below owns the entry until then, retries pick it up afterwards
+			NextAttemptAt: now.Add(retryBaseDelay),
+			CreatedAt:     now,
+		}
+		switch channel {
+		case model.NotificationChannelEmail:
+			entry.To = user.Email
+		case model.NotificationChannelWebhook:
+			if settings.WebhookURL != nil {
+				entry.To = *settings.WebhookURL
+			}
+		}
+
+		if _, err := DeliveryCollection.InsertOne(ctx, entry); err != nil {
+			return fmt.Errorf("failed to log delivery: %w", err)
+		}
+
+		go func(entry deliveryEntry) {
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			defer cancel()
+			if err := attempt(ctx, entry); err != nil {
+				log.Printf("delivery %s: %v", entry.ID.Hex(), err)
+			}
+		}(entry)
+	}
+	return nil
+}
+
+// RetryFailedDeliveries sends again the deliveries whose backoff has elapsed
+func RetryFailedDeliveries(ctx context.Context) error {
+	DeliveryCollection := database.DB.Collection("NotificationDeliveries")
+
+	cursor, err := DeliveryCollection.Find(ctx,
+		bson.M{"status": DeliveryPending, "nextAttemptAt": bson.M{"$lte": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).SetLimit(100),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fetch deliveries: %w", err)
+	}
+	var entries []deliveryEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return fmt.Errorf("failed to decode deliveries: %w", err)
+	}
+
+	for _, entry := range entries {
+		// claim the entry so a concurrent run does not send it twice
+		result, err := DeliveryCollection.UpdateOne(ctx,
+			bson.M{"_id": entry.ID, "status": DeliveryPending, "nextAttemptAt": entry.NextAttemptAt},
+			bson.M{"$set": bson.M{"nextAttemptAt": time.Now().Add(deliveryTimeout * 2)}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to claim delivery: %w", err)
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+		err = attempt(attemptCtx, entry)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt sends one delivery and records the outcome. Send failures are stored
+// as retry state, only failures to update the log are returned.
+func attempt(ctx context.Context, entry deliveryEntry) error {
+	DeliveryCollection := database.DB.Collection("NotificationDeliveries")
+
+	var sendErr error
+	notifier, ok := notifiers()[entry.Channel]
+	if !ok {
+		sendErr = fmt.Errorf("no notifier for channel %s", entry.Channel)
+	} else {
+		sendErr = notifier.Send(ctx, delivery.Message{
+			NotificationID: entry.NotificationID.Hex(),
+			Type:           entry.Type,
+			To:             entry.To,
+			Subject:        entry.Subject,
+			Body:           entry.Body,
+			CreatedAt:      entry.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	now := time.Now()
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+	switch {
+	case sendErr == nil:
+		update["$set"] = bson.M{"status": DeliverySent, "sentAt": now}
+	case entry.Attempts+1 >= maxDeliveryAttempts || sendErr == delivery.ErrNoRecipient || errors.Is(sendErr, delivery.ErrForbiddenURL):
+		update["$set"] = bson.M{"status": DeliveryFailed, "lastError": sendErr.Error()}
+	default:
+		update["$set"] = bson.M{
+			"lastError":     sendErr.Error(),
+			"nextAttemptAt": now.Add(retryBaseDelay << entry.Attempts),
+		}
+	}
+
+	_, err := DeliveryCollection.UpdateOne(context.WithoutCancel(ctx), bson.M{"_id": entry.ID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package notifications
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/delivery"
+	"bmsgql/graph/model"
+	"bmsgql/paging"
+	"bmsgql/pubsub"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Notification types, Enabled decides which of the user's NotificationSettings gates each one
+const (
+	TypeDueDateReminder    = "DUE_DATE_REMINDER"
+	TypeLoanOverdue        = "LOAN_OVERDUE"
+	TypeReservationReady   = "RESERVATION_READY"
+	TypeReservationExpired = "RESERVATION_EXPIRED"
+	TypeNewArrival         = "NEW_ARRIVAL"
+	TypeCommunityActivity  = "COMMUNITY_ACTIVITY"
+)
+
+// Notify persists a notification for a user and pushes it to their live subscriptions.
+// It returns nil without error when the user turned off this type of notification.
+func Notify(ctx context.Context, userID primitive.ObjectID, notificationType string, message string) (*model.Notification, error) {
+	NotificationCollection := database.DB.Collection("Notifications")
+
+	settings, err := settingsFor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !Enabled(settings, notificationType) {
+		return nil, nil
+	}
+
+	createdAt := time.Now().Format(time.RFC3339)
+	read := false
+	newNotification, err := NotificationCollection.InsertOne(ctx, bson.M{
+		"userId":    userID,
+		"type":      notificationType,
+		"message":   message,
+		"read":      read,
+		"createdAt": createdAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	insertedId := newNotification.InsertedID.(primitive.ObjectID)
+
+	notification := &model.Notification{
+		ID:        insertedId.Hex(),
+		Type:      notificationType,
+		Message:   message,
+		CreatedAt: &createdAt,
+		Read:      &read,
+		UserID:    userID,
+	}
+
+	pubsub.Publish(pubsub.NotificationTopic(userID.Hex()), notification)
+	if err := dispatch(ctx, notification, settings); err != nil {
+		log.Printf("failed to dispatch notification %s: %v", notification.ID, err)
+	}
+	return notification, nil
+}
+
+// Enabled reports whether settings allow notifications of the given type
+func Enabled(settings *model.NotificationSettings, notificationType string) bool {
+	var setting *bool
+	switch notificationType {
+	case TypeDueDateReminder, TypeLoanOverdue:
+		setting = settings.DueDateReminders
+	case TypeNewArrival:
+		setting = settings.NewArrivals
+	case TypeCommunityActivity:
+		setting = settings.CommunityActivity
+	}
+	return setting == nil || *setting
+}
+
+func Notifications(ctx context.Context, unreadOnly *bool, limit *int, offset *int) ([]*model.Notification, error) {
+	NotificationCollection := database.DB.Collection("Notifications")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"userId": userObjId}
+	if unreadOnly != nil && *unreadOnly {
+		filter["read"] = false
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64(paging.Offset(offset))).
+		SetLimit(int64(paging.Limit(limit)))
+
+	notifications := []*model.Notification{}
+	cursor, err := NotificationCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch notifications: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var notification model.Notification
+		if err := cursor.Decode(&notification); err != nil {
+			return nil, fmt.Errorf("failed to decode notification: %w", err)
+		}
+		notifications = append(notifications, &notification)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+	return notifications, nil
+}
+
+func UnreadNotificationCount(ctx context.Context) (int, error) {
+	NotificationCollection := database.DB.Collection("Notifications")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := NotificationCollection.CountDocuments(ctx, bson.M{"userId": userObjId, "read": false})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return int(count), nil
+}
+
+func MarkNotificationRead(ctx context.Context, id string) (*model.Notification, error) {
+	NotificationCollection := database.DB.Collection("Notifications")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	notificationId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification ID")
+	}
+
+	var notification model.Notification
+	err = NotificationCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": notificationId, "userId": userObjId},
+		bson.M{"$set": bson.M{"read": true}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&notification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("notification not found")
+		}
+		return nil, fmt.Errorf("failed to update notification: %w", err)
+	}
+	return &notification, nil
+}
+
+func MarkAllNotificationsRead(ctx context.Context) (bool, error) {
+	NotificationCollection := database.DB.Collection("Notifications")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = NotificationCollection.UpdateMany(ctx,
+		bson.M{"userId": userObjId, "read": false},
+		bson.M{"$set": bson.M{"read": true}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update notifications: %w", err)
+	}
+	return true, nil
+}
+
+func NotificationSettings(ctx context.Context) (*model.NotificationSettings, error) {
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return settingsFor(ctx, userObjId)
+}
+
+func UpdateNotificationSettings(ctx context.Context, input model.NotificationSettingsInput) (*model.NotificationSettings, error) {
+	UserCollection := database.DB.Collection("Users")
+
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	updateSettings := bson.M{}
+	if input.DueDateReminders != nil {
+		updateSettings["notificationSettings.dueDateReminders"] = *input.DueDateReminders
+	}
+	if input.NewArrivals != nil {
+		updateSettings["notificationSettings.newArrivals"] = *input.NewArrivals
+	}
+	if input.NewArrivalsDigest != nil {
+		updateSettings["notificationSettings.newArrivalsDigest"] = *input.NewArrivalsDigest
+	}
+	if input.CommunityActivity != nil {
+		updateSettings["notificationSettings.communityActivity"] = *input.CommunityActivity
+	}
+	if input.Channels != nil {
+		updateSettings["notificationSettings.channels"] = input.Channels
+	}
+	if input.WebhookURL != nil {
+		if *input.WebhookURL != "" {
+			if err := delivery.CheckWebhookURL(*input.WebhookURL); err != nil {
+				return nil, fmt.Errorf("invalid webhook URL: %w", err)
+			}
+		}
+		updateSettings["notificationSettings.webhookUrl"] = *input.WebhookURL
+	}
+
+	if len(updateSettings) > 0 {
+		result, err := UserCollection.UpdateOne(ctx, bson.M{"_id": userObjId}, bson.M{"$set": updateSettings})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update notification settings: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			return nil, fmt.Errorf("user not found")
+		}
+	}
+
+	return settingsFor(ctx, userObjId)
+}
+
+// Received streams notifications created for the current user until ctx is done
+func Received(ctx context.Context) (<-chan *model.Notification, error) {
+	userObjId, err := auth.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pubsub.Stream[*model.Notification](ctx, pubsub.NotificationTopic(userObjId.Hex())), nil
+}
+
+// settingsFor loads a user's notification settings, every notification type is on unless turned off
+func settingsFor(ctx context.Context, userID primitive.ObjectID) (*model.NotificationSettings, error) {
+	UserCollection := database.DB.Collection("Users")
+
+	var user struct {
+		NotificationSettings *model.NotificationSettings `bson:"notificationSettings"`
+	}
+	err := UserCollection.FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"notificationSettings": 1}),
+	).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to find notification settings: %w", err)
+	}
+
+	settings := user.NotificationSettings
+	if settings == nil {
+		settings = &model.NotificationSettings{}
+	}
+	enabled := true
+	if settings.DueDateReminders == nil {
+		settings.DueDateReminders = &enabled
+	}
+	if settings.NewArrivals == nil {
+		settings.NewArrivals = &enabled
+	}
+	if settings.CommunityActivity == nil {
+		settings.CommunityActivity = &enabled
+	}
+	if settings.NewArrivalsDigest == nil {
+		digest := false
+		settings.NewArrivalsDigest = &digest
+	}
+	if settings.Channels == nil {
+		settings.Channels = []model.NotificationChannel{}
+	}
+	return settings, nil
+}
@@ -0,0 +1,97 @@
+package notifications
+
+import (
+	"bmsgql/database"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxDeliveryAttempts = 5
+	retryBaseDelay      = time.Minute
+)
+
+// pendingNotification is a notification that could not be created and waits in
+// the PendingNotifications collection to be retried by the scheduler
+type pendingNotification struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	UserID        primitive.ObjectID `bson:"userId"`
+	Type          string             `bson:"type"`
+	Message       string             `bson:"message"`
+	Attempts      int                `bson:"attempts"`
+	LastError     string             `bson:"lastError"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt"`
+}
+
+// NotifyWithRetry behaves like Notify, but queues the notification for a later
+// retry instead of returning an error when it cannot be created right now
+func NotifyWithRetry(ctx context.Context, userID primitive.ObjectID, notificationType string, message string) {
+	_, err := Notify(ctx, userID, notificationType, message)
+	if err == nil {
+		return
+	}
+
+	log.Printf("failed to notify %s, queueing for retry: %v", userID.Hex(), err)
+	_, err = database.DB.Collection("PendingNotifications").InsertOne(ctx, bson.M{
+		"userId":        userID,
+		"type":          notificationType,
+		"message":       message,
+		"attempts":      1,
+		"lastError":     err.Error(),
+		"nextAttemptAt": time.Now().Add(retryBaseDelay),
+	})
+	if err != nil {
+		log.Printf("failed to queue notification for %s: %v", userID.Hex(), err)
+	}
+}
+
+// RetryPending retries queued notifications whose backoff has elapsed. Entries are
+// dropped once they succeed or after maxDeliveryAttempts failures.
+func RetryPending(ctx context.Context) error {
+	PendingCollection := database.DB.Collection("PendingNotifications")
+
+	cursor, err := PendingCollection.Find(ctx,
+		bson.M{"nextAttemptAt": bson.M{"$lte": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).SetLimit(100),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pending notifications: %w", err)
+	}
+	var pending []pendingNotification
+	if err := cursor.All(ctx, &pending); err != nil {
+		return fmt.Errorf("failed to decode pending notifications: %w", err)
+	}
+
+	for _, p := range pending {
+		_, notifyErr := Notify(ctx, p.UserID, p.Type, p.Message)
+		if notifyErr == nil || p.Attempts+1 >= maxDeliveryAttempts {
+			if notifyErr != nil {
+				log.Printf("giving up on notification %s after %d attempts: %v", p.ID.Hex(), p.Attempts+1, notifyErr)
+			}
+			if _, err := PendingCollection.DeleteOne(ctx, bson.M{"_id": p.ID}); err != nil {
+				return fmt.Errorf("failed to remove pending notification: %w", err)
+			}
+			continue
+		}
+
+		// exponential backoff: 1m, 2m, 4m, ...
+		delay := retryBaseDelay << p.Attempts
+		_, err := PendingCollection.UpdateOne(ctx, bson.M{"_id": p.ID}, bson.M{
+			"$inc": bson.M{"attempts": 1},
+			"$set": bson.M{
+				"lastError":     notifyErr.Error(),
+				"nextAttemptAt": time.Now().Add(delay),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to reschedule pending notification: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"strings"
+	"text/template"
+)
+
+// messageTemplate renders the subject and body sent over delivery channels
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+type templateData struct {
+	Name    string
+	Message string
+}
+
+func newTemplate(subject string, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+var defaultTemplate = newTemplate(
+	"New notification from the library",
+	"Hi {{.Name}},\n\n{{.Message}}\n",
+)
+
+var templates = map[string]messageTemplate{
+	TypeDueDateReminder: newTemplate(
+		"Reminder: your loan is due soon",
+		"Hi {{.Name}},\n\n{{.Message}}. Please return it on time or renew it to avoid late fees.\n",
+	),
+	TypeLoanOverdue: newTemplate(
+		"Your loan is overdue",
+		"Hi {{.Name}},\n\n{{.Message}}. Please return it as soon as possible.\n",
+	),
+	TypeReservationReady: newTemplate(
+		"Your reserved book is ready",
+		"Hi {{.Name}},\n\n{{.Message}}. Visit the front desk to collect it.\n",
+	),
+	TypeReservationExpired: newTemplate(
+		"Your reservation expired",
+		"Hi {{.Name}},\n\n{{.Message}}. You can reserve it again at any time.\n",
+	),
+	TypeNewArrival: newTemplate(
+		"New arrivals in your favorite genres",
+		"Hi {{.Name}},\n\n{{.Message}}\n",
+	),
+	TypeCommunityActivity: newTemplate(
+		"New activity in the community",
+		"Hi {{.Name}},\n\n{{.Message}}\n",
+	),
+}
+
+// render fills the template of the notification type
+func render(notificationType string, data templateData) (string, string, error) {
+	tmpl, ok := templates[notificationType]
+	if !ok {
+		tmpl = defaultTemplate
+	}
+
+	var subject, body strings.Builder
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
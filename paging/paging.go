@@ -0,0 +1,24 @@
+// Package paging reads the limit and offset arguments of list queries.
+package paging
+
+const (
+	DefaultSize = 20
+	MaxSize     = 100
+)
+
+// Limit is the page size asked for, capped at MaxSize, or DefaultSize when it
+// is missing or not positive
+func Limit(limit *int) int {
+	if limit == nil || *limit <= 0 {
+		return DefaultSize
+	}
+	return min(*limit, MaxSize)
+}
+
+// Offset is the number of items to skip, zero when missing or negative
+func Offset(offset *int) int {
+	if offset == nil || *offset < 0 {
+		return 0
+	}
+	return *offset
+}
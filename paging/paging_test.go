@@ -0,0 +1,28 @@
+package paging
+
+import "testing"
+
+func TestLimitAndOffset(t *testing.T) {
+	n := func(v int) *int { return &v }
+	tests := []struct {
+		value  *int
+		limit  int
+		offset int
+	}{
+		{nil, DefaultSize, 0},
+		{n(0), DefaultSize, 0},
+		{n(-5), DefaultSize, 0},
+		{n(1), 1, 1},
+		{n(MaxSize), MaxSize, MaxSize},
+		{n(MaxSize + 1), MaxSize, MaxSize + 1},
+		{n(500), MaxSize, 500},
+	}
+	for _, tt := range tests {
+		if got := Limit(tt.value); got != tt.limit {
+			t.Errorf("Limit(%v) = %d, want %d", tt.value, got, tt.limit)
+		}
+		if got := Offset(tt.value); got != tt.offset {
+			t.Errorf("Offset(%v) = %d, want %d", tt.value, got, tt.offset)
+		}
+	}
+}
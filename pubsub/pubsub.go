@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before events are dropped
+const subscriberBuffer = 16
+
+// Broker is an in-process publish/subscribe hub used to feed GraphQL subscriptions
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan any]struct{}
+}
+
+var DefaultBroker = NewBroker()
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[string]map[chan any]struct{})}
+}
+
+// Subscribe registers a listener on topic and returns its channel with a function to unsubscribe
+func (b *Broker) Subscribe(topic string) (<-chan any, func()) {
+	ch := make(chan any, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[chan any]struct{})
+	}
+	b.subscribers[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[topic], ch)
+			if len(b.subscribers[topic]) == 0 {
+				delete(b.subscribers, topic)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish delivers payload to every subscriber of topic without blocking the publisher
+func (b *Broker) Publish(topic string, payload any) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+// Subscribe listens on topic of the default broker
+func Subscribe(topic string) (<-chan any, func()) {
+	return DefaultBroker.Subscribe(topic)
+}
+
+// Publish sends payload to topic on the default broker
+func Publish(topic string, payload any) {
+	DefaultBroker.Publish(topic, payload)
+}
+
+// Stream subscribes to topic and forwards payloads of type T until ctx is done
+func Stream[T any](ctx context.Context, topic string) <-chan T {
+	events, unsubscribe := Subscribe(topic)
+	out := make(chan T, 1)
+
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-events:
+				payload, ok := event.(T)
+				if !ok {
+					continue
+				}
+				select {
+				case out <- payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,16 @@
+package pubsub
+
+// DiscussionTopic carries replies added to a discussion
+func DiscussionTopic(discussionID string) string {
+	return "discussion:" + discussionID
+}
+
+// NotificationTopic carries notifications created for a user
+func NotificationTopic(userID string) string {
+	return "notification:" + userID
+}
+
+// BookAvailabilityTopic carries a book whenever its availability changes
+func BookAvailabilityTopic(bookID string) string {
+	return "book-availability:" + bookID
+}
@@ -0,0 +1,181 @@
+package recommendations
+
+import (
+	"bmsgql/auth"
+	"bmsgql/books"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bmsgql/paging"
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultNeighbourSize = 10
+)
+
+// RecommendedBooks ranks books for the current reader. The ranking is computed
+// nightly by Refresh, books read since are left out and the list is topped up
+// with popular titles, those in the reader's favorite genres first. New readers
+// only get the popular titles.
+func RecommendedBooks(ctx context.Context, limit *int) ([]*model.Book, error) {
+	UserCollection := database.DB.Collection("Users")
+	RecommendationCollection := database.DB.Collection("Recommendations")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	userObjId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	size := paging.Limit(limit)
+
+	var user reader
+	err = UserCollection.FindOne(ctx, bson.M{"_id": userObjId}, options.FindOne().SetProjection(bson.M{"favoriteGenres": 1})).Decode(&user)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	var precomputed struct {
+		Books []scored `bson:"books"`
+	}
+	err = RecommendationCollection.FindOne(ctx, bson.M{"_id": userObjId}).Decode(&precomputed)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to look up recommendations: %w", err)
+	}
+
+	read, err := readBooks(ctx, userObjId)
+	if err != nil {
+		return nil, err
+	}
+	ids := []primitive.ObjectID{}
+	for _, entry := range precomputed.Books {
+		if !read[entry.BookID] {
+			ids = append(ids, entry.BookID)
+			read[entry.BookID] = true
+		}
+	}
+	recommended, err := orderedBooks(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(recommended) >= size {
+		return recommended[:size], nil
+	}
+
+	popular, err := popularBooks(ctx, user.FavoriteGenres)
+	if err != nil {
+		return nil, err
+	}
+	for _, book := range popular {
+		if len(recommended) == size {
+			break
+		}
+		bookId, _ := primitive.ObjectIDFromHex(book.ID)
+		if !read[bookId] {
+			recommended = append(recommended, book)
+		}
+	}
+	return recommended, nil
+}
+
+// AlsoBorrowed lists the books most often borrowed by readers who borrowed book
+func AlsoBorrowed(ctx context.Context, book *model.Book, limit *int) ([]*model.Book, error) {
+	bookId, err := primitive.ObjectIDFromHex(book.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID")
+	}
+
+	var neighbours struct {
+		Books []scored `bson:"books"`
+	}
+	err = database.DB.Collection("BookNeighbours").FindOne(ctx, bson.M{"_id": bookId}).Decode(&neighbours)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return []*model.Book{}, nil
+		}
+		return nil, fmt.Errorf("failed to look up borrowed books: %w", err)
+	}
+
+	size := defaultNeighbourSize
+	if limit != nil && *limit > 0 && *limit <= neighbourCount {
+		size = *limit
+	}
+	ids := []primitive.ObjectID{}
+	for _, entry := range neighbours.Books {
+		ids = append(ids, entry.BookID)
+	}
+	books, err := orderedBooks(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(books) > size {
+		books = books[:size]
+	}
+	return books, nil
+}
+
+// popularBooks are the most popular books of the last refresh, or the best
+// rated ones before the first, with the reader's favorite genres first
+func popularBooks(ctx context.Context, favorites []*model.BookCategory) ([]*model.Book, error) {
+	run, err := latestRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var popular []*model.Book
+	if run != nil && len(run.Popular) > 0 {
+		popular, err = orderedBooks(ctx, run.Popular)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		popular, err = books.TopRated(ctx, bson.M{"availability": bson.M{"$ne": model.BookAvailabilitySoldOut}}, popularCount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	favorite := map[model.BookCategory]bool{}
+	for _, category := range favorites {
+		if category != nil {
+			favorite[*category] = true
+		}
+	}
+	sort.SliceStable(popular, func(i, j int) bool {
+		return favorite[popular[i].Category] && !favorite[popular[j].Category]
+	})
+	return popular, nil
+}
+
+// readBooks are the books a reader borrowed or reviewed, up to now
+func readBooks(ctx context.Context, userObjId primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	read := map[primitive.ObjectID]bool{}
+	for _, collection := range []string{"Loans", "Reviews"} {
+		ids, err := database.DB.Collection(collection).Distinct(ctx, "bookId", bson.M{"userId": userObjId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", collection, err)
+		}
+		for _, id := range ids {
+			if bookId, ok := id.(primitive.ObjectID); ok {
+				read[bookId] = true
+			}
+		}
+	}
+	return read, nil
+}
+
+// orderedBooks fetches books in the order of ids, leaving out the ones deleted
+// or sold out since the lists were computed
+func orderedBooks(ctx context.Context, ids []primitive.ObjectID) ([]*model.Book, error) {
+	return database.OrderedBooks(ctx, ids, bson.M{"availability": bson.M{"$ne": model.BookAvailabilitySoldOut}})
+}
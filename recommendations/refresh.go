@@ -0,0 +1,611 @@
+package recommendations
+
+import (
+	"bmsgql/books"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// listSize is how many ranked books are kept per reader
+	listSize = 50
+	// neighbourCount is how many "also borrowed" books are kept per book
+	neighbourCount = 20
+	// minCoBorrowers keeps books borrowed together by a single reader out of
+	// "also borrowed", that says more about the reader than about the books
+	minCoBorrowers = 2
+	// maxHistory bounds the loans of one reader used for co-borrowing, the
+	// pairs grow with its square
+	maxHistory   = 500
+	popularCount = 100
+	// viewsPerLoan is how many readers viewing a book count as much towards
+	// its popularity as one borrowing it
+	viewsPerLoan = 5
+	// groupCandidates is how many of the best rated and most popular books of
+	// a category, genre or tag are considered for a reader of that group.
+	// Books of a group share its affinity, so the rest cannot outrank them on
+	// it.
+	groupCandidates    = 100
+	defaultRefreshHour = 2
+	writeBatchSize     = 500
+)
+
+// Weights of the ranking signals. Affinities are shares of the reader's
+// history, so every signal is between 0 and 1 before weighting.
+const (
+	weightCoBorrowed = 3.0
+	weightAuthor     = 1.5
+	weightCategory   = 1.0
+	weightFavorite   = 1.0
+	weightGenre      = 0.5
+	weightRating     = 0.5
+	weightPopularity = 0.25
+)
+
+// Run is a document of the RecommendationRuns collection, written when a
+// refresh completes
+type Run struct {
+	ID         primitive.ObjectID   `bson:"_id,omitempty"`
+	StartedAt  time.Time            `bson:"startedAt"`
+	FinishedAt time.Time            `bson:"finishedAt"`
+	Readers    int                  `bson:"readers"`
+	Books      int                  `bson:"books"`
+	Popular    []primitive.ObjectID `bson:"popular"`
+}
+
+// scored is an entry of a precomputed list
+type scored struct {
+	BookID primitive.ObjectID `bson:"bookId"`
+	Score  float64            `bson:"score"`
+}
+
+type bookInfo struct {
+	ID           primitive.ObjectID     `bson:"_id"`
+	Category     model.BookCategory     `bson:"category"`
+	Availability model.BookAvailability `bson:"availability"`
+	Contributors []struct {
+		AuthorID primitive.ObjectID `bson:"authorId"`
+	} `bson:"contributors"`
+	GenreIDs []primitive.ObjectID `bson:"genreIds"`
+	TagIDs   []primitive.ObjectID `bson:"tagIds"`
+
+	borrowers  int
+	viewers    int
+	ratingSum  float64
+	ratings    int
+	base       float64
+	neighbours []scored
+}
+
+type reader struct {
+	ID             primitive.ObjectID    `bson:"_id"`
+	FavoriteGenres []*model.BookCategory `bson:"favoriteGenres"`
+}
+
+// RefreshNightly runs Refresh once a day, after RECOMMENDATIONS_HOUR (default
+// 2, server local time). The scheduler calls it every hour.
+func RefreshNightly(ctx context.Context) error {
+	now := time.Now()
+	hour := envInt("RECOMMENDATIONS_HOUR", defaultRefreshHour)
+	if now.Hour() < hour {
+		return nil
+	}
+	due := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+
+	last, err := latestRun(ctx)
+	if err != nil {
+		return err
+	}
+	if last != nil && !last.StartedAt.Before(due) {
+		return nil
+	}
+	_, err = Refresh(ctx)
+	return err
+}
+
+// Refresh recomputes "also borrowed" for every book and the ranked list of
+// every reader with a history or favorite genres
+func Refresh(ctx context.Context) (*Run, error) {
+	run := &Run{StartedAt: time.Now()}
+
+	books, err := loadBooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history, err := loadHistory(ctx, books)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadViews(ctx, books); err != nil {
+		return nil, err
+	}
+	coBorrowing(books, history.borrowed)
+	run.Popular = rankBase(books)
+	pool := newCandidates(books, run.Popular)
+
+	if err := saveNeighbours(ctx, books, run.StartedAt); err != nil {
+		return nil, err
+	}
+
+	cursor, err := database.DB.Collection("Users").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"favoriteGenres": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch readers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var writes []mongo.WriteModel
+	for cursor.Next(ctx) {
+		var r reader
+		if err := cursor.Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to decode reader: %w", err)
+		}
+		ranked := rank(books, pool, history.seeds[r.ID], r.FavoriteGenres)
+		if len(ranked) == 0 {
+			continue
+		}
+		run.Readers++
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": r.ID}).
+			SetReplacement(bson.M{"books": ranked, "computedAt": run.StartedAt}).
+			SetUpsert(true))
+		if len(writes) == writeBatchSize {
+			if err := write(ctx, "Recommendations", writes); err != nil {
+				return nil, err
+			}
+			writes = writes[:0]
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch readers: %w", err)
+	}
+	if err := write(ctx, "Recommendations", writes); err != nil {
+		return nil, err
+	}
+	// readers whose history is gone, and deleted readers
+	_, err = database.DB.Collection("Recommendations").DeleteMany(ctx, bson.M{"computedAt": bson.M{"$lt": run.StartedAt}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove stale recommendations: %w", err)
+	}
+
+	run.Books = len(books)
+	run.FinishedAt = time.Now()
+	if _, err := database.DB.Collection("RecommendationRuns").InsertOne(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to record recommendation run: %w", err)
+	}
+	log.Printf("recommendations: ranked books for %d reader(s) in %s", run.Readers, run.FinishedAt.Sub(run.StartedAt))
+	return run, nil
+}
+
+// loadBooks fetches the books that can be recommended
+func loadBooks(ctx context.Context) (map[primitive.ObjectID]*bookInfo, error) {
+	cursor, err := database.DB.Collection("Books").Find(ctx, bson.M{"deletedAt": database.NotDeleted}, options.Find().SetProjection(bson.M{
+		"category": 1, "availability": 1, "contributors.authorId": 1, "genreIds": 1, "tagIds": 1,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	var list []*bookInfo
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+	books := make(map[primitive.ObjectID]*bookInfo, len(list))
+	for _, book := range list {
+		books[book.ID] = book
+	}
+	return books, nil
+}
+
+type history struct {
+	// borrowed lists the distinct books of each reader's loans, newest first
+	borrowed map[primitive.ObjectID][]primitive.ObjectID
+	// seeds weighs every book a reader borrowed or reviewed by how much they
+	// liked it
+	seeds map[primitive.ObjectID]map[primitive.ObjectID]float64
+}
+
+// loadHistory reads every loan and review, counting borrowers and ratings per
+// book on the way
+func loadHistory(ctx context.Context, books map[primitive.ObjectID]*bookInfo) (*history, error) {
+	h := &history{
+		borrowed: map[primitive.ObjectID][]primitive.ObjectID{},
+		seeds:    map[primitive.ObjectID]map[primitive.ObjectID]float64{},
+	}
+	seed := func(userId, bookId primitive.ObjectID, weight float64) {
+		if h.seeds[userId] == nil {
+			h.seeds[userId] = map[primitive.ObjectID]float64{}
+		}
+		if current, ok := h.seeds[userId][bookId]; !ok || weight > current {
+			h.seeds[userId][bookId] = weight
+		}
+	}
+
+	cursor, err := database.DB.Collection("Loans").Find(ctx, bson.M{}, options.Find().
+		SetProjection(bson.M{"userId": 1, "bookId": 1}).
+		SetSort(bson.D{{Key: "borrowedAt", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch loans: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var loan struct {
+			UserID primitive.ObjectID `bson:"userId"`
+			BookID primitive.ObjectID `bson:"bookId"`
+		}
+		if err := cursor.Decode(&loan); err != nil {
+			return nil, fmt.Errorf("failed to decode loan: %w", err)
+		}
+		if _, seen := h.seeds[loan.UserID][loan.BookID]; seen {
+			continue
+		}
+		seed(loan.UserID, loan.BookID, 1)
+		if book, ok := books[loan.BookID]; ok {
+			book.borrowers++
+			if len(h.borrowed[loan.UserID]) < maxHistory {
+				h.borrowed[loan.UserID] = append(h.borrowed[loan.UserID], loan.BookID)
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch loans: %w", err)
+	}
+
+	cursor, err = database.DB.Collection("Reviews").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"userId": 1, "bookId": 1, "rating": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reviews: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var review struct {
+			UserID primitive.ObjectID `bson:"userId"`
+			BookID primitive.ObjectID `bson:"bookId"`
+			Rating float64            `bson:"rating"`
+		}
+		if err := cursor.Decode(&review); err != nil {
+			return nil, fmt.Errorf("failed to decode review: %w", err)
+		}
+		// 1 star counts for nothing, 3 stars as much as a loan, 5 stars double
+		seed(review.UserID, review.BookID, math.Max(0, 1+(review.Rating-3)/2))
+		if book, ok := books[review.BookID]; ok {
+			book.ratingSum += review.Rating
+			book.ratings++
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch reviews: %w", err)
+	}
+	return h, nil
+}
+
+// loadViews counts the distinct readers who viewed each book recently, view
+// events expire on their own
+func loadViews(ctx context.Context, books map[primitive.ObjectID]*bookInfo) error {
+	cursor, err := database.DB.Collection("BookViews").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"bookId": "$bookId", "userId": "$userId"}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$_id.bookId", "viewers": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count book views: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var views struct {
+			BookID  primitive.ObjectID `bson:"_id"`
+			Viewers int                `bson:"viewers"`
+		}
+		if err := cursor.Decode(&views); err != nil {
+			return fmt.Errorf("failed to decode book views: %w", err)
+		}
+		if book, ok := books[views.BookID]; ok {
+			book.viewers = views.Viewers
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to count book views: %w", err)
+	}
+	return nil
+}
+
+// coBorrowing finds for every book the books most often borrowed by the same
+// readers. Scores are cosine similarities of the books' sets of borrowers.
+func coBorrowing(books map[primitive.ObjectID]*bookInfo, borrowed map[primitive.ObjectID][]primitive.ObjectID) {
+	pairs := map[[2]primitive.ObjectID]int{}
+	for _, list := range borrowed {
+		for i, a := range list {
+			for _, b := range list[i+1:] {
+				// each pair is counted under one key whatever order it was
+				// borrowed in
+				x, y := a, b
+				if bytes.Compare(x[:], y[:]) > 0 {
+					x, y = y, x
+				}
+				pairs[[2]primitive.ObjectID{x, y}]++
+			}
+		}
+	}
+
+	for pair, count := range pairs {
+		if count < minCoBorrowers {
+			continue
+		}
+		a, b := books[pair[0]], books[pair[1]]
+		score := float64(count) / math.Sqrt(float64(a.borrowers*b.borrowers))
+		a.neighbours = append(a.neighbours, scored{BookID: b.ID, Score: score})
+		b.neighbours = append(b.neighbours, scored{BookID: a.ID, Score: score})
+	}
+	for _, book := range books {
+		book.neighbours = top(book.neighbours, neighbourCount)
+	}
+}
+
+// rankBase scores every book on its ratings and popularity, the part of the
+// ranking that is the same for every reader, and returns the most popular
+// books that can be handed out. Popularity counts borrowers and, for less,
+// recent viewers.
+func rankBase(books map[primitive.ObjectID]*bookInfo) []primitive.ObjectID {
+	maxInterest := 0.0
+	for _, book := range books {
+		maxInterest = math.Max(maxInterest, interest(book))
+	}
+
+	popular := []scored{}
+	for _, book := range books {
+		book.base = weightRating * math.Min(1, math.Max(0, (book.rating()-1)/4))
+		if maxInterest > 0 {
+			book.base += weightPopularity * math.Log1p(interest(book)) / math.Log1p(maxInterest)
+		}
+		if recommendable(book) && (book.borrowers > 0 || book.viewers > 0 || book.ratings > 0) {
+			popular = append(popular, scored{BookID: book.ID, Score: book.base})
+		}
+	}
+
+	return bookIDs(top(popular, popularCount))
+}
+
+// rating is the book's review rating, with the prior books.TopRated uses
+func (book *bookInfo) rating() float64 {
+	return (book.ratingSum + books.RatingPrior*books.PriorRating) / float64(book.ratings+books.RatingPrior)
+}
+
+// candidates indexes the books that can be recommended by what draws a
+// reader to them, so rank only scores books related to a reader's history
+type candidates struct {
+	byAuthor   map[primitive.ObjectID][]primitive.ObjectID
+	byCategory map[model.BookCategory][]primitive.ObjectID
+	// byClass holds genres and tags
+	byClass map[primitive.ObjectID][]primitive.ObjectID
+	popular []primitive.ObjectID
+}
+
+// newCandidates indexes every recommendable book by author and the best of
+// every category, genre and tag. It needs the base scores of rankBase.
+func newCandidates(books map[primitive.ObjectID]*bookInfo, popular []primitive.ObjectID) *candidates {
+	c := &candidates{
+		byAuthor:   map[primitive.ObjectID][]primitive.ObjectID{},
+		byCategory: map[model.BookCategory][]primitive.ObjectID{},
+		byClass:    map[primitive.ObjectID][]primitive.ObjectID{},
+		popular:    popular,
+	}
+	categories := map[model.BookCategory][]scored{}
+	classes := map[primitive.ObjectID][]scored{}
+	for _, book := range books {
+		if !recommendable(book) {
+			continue
+		}
+		entry := scored{BookID: book.ID, Score: book.base}
+		for _, contributor := range book.Contributors {
+			c.byAuthor[contributor.AuthorID] = append(c.byAuthor[contributor.AuthorID], book.ID)
+		}
+		categories[book.Category] = append(categories[book.Category], entry)
+		for _, id := range classIDs(book) {
+			classes[id] = append(classes[id], entry)
+		}
+	}
+	for category, entries := range categories {
+		c.byCategory[category] = bookIDs(top(entries, groupCandidates))
+	}
+	for id, entries := range classes {
+		c.byClass[id] = bookIDs(top(entries, groupCandidates))
+	}
+	return c
+}
+
+// rank scores the books a reader has not read yet against their history and
+// favorite genres. Only books related to the history or favorites and the
+// popular ones are scored, see candidates.
+func rank(books map[primitive.ObjectID]*bookInfo, pool *candidates, seeds map[primitive.ObjectID]float64, favorites []*model.BookCategory) []scored {
+	if len(seeds) == 0 && len(favorites) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	coBorrowed := map[primitive.ObjectID]float64{}
+	categories := map[model.BookCategory]float64{}
+	authors := map[primitive.ObjectID]float64{}
+	classes := map[primitive.ObjectID]float64{}
+	for bookId, weight := range seeds {
+		book, ok := books[bookId]
+		if !ok || weight == 0 {
+			continue
+		}
+		total += weight
+		for _, neighbour := range book.neighbours {
+			coBorrowed[neighbour.BookID] += weight * neighbour.Score
+		}
+		categories[book.Category] += weight
+		for _, contributor := range book.Contributors {
+			authors[contributor.AuthorID] += weight
+		}
+		for _, id := range classIDs(book) {
+			classes[id] += weight
+		}
+	}
+	favorite := map[model.BookCategory]bool{}
+	for _, category := range favorites {
+		if category != nil {
+			favorite[*category] = true
+		}
+	}
+
+	related := map[primitive.ObjectID]bool{}
+	add := func(ids []primitive.ObjectID) {
+		for _, id := range ids {
+			related[id] = true
+		}
+	}
+	for id := range coBorrowed {
+		related[id] = true
+	}
+	for id := range authors {
+		add(pool.byAuthor[id])
+	}
+	for category := range categories {
+		add(pool.byCategory[category])
+	}
+	for category := range favorite {
+		add(pool.byCategory[category])
+	}
+	for id := range classes {
+		add(pool.byClass[id])
+	}
+	add(pool.popular)
+
+	ranked := []scored{}
+	for id := range related {
+		book, ok := books[id]
+		if !ok || !recommendable(book) {
+			continue
+		}
+		if _, read := seeds[book.ID]; read {
+			continue
+		}
+		score := book.base
+		if favorite[book.Category] {
+			score += weightFavorite
+		}
+		if total > 0 {
+			score += weightCoBorrowed * math.Min(1, coBorrowed[book.ID]/total)
+			score += weightCategory * categories[book.Category] / total
+			author := 0.0
+			for _, contributor := range book.Contributors {
+				author = math.Max(author, authors[contributor.AuthorID])
+			}
+			score += weightAuthor * author / total
+			class := 0.0
+			for _, id := range classIDs(book) {
+				class = math.Max(class, classes[id])
+			}
+			score += weightGenre * class / total
+		}
+		ranked = append(ranked, scored{BookID: book.ID, Score: score})
+	}
+	return top(ranked, listSize)
+}
+
+func saveNeighbours(ctx context.Context, books map[primitive.ObjectID]*bookInfo, computedAt time.Time) error {
+	var writes []mongo.WriteModel
+	for _, book := range books {
+		if len(book.neighbours) == 0 {
+			continue
+		}
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": book.ID}).
+			SetReplacement(bson.M{"books": book.neighbours, "computedAt": computedAt}).
+			SetUpsert(true))
+		if len(writes) == writeBatchSize {
+			if err := write(ctx, "BookNeighbours", writes); err != nil {
+				return err
+			}
+			writes = writes[:0]
+		}
+	}
+	if err := write(ctx, "BookNeighbours", writes); err != nil {
+		return err
+	}
+	_, err := database.DB.Collection("BookNeighbours").DeleteMany(ctx, bson.M{"computedAt": bson.M{"$lt": computedAt}})
+	if err != nil {
+		return fmt.Errorf("failed to remove stale neighbours: %w", err)
+	}
+	return nil
+}
+
+func write(ctx context.Context, collection string, writes []mongo.WriteModel) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	_, err := database.DB.Collection(collection).BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", collection, err)
+	}
+	return nil
+}
+
+func latestRun(ctx context.Context) (*Run, error) {
+	var run Run
+	err := database.DB.Collection("RecommendationRuns").FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "startedAt", Value: -1}})).Decode(&run)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to look up recommendation runs: %w", err)
+	}
+	return &run, nil
+}
+
+// classIDs are the genres and tags of a book
+func classIDs(book *bookInfo) []primitive.ObjectID {
+	return append(append([]primitive.ObjectID{}, book.GenreIDs...), book.TagIDs...)
+}
+
+func bookIDs(entries []scored) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.BookID
+	}
+	return ids
+}
+
+// interest is how many readers showed interest in a book, in borrowers
+func interest(book *bookInfo) float64 {
+	return float64(book.borrowers) + float64(book.viewers)/viewsPerLoan
+}
+
+// recommendable reports whether a book can still be borrowed or reserved
+func recommendable(book *bookInfo) bool {
+	return book.Availability != model.BookAvailabilitySoldOut
+}
+
+// top sorts entries by score, best first, and keeps the first n
+func top(entries []scored, n int) []scored {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].BookID.Hex() < entries[j].BookID.Hex()
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+func envInt(name string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 0 {
+		return fallback
+	}
+	return n
+}
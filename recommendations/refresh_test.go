@@ -0,0 +1,134 @@
+package recommendations
+
+import (
+	"bmsgql/graph/model"
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testID(n byte) primitive.ObjectID {
+	var id primitive.ObjectID
+	id[len(id)-1] = n
+	return id
+}
+
+// testBooks builds a catalogue of books a, b, c and d and the borrowing
+// history of three readers
+func testBooks() (map[primitive.ObjectID]*bookInfo, map[primitive.ObjectID][]primitive.ObjectID) {
+	a, b, c, d := testID(1), testID(2), testID(3), testID(4)
+	books := map[primitive.ObjectID]*bookInfo{
+		a: {ID: a, Category: model.BookCategoryFiction, borrowers: 2},
+		b: {ID: b, Category: model.BookCategoryFiction, borrowers: 3},
+		c: {ID: c, Category: model.BookCategoryScience, borrowers: 2},
+		d: {ID: d, Category: model.BookCategoryHistory},
+	}
+	borrowed := map[primitive.ObjectID][]primitive.ObjectID{
+		// out of ID order, the first pair has to be swapped
+		testID(101): {c, a, b},
+		testID(102): {b, c},
+		testID(103): {a, b},
+	}
+	return books, borrowed
+}
+
+func TestCoBorrowing(t *testing.T) {
+	books, borrowed := testBooks()
+	coBorrowing(books, borrowed)
+
+	a, b, c, d := testID(1), testID(2), testID(3), testID(4)
+	// a and c were borrowed together once only, below minCoBorrowers
+	want := map[primitive.ObjectID]map[primitive.ObjectID]float64{
+		a: {b: 2 / math.Sqrt(2*3)},
+		b: {a: 2 / math.Sqrt(2*3), c: 2 / math.Sqrt(3*2)},
+		c: {b: 2 / math.Sqrt(3*2)},
+		d: {},
+	}
+	for id, neighbours := range want {
+		got := books[id].neighbours
+		if len(got) != len(neighbours) {
+			t.Errorf("book %d: got %d neighbours %v, want %d", id[11], len(got), got, len(neighbours))
+			continue
+		}
+		for _, entry := range got {
+			score, ok := neighbours[entry.BookID]
+			if !ok {
+				t.Errorf("book %d: unexpected neighbour %d", id[11], entry.BookID[11])
+			} else if math.Abs(entry.Score-score) > 1e-9 {
+				t.Errorf("book %d: neighbour %d scored %v, want %v", id[11], entry.BookID[11], entry.Score, score)
+			}
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	books, borrowed := testBooks()
+	a, b, c, d, e := testID(1), testID(2), testID(3), testID(4), testID(5)
+	author := testID(201)
+	books[a].Contributors = append(books[a].Contributors, struct {
+		AuthorID primitive.ObjectID `bson:"authorId"`
+	}{author})
+	// e was never borrowed, only its author relates it to a
+	books[e] = &bookInfo{ID: e, Category: model.BookCategoryScience, Contributors: books[a].Contributors}
+	books[c].Availability = model.BookAvailabilitySoldOut
+	coBorrowing(books, borrowed)
+	pool := newCandidates(books, rankBase(books))
+
+	ranked := rank(books, pool, map[primitive.ObjectID]float64{a: 1}, nil)
+	got := []primitive.ObjectID{}
+	for _, entry := range ranked {
+		got = append(got, entry.BookID)
+	}
+	// a was read and c cannot be handed out, b was borrowed with a and shares
+	// its category, e has its author. d is unrelated and not popular.
+	want := []primitive.ObjectID{b, e}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	if ranked := rank(books, pool, nil, nil); ranked != nil {
+		t.Errorf("reader without history or favorites: got %v, want nothing", ranked)
+	}
+	history := model.BookCategoryHistory
+	ranked = rank(books, pool, nil, []*model.BookCategory{&history})
+	if len(ranked) == 0 || ranked[0].BookID != d {
+		t.Errorf("reader favoring history: got %v, want d first", ranked)
+	}
+}
+
+func TestNewCandidates(t *testing.T) {
+	books := map[primitive.ObjectID]*bookInfo{}
+	for i := 1; i <= groupCandidates+10; i++ {
+		id := testID(byte(i))
+		books[id] = &bookInfo{ID: id, Category: model.BookCategoryFiction, base: float64(i)}
+	}
+	pool := newCandidates(books, nil)
+	fiction := pool.byCategory[model.BookCategoryFiction]
+	if len(fiction) != groupCandidates {
+		t.Fatalf("got %d fiction candidates, want %d", len(fiction), groupCandidates)
+	}
+	if fiction[0] != testID(groupCandidates+10) {
+		t.Errorf("best fiction candidate is %v, want the best scored book", fiction[0])
+	}
+}
+
+func TestTop(t *testing.T) {
+	entries := []scored{
+		{BookID: testID(3), Score: 1},
+		{BookID: testID(1), Score: 2},
+		{BookID: testID(2), Score: 1},
+	}
+	got := top(entries, 2)
+	if len(got) != 2 || got[0].BookID != testID(1) || got[1].BookID != testID(2) {
+		t.Errorf("got %v, want books 1 and 2", got)
+	}
+	if got := top(nil, 5); len(got) != 0 {
+		t.Errorf("got %v from no entries", got)
+	}
+}
@@ -0,0 +1,395 @@
+package reports
+
+import (
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// table is the tabular result of a report
+type table struct {
+	columns []string
+	rows    [][]string
+}
+
+// params is a validated ReportFilterInput
+type params struct {
+	from         *time.Time
+	to           *time.Time
+	category     *model.BookCategory
+	userActivity string
+}
+
+var builders = map[model.ReportType]func(context.Context, params) (*table, error){
+	model.ReportTypeCirculation:        circulation,
+	model.ReportTypeCategoryPopularity: categoryPopularity,
+	model.ReportTypeUserActivity:       userActivity,
+	model.ReportTypeOverdueItems:       overdueItems,
+}
+
+const dateLayout = "2006-01-02"
+
+func parseFilter(filter *model.ReportFilterInput) (params, error) {
+	var p params
+	if filter.DateRange != nil {
+		from, err := parseDate(filter.DateRange.StartDate)
+		if err != nil {
+			return p, fmt.Errorf("invalid start date: %w", err)
+		}
+		to, err := parseDate(filter.DateRange.EndDate)
+		if err != nil {
+			return p, fmt.Errorf("invalid end date: %w", err)
+		}
+		// a plain end date includes the whole day
+		if len(filter.DateRange.EndDate) == len(dateLayout) {
+			to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		if to.Before(from) {
+			return p, fmt.Errorf("end date is before start date")
+		}
+		p.from, p.to = &from, &to
+	}
+	p.category = filter.Category
+	if filter.UserActivity != nil {
+		p.userActivity = strings.TrimSpace(*filter.UserActivity)
+	}
+	return p, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, value)
+}
+
+func (p params) describe() string {
+	var parts []string
+	if p.from != nil {
+		parts = append(parts, p.from.Format(dateLayout)+" to "+p.to.Format(dateLayout))
+	}
+	if p.category != nil {
+		parts = append(parts, p.category.String())
+	}
+	if p.userActivity != "" {
+		parts = append(parts, p.userActivity)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// dateMatch restricts field to the requested date range
+func (p params) dateMatch(field string) bson.M {
+	if p.from == nil {
+		return bson.M{}
+	}
+	return bson.M{field: bson.M{"$gte": *p.from, "$lte": *p.to}}
+}
+
+// day is the date of field in the time zone of the requested range, so rows
+// line up with dateMatch
+func (p params) day(field string) bson.M {
+	timezone := "UTC"
+	if p.from != nil {
+		timezone = p.from.Format("-07:00")
+	}
+	return bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": field, "timezone": timezone}}
+}
+
+// loanBookStages joins loans with their book and applies the category filter
+func (p params) loanBookStages() bson.A {
+	stages := bson.A{
+		bson.M{"$lookup": bson.M{"from": "Books", "localField": "bookId", "foreignField": "_id", "as": "book"}},
+		bson.M{"$unwind": "$book"},
+	}
+	if p.category != nil {
+		stages = append(stages, bson.M{"$match": bson.M{"book.category": *p.category}})
+	}
+	return stages
+}
+
+// circulation counts loans per day they were borrowed and returns per day
+// they were returned
+func circulation(ctx context.Context, p params) (*table, error) {
+	pipeline := bson.A{bson.M{"$match": p.dateMatch("borrowedAt")}}
+	pipeline = append(pipeline, p.loanBookStages()...)
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{
+			"_id":      p.day("$borrowedAt"),
+			"borrowed": bson.M{"$sum": 1},
+			"readers":  bson.M{"$addToSet": "$userId"},
+		}},
+	)
+	var borrowed []struct {
+		Date     string               `bson:"_id"`
+		Borrowed int                  `bson:"borrowed"`
+		Readers  []primitive.ObjectID `bson:"readers"`
+	}
+	if err := aggregate(ctx, "Loans", pipeline, &borrowed); err != nil {
+		return nil, err
+	}
+
+	match := p.dateMatch("returnedAt")
+	match["status"] = "RETURNED"
+	pipeline = bson.A{bson.M{"$match": match}}
+	pipeline = append(pipeline, p.loanBookStages()...)
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{
+			"_id":      p.day("$returnedAt"),
+			"returned": bson.M{"$sum": 1},
+		}},
+	)
+	var returned []struct {
+		Date     string `bson:"_id"`
+		Returned int    `bson:"returned"`
+	}
+	if err := aggregate(ctx, "Loans", pipeline, &returned); err != nil {
+		return nil, err
+	}
+
+	type day struct {
+		borrowed, returned, readers int
+	}
+	byDate := map[string]*day{}
+	date := func(d string) *day {
+		if byDate[d] == nil {
+			byDate[d] = &day{}
+		}
+		return byDate[d]
+	}
+	for _, r := range borrowed {
+		d := date(r.Date)
+		d.borrowed, d.readers = r.Borrowed, len(r.Readers)
+	}
+	for _, r := range returned {
+		date(r.Date).returned = r.Returned
+	}
+	dates := make([]string, 0, len(byDate))
+	for d := range byDate {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+
+	t := &table{columns: []string{"date", "borrowed", "returned", "readers"}, rows: [][]string{}}
+	for _, d := range dates {
+		r := byDate[d]
+		t.rows = append(t.rows, []string{d, strconv.Itoa(r.borrowed), strconv.Itoa(r.returned), strconv.Itoa(r.readers)})
+	}
+	return t, nil
+}
+
+// categoryPopularity ranks categories by loans in the period, with the views
+// of their books. Views are only kept for 30 days, older periods have none.
+func categoryPopularity(ctx context.Context, p params) (*table, error) {
+	pipeline := bson.A{bson.M{"$match": p.dateMatch("borrowedAt")}}
+	pipeline = append(pipeline, p.loanBookStages()...)
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{
+			"_id":     "$book.category",
+			"loans":   bson.M{"$sum": 1},
+			"titles":  bson.M{"$addToSet": "$bookId"},
+			"readers": bson.M{"$addToSet": "$userId"},
+		}},
+	)
+	var loans []struct {
+		Category string               `bson:"_id"`
+		Loans    int                  `bson:"loans"`
+		Titles   []primitive.ObjectID `bson:"titles"`
+		Readers  []primitive.ObjectID `bson:"readers"`
+	}
+	if err := aggregate(ctx, "Loans", pipeline, &loans); err != nil {
+		return nil, err
+	}
+
+	// BookViews has one document per reader, book and day
+	pipeline = bson.A{bson.M{"$match": p.dateMatch("day")}}
+	pipeline = append(pipeline, p.loanBookStages()...)
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{
+			"_id":     "$book.category",
+			"views":   bson.M{"$sum": bson.M{"$ifNull": bson.A{"$views", 1}}},
+			"viewers": bson.M{"$addToSet": "$userId"},
+		}},
+	)
+	var views []struct {
+		Category string               `bson:"_id"`
+		Views    int                  `bson:"views"`
+		Viewers  []primitive.ObjectID `bson:"viewers"`
+	}
+	if err := aggregate(ctx, "BookViews", pipeline, &views); err != nil {
+		return nil, err
+	}
+
+	type popularity struct {
+		category                               string
+		loans, titles, readers, views, viewers int
+	}
+	byCategory := map[string]*popularity{}
+	category := func(name string) *popularity {
+		if byCategory[name] == nil {
+			byCategory[name] = &popularity{category: name}
+		}
+		return byCategory[name]
+	}
+	for _, r := range loans {
+		c := category(r.Category)
+		c.loans, c.titles, c.readers = r.Loans, len(r.Titles), len(r.Readers)
+	}
+	for _, r := range views {
+		c := category(r.Category)
+		c.views, c.viewers = r.Views, len(r.Viewers)
+	}
+	ranked := make([]*popularity, 0, len(byCategory))
+	for _, c := range byCategory {
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].loans != ranked[j].loans {
+			return ranked[i].loans > ranked[j].loans
+		}
+		if ranked[i].views != ranked[j].views {
+			return ranked[i].views > ranked[j].views
+		}
+		return ranked[i].category < ranked[j].category
+	})
+
+	t := &table{columns: []string{"category", "loans", "titles", "readers", "views", "viewers"}, rows: [][]string{}}
+	for _, c := range ranked {
+		t.rows = append(t.rows, []string{
+			c.category, strconv.Itoa(c.loans), strconv.Itoa(c.titles), strconv.Itoa(c.readers), strconv.Itoa(c.views), strconv.Itoa(c.viewers),
+		})
+	}
+	return t, nil
+}
+
+// userActivity lists loans and reviews per reader, userActivity narrows it to a
+// reader by ID or email
+func userActivity(ctx context.Context, p params) (*table, error) {
+	userMatch := bson.M{}
+	if p.userActivity != "" {
+		if userId, err := primitive.ObjectIDFromHex(p.userActivity); err == nil {
+			userMatch["_id"] = userId
+		} else {
+			userMatch["email"] = p.userActivity
+		}
+	}
+
+	loanMatch := bson.A{bson.M{"$eq": bson.A{"$userId", "$$userId"}}}
+	if p.from != nil {
+		loanMatch = append(loanMatch,
+			bson.M{"$gte": bson.A{"$borrowedAt", *p.from}},
+			bson.M{"$lte": bson.A{"$borrowedAt", *p.to}},
+		)
+	}
+	loanPipeline := bson.A{bson.M{"$match": bson.M{"$expr": bson.M{"$and": loanMatch}}}}
+	if p.category != nil {
+		loanPipeline = append(loanPipeline, p.loanBookStages()...)
+	}
+	loanPipeline = append(loanPipeline, bson.M{"$count": "count"})
+
+	// reviews store createdAt as RFC3339 strings, which sort like the dates they hold
+	reviewMatch := bson.A{bson.M{"$eq": bson.A{"$userId", "$$userId"}}}
+	if p.from != nil {
+		reviewMatch = append(reviewMatch,
+			bson.M{"$gte": bson.A{"$createdAt", p.from.UTC().Format(time.RFC3339)}},
+			bson.M{"$lte": bson.A{"$createdAt", p.to.UTC().Format(time.RFC3339)}},
+		)
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": userMatch},
+		bson.M{"$lookup": bson.M{"from": "Loans", "let": bson.M{"userId": "$_id"}, "pipeline": loanPipeline, "as": "loans"}},
+		bson.M{"$lookup": bson.M{"from": "Reviews", "let": bson.M{"userId": "$_id"}, "pipeline": bson.A{
+			bson.M{"$match": bson.M{"$expr": bson.M{"$and": reviewMatch}}},
+			bson.M{"$count": "count"},
+		}, "as": "reviews"}},
+		bson.M{"$project": bson.M{
+			"name":    1,
+			"email":   1,
+			"loans":   bson.M{"$ifNull": bson.A{bson.M{"$first": "$loans.count"}, 0}},
+			"reviews": bson.M{"$ifNull": bson.A{bson.M{"$first": "$reviews.count"}, 0}},
+		}},
+		bson.M{"$sort": bson.D{{Key: "loans", Value: -1}, {Key: "reviews", Value: -1}, {Key: "name", Value: 1}}},
+	}
+
+	var results []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		Name    string             `bson:"name"`
+		Email   string             `bson:"email"`
+		Loans   int                `bson:"loans"`
+		Reviews int                `bson:"reviews"`
+	}
+	if err := aggregate(ctx, "Users", pipeline, &results); err != nil {
+		return nil, err
+	}
+
+	t := &table{columns: []string{"userId", "name", "email", "loans", "reviews"}, rows: [][]string{}}
+	for _, r := range results {
+		t.rows = append(t.rows, []string{r.ID.Hex(), r.Name, r.Email, strconv.Itoa(r.Loans), strconv.Itoa(r.Reviews)})
+	}
+	return t, nil
+}
+
+// overdueItems lists loans past their due date that are not returned yet,
+// the date range applies to the due date
+func overdueItems(ctx context.Context, p params) (*table, error) {
+	now := time.Now()
+	match := bson.M{"$or": bson.A{
+		bson.M{"status": "OVERDUE"},
+		bson.M{"status": "ACTIVE", "dueDate": bson.M{"$lt": now}},
+	}}
+	pipeline := bson.A{bson.M{"$match": match}, bson.M{"$match": p.dateMatch("dueDate")}}
+	pipeline = append(pipeline, p.loanBookStages()...)
+	pipeline = append(pipeline,
+		bson.M{"$lookup": bson.M{"from": "Users", "localField": "userId", "foreignField": "_id", "as": "user"}},
+		bson.M{"$unwind": "$user"},
+		bson.M{"$sort": bson.M{"dueDate": 1}},
+	)
+
+	var results []struct {
+		Book struct {
+			Title string `bson:"title"`
+			Isbn  string `bson:"isbn"`
+		} `bson:"book"`
+		User struct {
+			Name  string `bson:"name"`
+			Email string `bson:"email"`
+		} `bson:"user"`
+		BorrowedAt time.Time `bson:"borrowedAt"`
+		DueDate    time.Time `bson:"dueDate"`
+	}
+	if err := aggregate(ctx, "Loans", pipeline, &results); err != nil {
+		return nil, err
+	}
+
+	t := &table{columns: []string{"title", "isbn", "reader", "email", "borrowedAt", "dueDate", "daysOverdue"}, rows: [][]string{}}
+	for _, r := range results {
+		daysOverdue := int(now.Sub(r.DueDate).Hours() / 24)
+		t.rows = append(t.rows, []string{
+			r.Book.Title, r.Book.Isbn, r.User.Name, r.User.Email,
+			r.BorrowedAt.Format(dateLayout), r.DueDate.Format(dateLayout), strconv.Itoa(daysOverdue),
+		})
+	}
+	return t, nil
+}
+
+func aggregate(ctx context.Context, collection string, pipeline bson.A, results interface{}) error {
+	cursor, err := database.DB.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate %s: %w", collection, err)
+	}
+	if err := cursor.All(ctx, results); err != nil {
+		return fmt.Errorf("failed to decode %s report: %w", collection, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package reports
+
+import (
+	"bmsgql/auth"
+	"bmsgql/spreadsheet"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// DownloadHandler serves a saved report at /reports/{id} as CSV, or as JSON with
+// ?format=json. It must run behind auth.AuthMiddleware, only ADMIN may download.
+func DownloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, err := auth.RequireAdmin(r.Context()); err != nil {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		report, err := findReport(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		filename := strings.Trim(unsafeFilename.ReplaceAllString(strings.ToLower(report.Title), "-"), "-")
+		if filename == "" {
+			filename = "report"
+		}
+
+		switch r.URL.Query().Get("format") {
+		case "", "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+			// rows hold titles and emails, escaped so they cannot run as formulas
+			writer := csv.NewWriter(w)
+			writer.Write(report.Columns)
+			for _, row := range report.Rows {
+				writer.Write(spreadsheet.Row(row))
+			}
+			writer.Flush()
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+			json.NewEncoder(w).Encode(struct {
+				ID          string              `json:"id"`
+				Title       string              `json:"title"`
+				Type        string              `json:"type"`
+				GeneratedAt string              `json:"generatedAt"`
+				Rows        []map[string]string `json:"rows"`
+			}{report.ID, report.Title, report.Type.String(), report.GeneratedAt, records(report)})
+		default:
+			writeError(w, http.StatusBadRequest, "unsupported format, use csv or json")
+		}
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+}
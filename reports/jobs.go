@@ -0,0 +1,329 @@
+package reports
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultWorkers       = 2
+	defaultRetentionDays = 7
+	pollInterval         = 5 * time.Second
+	// jobTimeout bounds a single run, a RUNNING job older than this is assumed to
+	// belong to a worker that died and is picked up again
+	jobTimeout = 30 * time.Minute
+)
+
+// job is a document of the ReportJobs collection
+type job struct {
+	ID          primitive.ObjectID      `bson:"_id,omitempty"`
+	Filter      model.ReportFilterInput `bson:"filter"`
+	Status      model.ReportJobStatus   `bson:"status"`
+	Progress    int                     `bson:"progress"`
+	Error       string                  `bson:"error,omitempty"`
+	ReportIDs   []primitive.ObjectID    `bson:"reportIds"`
+	RequestedBy primitive.ObjectID      `bson:"requestedBy"`
+	CreatedAt   time.Time               `bson:"createdAt"`
+	StartedAt   *time.Time              `bson:"startedAt,omitempty"`
+	FinishedAt  *time.Time              `bson:"finishedAt,omitempty"`
+}
+
+func (j *job) toModel() *model.ReportJob {
+	format := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		formatted := t.Format(time.RFC3339)
+		return &formatted
+	}
+
+	reportJob := &model.ReportJob{
+		ID:         j.ID.Hex(),
+		Status:     j.Status,
+		Progress:   j.Progress,
+		CreatedAt:  j.CreatedAt.Format(time.RFC3339),
+		StartedAt:  format(j.StartedAt),
+		FinishedAt: format(j.FinishedAt),
+		ReportIDs:  j.ReportIDs,
+	}
+	if j.Error != "" {
+		reportJob.Error = &j.Error
+	}
+	return reportJob
+}
+
+// wake lets GenerateReport start an idle worker without waiting for the next poll
+var wake = make(chan struct{}, 1)
+
+// GenerateReport queues a report job and returns its ID. The job produces the
+// report of the filter's type, or one of every type when no type is given, poll
+// reportJob for the result.
+func GenerateReport(ctx context.Context, filter *model.ReportFilterInput) (string, error) {
+	ReportJobCollection := database.DB.Collection("ReportJobs")
+
+	userObjId, err := auth.RequireAdmin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if filter == nil {
+		filter = &model.ReportFilterInput{}
+	}
+	// reject a bad filter now rather than failing the job later
+	if _, err := parseFilter(filter); err != nil {
+		return "", err
+	}
+
+	result, err := ReportJobCollection.InsertOne(ctx, job{
+		Filter:      *filter,
+		Status:      model.ReportJobStatusQueued,
+		ReportIDs:   []primitive.ObjectID{},
+		RequestedBy: userObjId,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to queue report: %w", err)
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// ReportJob returns the status of a queued report
+func ReportJob(ctx context.Context, id string) (*model.ReportJob, error) {
+	ReportJobCollection := database.DB.Collection("ReportJobs")
+
+	if _, err := auth.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	jobId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report job ID")
+	}
+
+	var j job
+	err = ReportJobCollection.FindOne(ctx, bson.M{"_id": jobId}).Decode(&j)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("report job not found")
+		}
+		return nil, fmt.Errorf("failed to find report job: %w", err)
+	}
+	return j.toModel(), nil
+}
+
+// JobReports returns the reports a job has produced so far
+func JobReports(ctx context.Context, reportJob *model.ReportJob) ([]*model.Report, error) {
+	ReportCollection := database.DB.Collection("Reports")
+
+	if len(reportJob.ReportIDs) == 0 {
+		return []*model.Report{}, nil
+	}
+
+	cursor, err := ReportCollection.Find(ctx, bson.M{"_id": bson.M{"$in": reportJob.ReportIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reports: %w", err)
+	}
+	reports := []*model.Report{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, fmt.Errorf("failed to decode reports: %w", err)
+	}
+	return reports, nil
+}
+
+// RunWorkers processes queued report jobs with REPORT_WORKERS workers until ctx
+// is cancelled. Every replica runs workers, a job is claimed by exactly one.
+func RunWorkers(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx)
+		}()
+	}
+	wg.Wait()
+	log.Println("reports: workers stopped")
+}
+
+func work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// drain the queue before waiting again
+		for ctx.Err() == nil {
+			j, err := claim(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("reports: %v", err)
+				}
+				break
+			}
+			if j == nil {
+				break
+			}
+			run(ctx, j)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks the oldest queued job as RUNNING and returns it, or nil when the
+// queue is empty
+func claim(ctx context.Context) (*job, error) {
+	ReportJobCollection := database.DB.Collection("ReportJobs")
+
+	now := time.Now()
+	var j job
+	err := ReportJobCollection.FindOneAndUpdate(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"status": model.ReportJobStatusQueued},
+			bson.M{"status": model.ReportJobStatusRunning, "startedAt": bson.M{"$lt": now.Add(-jobTimeout)}},
+		}},
+		bson.M{"$set": bson.M{
+			"status":    model.ReportJobStatusRunning,
+			"progress":  0,
+			"startedAt": now,
+			"reportIds": bson.A{},
+		}},
+		options.FindOneAndUpdate().SetSort(bson.M{"createdAt": 1}).SetReturnDocument(options.After),
+	).Decode(&j)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim report job: %w", err)
+	}
+	return &j, nil
+}
+
+// run generates every report of the job, recording progress after each one
+func run(ctx context.Context, j *job) {
+	ReportJobCollection := database.DB.Collection("ReportJobs")
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	types := model.AllReportType
+	if j.Filter.Type != nil {
+		types = []model.ReportType{*j.Filter.Type}
+	}
+
+	for i, reportType := range types {
+		report, err := Generate(ctx, reportType, &j.Filter, j.RequestedBy)
+		if err != nil {
+			if ctx.Err() == context.Canceled {
+				// shutting down, leave the job for the next worker
+				requeue(j.ID)
+				return
+			}
+			finish(j.ID, model.ReportJobStatusFailed, bson.M{"error": err.Error()})
+			log.Printf("reports: job %s failed: %v", j.ID.Hex(), err)
+			return
+		}
+
+		reportId, _ := primitive.ObjectIDFromHex(report.ID)
+		_, err = ReportJobCollection.UpdateOne(ctx, bson.M{"_id": j.ID}, bson.M{
+			"$set":  bson.M{"progress": (i + 1) * 100 / len(types)},
+			"$push": bson.M{"reportIds": reportId},
+		})
+		if err != nil {
+			log.Printf("reports: failed to record progress of job %s: %v", j.ID.Hex(), err)
+		}
+	}
+
+	finish(j.ID, model.ReportJobStatusCompleted, bson.M{"progress": 100})
+}
+
+// finish records the outcome of a job, even when the worker is shutting down
+func finish(jobId primitive.ObjectID, status model.ReportJobStatus, fields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fields["status"] = status
+	fields["finishedAt"] = time.Now()
+	_, err := database.DB.Collection("ReportJobs").UpdateOne(ctx, bson.M{"_id": jobId}, bson.M{"$set": fields})
+	if err != nil {
+		log.Printf("reports: failed to finish job %s: %v", jobId.Hex(), err)
+	}
+}
+
+// requeue puts a job interrupted by shutdown back in the queue
+func requeue(jobId primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection("ReportJobs").UpdateOne(ctx, bson.M{"_id": jobId}, bson.M{
+		"$set":   bson.M{"status": model.ReportJobStatusQueued, "progress": 0, "reportIds": bson.A{}},
+		"$unset": bson.M{"startedAt": ""},
+	})
+	if err != nil {
+		log.Printf("reports: failed to requeue job %s: %v", jobId.Hex(), err)
+	}
+}
+
+// CleanupReports removes finished jobs and saved reports older than
+// REPORT_RETENTION_DAYS
+func CleanupReports(ctx context.Context) error {
+	ReportJobCollection := database.DB.Collection("ReportJobs")
+	ReportCollection := database.DB.Collection("Reports")
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays())
+
+	_, err := ReportJobCollection.DeleteMany(ctx, bson.M{
+		"status":     bson.M{"$in": bson.A{model.ReportJobStatusCompleted, model.ReportJobStatusFailed}},
+		"finishedAt": bson.M{"$lt": cutoff},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove old report jobs: %w", err)
+	}
+
+	// reports have no date field usable in a query, the ObjectID carries the
+	// creation time
+	_, err = ReportCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$lt": primitive.NewObjectIDFromTimestamp(cutoff)}})
+	if err != nil {
+		return fmt.Errorf("failed to remove old reports: %w", err)
+	}
+	return nil
+}
+
+func workerCount() int {
+	n, err := strconv.Atoi(os.Getenv("REPORT_WORKERS"))
+	if err != nil || n <= 0 {
+		return defaultWorkers
+	}
+	return n
+}
+
+func retentionDays() int {
+	n, err := strconv.Atoi(os.Getenv("REPORT_RETENTION_DAYS"))
+	if err != nil || n <= 0 {
+		return defaultRetentionDays
+	}
+	return n
+}
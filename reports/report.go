@@ -0,0 +1,162 @@
+package reports
+
+import (
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/graph/model"
+	"bmsgql/paging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var titles = map[model.ReportType]string{
+	model.ReportTypeCirculation:        "Circulation",
+	model.ReportTypeCategoryPopularity: "Category popularity",
+	model.ReportTypeUserActivity:       "User activity",
+	model.ReportTypeOverdueItems:       "Overdue items",
+}
+
+// Reports lists saved reports, newest first. The filter picks reports of a
+// type that were generated for the same category, user and period. Reports
+// are generated by the jobs generateReport queues.
+func Reports(ctx context.Context, filter *model.ReportFilterInput, limit *int, offset *int) ([]*model.Report, error) {
+	ReportCollection := database.DB.Collection("Reports")
+
+	if _, err := auth.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	query := bson.M{}
+	if filter != nil {
+		params, err := parseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Type != nil {
+			query["type"] = *filter.Type
+		}
+		if params.from != nil {
+			query["from"] = *params.from
+			query["to"] = *params.to
+		}
+		if params.category != nil {
+			query["filter.category"] = *params.category
+		}
+		if params.userActivity != "" {
+			query["filter.userActivity"] = params.userActivity
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(int64(paging.Offset(offset))).
+		SetLimit(int64(paging.Limit(limit)))
+	cursor, err := ReportCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reports: %w", err)
+	}
+	reports := []*model.Report{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, fmt.Errorf("failed to decode reports: %w", err)
+	}
+	return reports, nil
+}
+
+// Generate builds a report and persists it in the Reports collection
+func Generate(ctx context.Context, reportType model.ReportType, filter *model.ReportFilterInput, generatedBy primitive.ObjectID) (*model.Report, error) {
+	ReportCollection := database.DB.Collection("Reports")
+
+	params, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	build, ok := builders[reportType]
+	if !ok {
+		return nil, fmt.Errorf("unknown report type %s", reportType)
+	}
+	table, err := build(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedAt := time.Now().Format(time.RFC3339)
+	title := titles[reportType] + params.describe()
+	result, err := ReportCollection.InsertOne(ctx, bson.M{
+		"title":       title,
+		"type":        reportType,
+		"filter":      filter,
+		"from":        params.from,
+		"to":          params.to,
+		"columns":     table.columns,
+		"rows":        table.rows,
+		"generatedAt": generatedAt,
+		"generatedBy": generatedBy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save report: %w", err)
+	}
+
+	insertedId := result.InsertedID.(primitive.ObjectID)
+
+	return &model.Report{
+		ID:          insertedId.Hex(),
+		Title:       title,
+		Type:        reportType,
+		GeneratedAt: generatedAt,
+		Columns:     table.columns,
+		Rows:        table.rows,
+	}, nil
+}
+
+// Data encodes the rows of a report as a JSON array of objects keyed by column
+func Data(report *model.Report) (string, error) {
+	data, err := json.Marshal(records(report))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode report: %w", err)
+	}
+	return string(data), nil
+}
+
+// DownloadURL is the path of the HTTP endpoint serving the report
+func DownloadURL(report *model.Report) string {
+	return "/reports/" + report.ID
+}
+
+func findReport(ctx context.Context, id string) (*model.Report, error) {
+	reportId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report ID")
+	}
+
+	var report model.Report
+	err = database.DB.Collection("Reports").FindOne(ctx, bson.M{"_id": reportId}).Decode(&report)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("report not found")
+		}
+		return nil, fmt.Errorf("failed to find report: %w", err)
+	}
+	return &report, nil
+}
+
+func records(report *model.Report) []map[string]string {
+	records := make([]map[string]string, len(report.Rows))
+	for i, row := range report.Rows {
+		record := make(map[string]string, len(report.Columns))
+		for j, column := range report.Columns {
+			if j < len(row) {
+				record[column] = row[j]
+			}
+		}
+		records[i] = record
+	}
+	return records
+}
@@ -40,17 +40,18 @@ func AddReview(ctx context.Context, bookID string, input model.ReviewInput) (*mo
 	}
 
 	var book model.Book
-	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId}).Decode(&book)
+	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId, "deletedAt": bson.M{"$exists": false}}).Decode(&book)
 	if err != nil {
 		return nil, fmt.Errorf("book not found")
 	}
 
+	createdAt := time.Now().Format(time.RFC3339)
 	newReview, err := ReviewCollection.InsertOne(ctx, bson.M{
 		"bookId":    bookId,
 		"userId":    userObjId,
 		"rating":    input.Rating,
 		"content":   input.Content,
-		"createdAt": time.Now().Format(time.RFC3339),
+		"createdAt": createdAt,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to add review: %w", err)
@@ -59,11 +60,12 @@ func AddReview(ctx context.Context, bookID string, input model.ReviewInput) (*mo
 	insertedId := newReview.InsertedID.(primitive.ObjectID)
 
 	review := &model.Review{
-		ID:      insertedId.Hex(),
-		Book:    &book,
-		User:    &user,
-		Rating:  input.Rating,
-		Content: input.Content,
+		ID:        insertedId.Hex(),
+		BookID:    bookId,
+		UserID:    userObjId,
+		Rating:    input.Rating,
+		Content:   input.Content,
+		CreatedAt: &createdAt,
 	}
 
 	// update the book's rating
@@ -134,7 +136,7 @@ func BookReviews(ctx context.Context, bookID string) ([]*model.Review, error) {
 	}
 
 	var book model.Book
-	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId}).Decode(&book)
+	err = BookCollection.FindOne(ctx, bson.M{"_id": bookId, "deletedAt": bson.M{"$exists": false}}).Decode(&book)
 	if err != nil {
 		return nil, fmt.Errorf("book not found")
 	}
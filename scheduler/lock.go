@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"bmsgql/database"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lock is a lease stored as a single document of the SchedulerLocks collection.
+// Only the replica holding an unexpired lease runs the jobs.
+type lock struct {
+	name  string
+	owner string
+	ttl   time.Duration
+}
+
+// acquire takes the lease if it is free or expired, or extends it if we already
+// hold it. It reports whether this replica is the leader afterwards.
+func (l *lock) acquire(ctx context.Context) (bool, error) {
+	LockCollection := database.DB.Collection("SchedulerLocks")
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": l.name,
+		"$or": bson.A{
+			bson.M{"owner": l.owner},
+			bson.M{"expiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": l.owner, "expiresAt": now.Add(l.ttl)}}
+
+	_, err := LockCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		// the upsert collides with the _id of a lease held by another replica
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire scheduler lock: %w", err)
+	}
+	return true, nil
+}
+
+// release gives the lease up so another replica can take over right away
+func (l *lock) release(ctx context.Context) error {
+	_, err := database.DB.Collection("SchedulerLocks").DeleteOne(ctx, bson.M{"_id": l.name, "owner": l.owner})
+	if err != nil {
+		return fmt.Errorf("failed to release scheduler lock: %w", err)
+	}
+	return nil
+}
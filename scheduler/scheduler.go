@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"bmsgql/books"
+	"bmsgql/imports"
+	"bmsgql/notifications"
+	"bmsgql/recommendations"
+	"bmsgql/reports"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	lockName = "scheduler"
+	leaseTTL = 30 * time.Second
+)
+
+// Job is a task run periodically by the leader replica
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs jobs on their intervals. Every replica runs a Scheduler but only
+// the one holding the MongoDB lock executes jobs, the others stand by to take over.
+type Scheduler struct {
+	jobs   []Job
+	lock   *lock
+	leader atomic.Bool
+}
+
+func New(jobs ...Job) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		jobs: jobs,
+		lock: &lock{
+			name:  lockName,
+			owner: fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), primitive.NewObjectID().Hex()),
+			ttl:   leaseTTL,
+		},
+	}
+}
+
+// DefaultJobs are the background jobs the server runs
+func DefaultJobs() []Job {
+	return []Job{
+		{Name: "due-date-reminders", Interval: time.Hour, Run: books.SendDueDateReminders},
+		{Name: "overdue-loans", Interval: 15 * time.Minute, Run: books.MarkOverdueLoans},
+		{Name: "reservation-expiry", Interval: 5 * time.Minute, Run: books.ExpireReservations},
+		{Name: "new-arrival-digests", Interval: time.Hour, Run: books.SendNewArrivalDigests},
+		{Name: "notification-retries", Interval: time.Minute, Run: notifications.RetryPending},
+		{Name: "notification-deliveries", Interval: time.Minute, Run: notifications.RetryFailedDeliveries},
+		{Name: "report-cleanup", Interval: time.Hour, Run: reports.CleanupReports},
+		{Name: "import-cleanup", Interval: time.Hour, Run: imports.CleanupImports},
+		{Name: "recommendations", Interval: time.Hour, Run: recommendations.RefreshNightly},
+	}
+}
+
+// Run blocks until ctx is cancelled, then waits for running jobs to finish and
+// releases the lock
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	s.renewLease(ctx)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.renewLease(ctx)
+			}
+		}
+	}()
+
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+
+	releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if s.leader.Load() {
+		if err := s.lock.release(releaseCtx); err != nil {
+			log.Printf("scheduler: %v", err)
+		}
+	}
+	log.Println("scheduler: stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.leader.Load() {
+				continue
+			}
+			start := time.Now()
+			if err := job.Run(ctx); err != nil {
+				log.Printf("scheduler: job %s failed: %v", job.Name, err)
+				continue
+			}
+			log.Printf("scheduler: job %s finished in %s", job.Name, time.Since(start))
+		}
+	}
+}
+
+func (s *Scheduler) renewLease(ctx context.Context) {
+	leader, err := s.lock.acquire(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("scheduler: %v", err)
+		}
+		leader = false
+	}
+	if s.leader.Swap(leader) != leader {
+		if leader {
+			log.Println("scheduler: acquired leadership, running jobs")
+		} else {
+			log.Println("scheduler: lost leadership, standing by")
+		}
+	}
+}
@@ -1,19 +1,50 @@
 package main
 
 import (
+	"bmsgql/audit"
 	"bmsgql/auth"
+	"bmsgql/authors"
+	"bmsgql/blobstore"
+	"bmsgql/books"
+	"bmsgql/collections"
+	"bmsgql/covers"
 	"bmsgql/database"
+	"bmsgql/exports"
 	"bmsgql/graph"
+	"bmsgql/imports"
+	"bmsgql/loaders"
+	"bmsgql/reports"
+	"bmsgql/scheduler"
+	"bmsgql/tags"
+	"bmsgql/works"
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
-const defaultPort = "8080"
+const (
+	defaultPort = "8080"
+	// maxUploadSize bounds a multipart request, catalogue files sent to
+	// importCatalogue are the largest uploads
+	maxUploadSize = 256 << 20
+	// maxUploadMemory is how much of an upload is held in memory, the rest
+	// goes to a temporary file
+	maxUploadMemory = 8 << 20
+)
 
 func main() {
 	err := godotenv.Load()
@@ -22,26 +53,130 @@ func main() {
 	}
 
 	// Establish database connection
-	_, err = database.Connect()
+	client, err := database.Connect()
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
 	log.Println("Connected to the database successfully!")
 
+	indexCtx, cancelIndexes := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := books.EnsureIsbnIndex(indexCtx); err != nil {
+		// without it two books could end up with the same ISBN
+		log.Fatalf("Failed to create indexes: %v", err)
+	}
+	if err := books.EnsureIndexes(indexCtx); err != nil {
+		log.Printf("Failed to create indexes: %v", err)
+	}
+	if err := authors.EnsureIndexes(indexCtx); err != nil {
+		log.Printf("Failed to create indexes: %v", err)
+	}
+	if err := works.EnsureIndexes(indexCtx); err != nil {
+		log.Printf("Failed to create indexes: %v", err)
+	}
+	if err := tags.EnsureIndexes(indexCtx); err != nil {
+		log.Printf("Failed to create indexes: %v", err)
+	}
+	if err := collections.EnsureIndexes(indexCtx); err != nil {
+		log.Printf("Failed to create indexes: %v", err)
+	}
+	cancelIndexes()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = defaultPort
 	}
 
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	srv := newServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/graphql"))
-	authMiddleware := auth.AuthMiddleware(srv)
+	authMiddleware := audit.Middleware(auth.AuthMiddleware(loaders.Middleware(srv)))
 	http.Handle("/graphql", enableCORS(authMiddleware))
+	http.Handle("/reports/{id}", enableCORS(auth.AuthMiddleware(reports.DownloadHandler())))
+	http.Handle("/export/books", enableCORS(auth.AuthMiddleware(exports.Handler())))
+	http.Handle(blobstore.LocalPrefix, covers.Handler())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start background jobs
+	jobs := scheduler.New(scheduler.DefaultJobs()...)
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Run(ctx)
+		close(jobsDone)
+	}()
+
+	// Report and import workers run on every replica, jobs are claimed one at a time
+	workersDone := make(chan struct{})
+	go func() {
+		reports.RunWorkers(ctx)
+		close(workersDone)
+	}()
+	importsDone := make(chan struct{})
+	go func() {
+		imports.RunWorkers(ctx)
+		close(importsDone)
+	}()
+
+	server := &http.Server{Addr: ":" + port}
+	go func() {
+		log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+	<-jobsDone
+	<-workersDone
+	<-importsDone
+	if err := client.Disconnect(shutdownCtx); err != nil {
+		log.Printf("Database disconnect: %v", err)
+	}
+}
+
+// newServer mirrors handler.NewDefaultServer, with WebSocket subscriptions
+// authenticated from the connection_init payload
+func newServer(es graphql.ExecutableSchema) *handler.Server {
+	srv := handler.New(es)
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              auth.WebSocketInit,
+		Upgrader: websocket.Upgrader{
+			// Any origin may connect, as enableCORS lets any origin call the
+			// HTTP endpoint. Connections authenticate with the token sent in
+			// connection_init, not cookies, so a foreign page cannot act with
+			// a reader's credentials it does not have.
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: maxUploadSize,
+		MaxMemory:     maxUploadMemory,
+	})
+
+	srv.AroundFields(audit.FieldMiddleware)
+
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New[string](100),
+	})
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	return srv
 }
 
 func enableCORS(next http.Handler) http.Handler {
@@ -0,0 +1,31 @@
+// Package spreadsheet keeps the cells of CSV files from running as formulas
+// when the file is opened in a spreadsheet.
+package spreadsheet
+
+import "strconv"
+
+// Cell prefixes value with ' when a spreadsheet would read it as a formula,
+// that is when it starts with =, +, -, @, a tab or a carriage return. Numbers
+// such as "-2" are left as they are.
+func Cell(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return value
+		}
+		return "'" + value
+	}
+	return value
+}
+
+// Row is Cell applied to every value of a row
+func Row(values []string) []string {
+	row := make([]string, len(values))
+	for i, value := range values {
+		row[i] = Cell(value)
+	}
+	return row
+}
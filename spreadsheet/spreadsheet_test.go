@@ -0,0 +1,28 @@
+package spreadsheet
+
+import "testing"
+
+func TestCell(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"", ""},
+		{"Good Omens", "Good Omens"},
+		{"a=b", "a=b"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1+2", "'+1+2"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"-2", "-2"},
+		{"-2.5", "-2.5"},
+		{"+3", "+3"},
+		{"42", "42"},
+	}
+	for _, tt := range tests {
+		if got := Cell(tt.value); got != tt.want {
+			t.Errorf("Cell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
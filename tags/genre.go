@@ -0,0 +1,407 @@
+package tags
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/loaders"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Genres lists the subgenres of a genre, or the top level genres when parentId
+// is nil
+func Genres(ctx context.Context, scheme *model.GenreScheme, parentId *string) ([]*model.Genre, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	filter := bson.M{"parentId": bson.M{"$exists": false}}
+	if parentId != nil {
+		id, err := primitive.ObjectIDFromHex(*parentId)
+		if err != nil {
+			return nil, fmt.Errorf("invalid genre ID")
+		}
+		filter["parentId"] = id
+	}
+	if scheme != nil {
+		filter["scheme"] = *scheme
+	}
+	return findGenres(ctx, filter)
+}
+
+func Genre(ctx context.Context, id string) (*model.Genre, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	genreId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genre ID")
+	}
+	return findGenre(ctx, genreId)
+}
+
+// AddGenre adds a genre to a scheme. Genres keep the IDs of all their
+// ancestors, root first, so a whole branch is found with one query.
+func AddGenre(ctx context.Context, input model.GenreInput) (*model.Genre, error) {
+	GenreCollection := database.DB.Collection("Genres")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	genre := &model.Genre{
+		Scheme:    input.Scheme,
+		Code:      strings.ToUpper(strings.TrimSpace(input.Code)),
+		Name:      strings.Join(strings.Fields(input.Name), " "),
+		Ancestors: []primitive.ObjectID{},
+	}
+	if genre.Code == "" || genre.Name == "" {
+		return nil, errcode.Errorf(errcode.BadUserInput, "genre code and name are required")
+	}
+	if input.ParentID != nil && *input.ParentID != "" {
+		parent, err := parentGenre(ctx, *input.ParentID, genre.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		parentId, _ := primitive.ObjectIDFromHex(parent.ID)
+		genre.ParentID = &parentId
+		genre.Ancestors = append(append(genre.Ancestors, parent.Ancestors...), parentId)
+	}
+
+	newGenre := bson.M{
+		"scheme":    genre.Scheme,
+		"code":      genre.Code,
+		"name":      genre.Name,
+		"ancestors": genre.Ancestors,
+	}
+	if genre.ParentID != nil {
+		newGenre["parentId"] = *genre.ParentID
+	}
+	result, err := GenreCollection.InsertOne(ctx, newGenre)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateGenre(genre.Scheme, genre.Code)
+		}
+		return nil, fmt.Errorf("failed to add genre: %w", err)
+	}
+	genre.ID = result.InsertedID.(primitive.ObjectID).Hex()
+
+	audit.Track(ctx, "Genre", genre.ID, nil, genre)
+	return genre, nil
+}
+
+// EditGenre updates a genre. Moving it under another parent moves its whole
+// branch with it.
+func EditGenre(ctx context.Context, id string, input model.EditGenreInput) (*model.Genre, error) {
+	GenreCollection := database.DB.Collection("Genres")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	genreId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genre ID")
+	}
+	before, err := findGenre(ctx, genreId)
+	if err != nil {
+		return nil, err
+	}
+
+	updateGenre := bson.M{}
+	update := bson.M{}
+	if input.Code != nil {
+		code := strings.ToUpper(strings.TrimSpace(*input.Code))
+		if code == "" {
+			return nil, errcode.Errorf(errcode.BadUserInput, "genre code is required")
+		}
+		updateGenre["code"] = code
+	}
+	if input.Name != nil {
+		name := strings.Join(strings.Fields(*input.Name), " ")
+		if name == "" {
+			return nil, errcode.Errorf(errcode.BadUserInput, "genre name is required")
+		}
+		updateGenre["name"] = name
+	}
+	var ancestors []primitive.ObjectID
+	if input.ParentID != nil {
+		ancestors = []primitive.ObjectID{}
+		if *input.ParentID == "" {
+			update["$unset"] = bson.M{"parentId": ""}
+		} else {
+			parent, err := parentGenre(ctx, *input.ParentID, before.Scheme)
+			if err != nil {
+				return nil, err
+			}
+			parentId, _ := primitive.ObjectIDFromHex(parent.ID)
+			if parentId == genreId || containsId(parent.Ancestors, genreId) {
+				return nil, errcode.Errorf(errcode.BadUserInput, "a genre cannot be moved under itself or one of its subgenres")
+			}
+			updateGenre["parentId"] = parentId
+			ancestors = append(append(ancestors, parent.Ancestors...), parentId)
+		}
+		updateGenre["ancestors"] = ancestors
+	}
+	if len(updateGenre) > 0 {
+		update["$set"] = updateGenre
+	}
+	if len(update) == 0 {
+		return before, nil
+	}
+
+	var genre model.Genre
+	err = GenreCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": genreId},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&genre)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateGenre(before.Scheme, updateGenre["code"].(string))
+		}
+		return nil, fmt.Errorf("failed to update genre: %w", err)
+	}
+
+	if ancestors != nil {
+		if err := moveBranch(ctx, genreId, append(ancestors, genreId)); err != nil {
+			return nil, err
+		}
+	}
+
+	audit.Track(ctx, "Genre", id, before, &genre)
+	return &genre, nil
+}
+
+// DeleteGenre removes a genre without subgenres and takes it off every book
+// classified under it
+func DeleteGenre(ctx context.Context, id string) (bool, error) {
+	GenreCollection := database.DB.Collection("Genres")
+	BookCollection := database.DB.Collection("Books")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return false, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return false, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return false, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	genreId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid genre ID")
+	}
+
+	children, err := GenreCollection.CountDocuments(ctx, bson.M{"parentId": genreId})
+	if err != nil {
+		return false, fmt.Errorf("failed to look up subgenres: %w", err)
+	}
+	if children > 0 {
+		return false, errcode.Errorf(errcode.Conflict, "genre has %d subgenre(s), delete or move them first", children)
+	}
+
+	var before model.Genre
+	err = GenreCollection.FindOneAndDelete(ctx, bson.M{"_id": genreId}).Decode(&before)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, errcode.Errorf(errcode.NotFound, "genre not found")
+		}
+		return false, fmt.Errorf("failed to delete genre: %w", err)
+	}
+	_, err = BookCollection.UpdateMany(ctx, bson.M{"genreIds": genreId}, bson.M{"$pull": bson.M{"genreIds": genreId}})
+	if err != nil {
+		return false, fmt.Errorf("failed to unclassify books: %w", err)
+	}
+
+	audit.Track(ctx, "Genre", id, &before, nil)
+	return true, nil
+}
+
+// Parent is the genre a genre is a subgenre of, nil for a top level genre
+func Parent(ctx context.Context, genre *model.Genre) (*model.Genre, error) {
+	if genre.ParentID == nil {
+		return nil, nil
+	}
+	parent, err := loaders.GetGenre(ctx, genre.ParentID.Hex())
+	if err != nil {
+		if errors.Is(err, loaders.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parent, nil
+}
+
+func Children(ctx context.Context, genre *model.Genre) ([]*model.Genre, error) {
+	genreId, err := primitive.ObjectIDFromHex(genre.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genre ID")
+	}
+	return findGenres(ctx, bson.M{"parentId": genreId})
+}
+
+// BooksByGenre lists the books classified under a genre, by default including
+// the ones classified under any of its subgenres
+func BooksByGenre(ctx context.Context, id string, includeSubgenres *bool, limit, offset *int) ([]*model.Book, error) {
+	GenreCollection := database.DB.Collection("Genres")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	genreId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genre ID")
+	}
+	if _, err := findGenre(ctx, genreId); err != nil {
+		return nil, err
+	}
+
+	genreIds := bson.A{genreId}
+	if includeSubgenres == nil || *includeSubgenres {
+		branch, err := GenreCollection.Distinct(ctx, "_id", bson.M{"ancestors": genreId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up subgenres: %w", err)
+		}
+		genreIds = append(genreIds, branch...)
+	}
+	return findBooks(ctx, bson.M{"genreIds": bson.M{"$in": genreIds}, "deletedAt": database.NotDeleted}, limit, offset)
+}
+
+// BookGenres are the genres of a book, in the order they were given
+func BookGenres(ctx context.Context, book *model.Book) ([]*model.Genre, error) {
+	return loaders.GetGenres(ctx, hexIDs(book.GenreIDs))
+}
+
+// CheckGenres makes sure every genre exists and returns their IDs without repeats
+func CheckGenres(ctx context.Context, ids []string) ([]primitive.ObjectID, error) {
+	return checkIDs(ctx, "Genres", "genre", ids)
+}
+
+// moveBranch rewrites the ancestors of every genre below a moved one, prefix
+// being the new ancestors of the moved genre followed by the genre itself
+func moveBranch(ctx context.Context, genreId primitive.ObjectID, prefix []primitive.ObjectID) error {
+	GenreCollection := database.DB.Collection("Genres")
+
+	descendants, err := findGenres(ctx, bson.M{"ancestors": genreId})
+	if err != nil {
+		return err
+	}
+	for _, descendant := range descendants {
+		// keep the part of the path below the moved genre
+		below := descendant.Ancestors
+		for i, ancestor := range descendant.Ancestors {
+			if ancestor == genreId {
+				below = descendant.Ancestors[i+1:]
+				break
+			}
+		}
+		ancestors := append(append([]primitive.ObjectID{}, prefix...), below...)
+		descendantId, _ := primitive.ObjectIDFromHex(descendant.ID)
+		_, err := GenreCollection.UpdateOne(ctx, bson.M{"_id": descendantId}, bson.M{"$set": bson.M{"ancestors": ancestors}})
+		if err != nil {
+			return fmt.Errorf("failed to move subgenre: %w", err)
+		}
+	}
+	return nil
+}
+
+// parentGenre looks up the genre a genre is placed under, which must belong to
+// the same scheme
+func parentGenre(ctx context.Context, id string, scheme model.GenreScheme) (*model.Genre, error) {
+	parentId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.BadUserInput, "invalid parent genre ID %q", id)
+	}
+	parent, err := findGenre(ctx, parentId)
+	if err != nil {
+		return nil, err
+	}
+	if parent.Scheme != scheme {
+		return nil, errcode.Errorf(errcode.BadUserInput, "a %s genre cannot be placed under a %s genre", scheme, parent.Scheme)
+	}
+	return parent, nil
+}
+
+func findGenre(ctx context.Context, genreId primitive.ObjectID) (*model.Genre, error) {
+	var genre model.Genre
+	err := database.DB.Collection("Genres").FindOne(ctx, bson.M{"_id": genreId}).Decode(&genre)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errcode.Errorf(errcode.NotFound, "genre not found")
+		}
+		return nil, fmt.Errorf("failed to find genre: %w", err)
+	}
+	return &genre, nil
+}
+
+func findGenres(ctx context.Context, filter bson.M) ([]*model.Genre, error) {
+	cursor, err := database.DB.Collection("Genres").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "code", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch genres: %w", err)
+	}
+	genres := []*model.Genre{}
+	if err := cursor.All(ctx, &genres); err != nil {
+		return nil, fmt.Errorf("failed to decode genres: %w", err)
+	}
+	return genres, nil
+}
+
+func duplicateGenre(scheme model.GenreScheme, code string) error {
+	return errcode.Errorf(errcode.Conflict, "a %s genre with code %s already exists", scheme, code)
+}
+
+func containsId(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package tags
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/loaders"
+	"bmsgql/paging"
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bookOrder is the order books are browsed in
+var bookOrder = bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}
+
+func Tags(ctx context.Context) ([]*model.Tag, error) {
+	TagCollection := database.DB.Collection("Tags")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	cursor, err := TagCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	taglist := []*model.Tag{}
+	if err := cursor.All(ctx, &taglist); err != nil {
+		return nil, fmt.Errorf("failed to decode tags: %w", err)
+	}
+	return taglist, nil
+}
+
+func AddTag(ctx context.Context, name string) (*model.Tag, error) {
+	TagCollection := database.DB.Collection("Tags")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	tag := &model.Tag{Name: strings.Join(strings.Fields(name), " ")}
+	tag.Slug = Slug(tag.Name)
+	if tag.Slug == "" {
+		return nil, errcode.Errorf(errcode.BadUserInput, "tag name is required")
+	}
+
+	result, err := TagCollection.InsertOne(ctx, bson.M{"name": tag.Name, "slug": tag.Slug})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateTag(tag.Slug)
+		}
+		return nil, fmt.Errorf("failed to add tag: %w", err)
+	}
+	tag.ID = result.InsertedID.(primitive.ObjectID).Hex()
+
+	audit.Track(ctx, "Tag", tag.ID, nil, tag)
+	return tag, nil
+}
+
+// RenameTag changes the name of a tag and with it the slug it is browsed by
+func RenameTag(ctx context.Context, id string, name string) (*model.Tag, error) {
+	TagCollection := database.DB.Collection("Tags")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	tagId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag ID")
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	slug := Slug(name)
+	if slug == "" {
+		return nil, errcode.Errorf(errcode.BadUserInput, "tag name is required")
+	}
+
+	var before model.Tag
+	err = TagCollection.FindOne(ctx, bson.M{"_id": tagId}).Decode(&before)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.NotFound, "tag not found")
+	}
+
+	var tag model.Tag
+	err = TagCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": tagId},
+		bson.M{"$set": bson.M{"name": name, "slug": slug}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&tag)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateTag(slug)
+		}
+		return nil, fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	audit.Track(ctx, "Tag", id, &before, &tag)
+	return &tag, nil
+}
+
+// DeleteTag removes a tag and takes it off every book that had it
+func DeleteTag(ctx context.Context, id string) (bool, error) {
+	TagCollection := database.DB.Collection("Tags")
+	BookCollection := database.DB.Collection("Books")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return false, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return false, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return false, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	tagId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid tag ID")
+	}
+
+	var before model.Tag
+	err = TagCollection.FindOneAndDelete(ctx, bson.M{"_id": tagId}).Decode(&before)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, errcode.Errorf(errcode.NotFound, "tag not found")
+		}
+		return false, fmt.Errorf("failed to delete tag: %w", err)
+	}
+	_, err = BookCollection.UpdateMany(ctx, bson.M{"tagIds": tagId}, bson.M{"$pull": bson.M{"tagIds": tagId}})
+	if err != nil {
+		return false, fmt.Errorf("failed to untag books: %w", err)
+	}
+
+	audit.Track(ctx, "Tag", id, &before, nil)
+	return true, nil
+}
+
+// BooksByTag lists the books carrying the tag with the given slug
+func BooksByTag(ctx context.Context, slug string, limit, offset *int) ([]*model.Book, error) {
+	TagCollection := database.DB.Collection("Tags")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	var tag struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := TagCollection.FindOne(ctx, bson.M{"slug": Slug(slug)}).Decode(&tag)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errcode.Errorf(errcode.NotFound, "tag not found")
+		}
+		return nil, fmt.Errorf("failed to find tag: %w", err)
+	}
+	return findBooks(ctx, bson.M{"tagIds": tag.ID, "deletedAt": database.NotDeleted}, limit, offset)
+}
+
+// BookTags are the tags of a book, in the order they were given
+func BookTags(ctx context.Context, book *model.Book) ([]*model.Tag, error) {
+	return loaders.GetTags(ctx, hexIDs(book.TagIDs))
+}
+
+// CheckTags makes sure every tag exists and returns their IDs without repeats
+func CheckTags(ctx context.Context, ids []string) ([]primitive.ObjectID, error) {
+	return checkIDs(ctx, "Tags", "tag", ids)
+}
+
+// Slug is the URL form of a tag name, "Science & Nature" becomes
+// "science-nature"
+func Slug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// EnsureIndexes creates the indexes tag and genre lookups rely on
+func EnsureIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("Tags").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetName("slug_unique").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tag index: %w", err)
+	}
+	_, err = database.DB.Collection("Genres").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "scheme", Value: 1}, {Key: "code", Value: 1}},
+			Options: options.Index().SetName("scheme_code_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "ancestors", Value: 1}},
+			Options: options.Index().SetName("ancestors"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create genre indexes: %w", err)
+	}
+	_, err = database.DB.Collection("Books").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tagIds", Value: 1}}, Options: options.Index().SetName("tag_ids")},
+		{Keys: bson.D{{Key: "genreIds", Value: 1}}, Options: options.Index().SetName("genre_ids")},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create book classification indexes: %w", err)
+	}
+	return nil
+}
+
+func duplicateTag(slug string) error {
+	return errcode.Errorf(errcode.Conflict, "a tag %q already exists", slug)
+}
+
+// checkIDs parses ids, drops repeats and fails unless every one names a
+// document of collection
+func checkIDs(ctx context.Context, collection, kind string, ids []string) ([]primitive.ObjectID, error) {
+	objIds := []primitive.ObjectID{}
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		objId, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.BadUserInput, "invalid %s ID %q", kind, id)
+		}
+		if !seen[objId] {
+			seen[objId] = true
+			objIds = append(objIds, objId)
+		}
+	}
+	if len(objIds) == 0 {
+		return objIds, nil
+	}
+
+	count, err := database.DB.Collection(collection).CountDocuments(ctx, bson.M{"_id": bson.M{"$in": objIds}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %ss: %w", kind, err)
+	}
+	if int(count) != len(objIds) {
+		return nil, errcode.Errorf(errcode.NotFound, "%d of the given %ss do not exist", len(objIds)-int(count), kind)
+	}
+	return objIds, nil
+}
+
+func findBooks(ctx context.Context, filter bson.M, limit, offset *int) ([]*model.Book, error) {
+	cursor, err := database.DB.Collection("Books").Find(ctx, filter, options.Find().
+		SetSort(bookOrder).
+		SetSkip(int64(paging.Offset(offset))).
+		SetLimit(int64(paging.Limit(limit))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch books: %w", err)
+	}
+	books := []*model.Book{}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, fmt.Errorf("failed to decode books: %w", err)
+	}
+	return books, nil
+}
+
+func hexIDs(ids []primitive.ObjectID) []string {
+	hex := make([]string, len(ids))
+	for i, id := range ids {
+		hex[i] = id.Hex()
+	}
+	return hex
+}
@@ -0,0 +1,197 @@
+package works
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/loaders"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Series(ctx context.Context, id string) (*model.Series, error) {
+	SeriesCollection := database.DB.Collection("Series")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	seriesId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid series ID")
+	}
+
+	var series model.Series
+	err = SeriesCollection.FindOne(ctx, bson.M{"_id": seriesId}).Decode(&series)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errcode.Errorf(errcode.NotFound, "series not found")
+		}
+		return nil, fmt.Errorf("failed to find series: %w", err)
+	}
+	return &series, nil
+}
+
+func AddSeries(ctx context.Context, input model.SeriesInput) (*model.Series, error) {
+	SeriesCollection := database.DB.Collection("Series")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	series := &model.Series{Title: strings.Join(strings.Fields(input.Title), " ")}
+	if series.Title == "" {
+		return nil, errcode.Errorf(errcode.BadUserInput, "series title is required")
+	}
+	if input.Description != nil {
+		series.Description = *input.Description
+	}
+
+	result, err := SeriesCollection.InsertOne(ctx, bson.M{
+		"title":       series.Title,
+		"description": series.Description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add series: %w", err)
+	}
+	series.ID = result.InsertedID.(primitive.ObjectID).Hex()
+
+	audit.Track(ctx, "Series", series.ID, nil, series)
+	return series, nil
+}
+
+func EditSeries(ctx context.Context, id string, input model.EditSeriesInput) (*model.Series, error) {
+	SeriesCollection := database.DB.Collection("Series")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	seriesId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid series ID")
+	}
+
+	var before model.Series
+	err = SeriesCollection.FindOne(ctx, bson.M{"_id": seriesId}).Decode(&before)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.NotFound, "series not found")
+	}
+
+	updateSeries := bson.M{}
+	if input.Title != nil {
+		title := strings.Join(strings.Fields(*input.Title), " ")
+		if title == "" {
+			return nil, errcode.Errorf(errcode.BadUserInput, "series title is required")
+		}
+		updateSeries["title"] = title
+	}
+	if input.Description != nil {
+		updateSeries["description"] = input.Description
+	}
+	if len(updateSeries) == 0 {
+		return &before, nil
+	}
+
+	var series model.Series
+	err = SeriesCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": seriesId},
+		bson.M{"$set": updateSeries},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&series)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update series: %w", err)
+	}
+
+	audit.Track(ctx, "Series", id, &before, &series)
+	return &series, nil
+}
+
+// Works lists the works of a series in volume order, works without a volume
+// number last
+func Works(ctx context.Context, series *model.Series) ([]*model.Work, error) {
+	WorkCollection := database.DB.Collection("Works")
+
+	seriesId, err := primitive.ObjectIDFromHex(series.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid series ID")
+	}
+
+	cursor, err := WorkCollection.Find(ctx, bson.M{"seriesId": seriesId}, options.Find().SetSort(bson.D{{Key: "volume", Value: 1}, {Key: "title", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch works: %w", err)
+	}
+	worklist := []*model.Work{}
+	if err := cursor.All(ctx, &worklist); err != nil {
+		return nil, fmt.Errorf("failed to decode works: %w", err)
+	}
+	// MongoDB sorts missing volumes first
+	sort.SliceStable(worklist, func(i, j int) bool {
+		return worklist[i].Volume != nil && worklist[j].Volume == nil
+	})
+	return worklist, nil
+}
+
+// WorkSeries is the series a work belongs to, nil when it stands alone
+func WorkSeries(ctx context.Context, work *model.Work) (*model.Series, error) {
+	if work.SeriesID == nil {
+		return nil, nil
+	}
+	series, err := loaders.GetSeries(ctx, work.SeriesID.Hex())
+	if err != nil {
+		if errors.Is(err, loaders.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return series, nil
+}
+
+func checkSeries(ctx context.Context, id string) (primitive.ObjectID, error) {
+	seriesId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, errcode.Errorf(errcode.BadUserInput, "invalid series ID %q", id)
+	}
+	count, err := database.DB.Collection("Series").CountDocuments(ctx, bson.M{"_id": seriesId})
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("failed to look up series: %w", err)
+	}
+	if count == 0 {
+		return primitive.NilObjectID, errcode.Errorf(errcode.NotFound, "series %s not found", id)
+	}
+	return seriesId, nil
+}
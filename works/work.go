@@ -0,0 +1,297 @@
+package works
+
+import (
+	"bmsgql/audit"
+	"bmsgql/auth"
+	"bmsgql/database"
+	"bmsgql/errcode"
+	"bmsgql/graph/model"
+	"bmsgql/loaders"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// editionOrder lists the oldest editions first
+var editionOrder = bson.D{{Key: "publicationYear", Value: 1}, {Key: "_id", Value: 1}}
+
+func Work(ctx context.Context, id string) (*model.Work, error) {
+	WorkCollection := database.DB.Collection("Works")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+
+	workId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid work ID")
+	}
+
+	var work model.Work
+	err = WorkCollection.FindOne(ctx, bson.M{"_id": workId}).Decode(&work)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errcode.Errorf(errcode.NotFound, "work not found")
+		}
+		return nil, fmt.Errorf("failed to find work: %w", err)
+	}
+	return &work, nil
+}
+
+func AddWork(ctx context.Context, input model.WorkInput) (*model.Work, error) {
+	WorkCollection := database.DB.Collection("Works")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	title := strings.Join(strings.Fields(input.Title), " ")
+	if title == "" {
+		return nil, errcode.Errorf(errcode.BadUserInput, "work title is required")
+	}
+	work := &model.Work{Title: title}
+	newWork := bson.M{"title": title}
+
+	if input.SeriesID != nil && *input.SeriesID != "" {
+		seriesId, err := checkSeries(ctx, *input.SeriesID)
+		if err != nil {
+			return nil, err
+		}
+		work.SeriesID = &seriesId
+		newWork["seriesId"] = seriesId
+	}
+	if input.Volume != nil {
+		if work.SeriesID == nil {
+			return nil, errcode.Errorf(errcode.BadUserInput, "a volume number needs a series")
+		}
+		if *input.Volume <= 0 {
+			return nil, errcode.Errorf(errcode.BadUserInput, "volume number must be positive")
+		}
+		work.Volume = input.Volume
+		newWork["volume"] = *input.Volume
+	}
+
+	result, err := WorkCollection.InsertOne(ctx, newWork)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add work: %w", err)
+	}
+	work.ID = result.InsertedID.(primitive.ObjectID).Hex()
+
+	audit.Track(ctx, "Work", work.ID, nil, work)
+	return work, nil
+}
+
+// EditWork updates a work. Taking it out of its series drops its volume number too.
+func EditWork(ctx context.Context, id string, input model.EditWorkInput) (*model.Work, error) {
+	WorkCollection := database.DB.Collection("Works")
+
+	userID, ok := auth.GetUserID(ctx)
+	if !ok || userID == "" {
+		fmt.Printf("Error: user ID not found in context or is empty \n")
+		return nil, fmt.Errorf("user not authenticated")
+	}
+	// Validate account type
+	accountType, ok := auth.GetAccountType(ctx)
+	if !ok || accountType == "" {
+		return nil, fmt.Errorf("account type not found in context")
+	}
+
+	if accountType != "ADMIN" {
+		return nil, fmt.Errorf("access denied: only users with an account type of ADMIN can access this")
+	}
+
+	workId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid work ID")
+	}
+
+	var before model.Work
+	err = WorkCollection.FindOne(ctx, bson.M{"_id": workId}).Decode(&before)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.NotFound, "work not found")
+	}
+
+	updateWork := bson.M{}
+	unsetWork := bson.M{}
+	if input.Title != nil {
+		title := strings.Join(strings.Fields(*input.Title), " ")
+		if title == "" {
+			return nil, errcode.Errorf(errcode.BadUserInput, "work title is required")
+		}
+		updateWork["title"] = title
+	}
+	inSeries := before.SeriesID != nil
+	if input.SeriesID != nil {
+		if *input.SeriesID == "" {
+			unsetWork["seriesId"] = ""
+			unsetWork["volume"] = ""
+			inSeries = false
+		} else {
+			seriesId, err := checkSeries(ctx, *input.SeriesID)
+			if err != nil {
+				return nil, err
+			}
+			updateWork["seriesId"] = seriesId
+			inSeries = true
+		}
+	}
+	if input.Volume != nil {
+		if !inSeries {
+			return nil, errcode.Errorf(errcode.BadUserInput, "a volume number needs a series")
+		}
+		if *input.Volume <= 0 {
+			return nil, errcode.Errorf(errcode.BadUserInput, "volume number must be positive")
+		}
+		updateWork["volume"] = *input.Volume
+	}
+
+	update := bson.M{}
+	if len(updateWork) > 0 {
+		update["$set"] = updateWork
+	}
+	if len(unsetWork) > 0 {
+		update["$unset"] = unsetWork
+	}
+	if len(update) == 0 {
+		return &before, nil
+	}
+
+	var work model.Work
+	err = WorkCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": workId},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&work)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update work: %w", err)
+	}
+
+	audit.Track(ctx, "Work", id, &before, &work)
+	return &work, nil
+}
+
+// Editions lists the editions and translations grouped under a work
+func Editions(ctx context.Context, work *model.Work) ([]*model.Book, error) {
+	workId, err := primitive.ObjectIDFromHex(work.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid work ID")
+	}
+	return findBooks(ctx, bson.M{"workId": workId, "deletedAt": database.NotDeleted})
+}
+
+// BookWork is the work a book is an edition of, nil when it is not grouped
+func BookWork(ctx context.Context, book *model.Book) (*model.Work, error) {
+	if book.WorkID == nil {
+		return nil, nil
+	}
+	work, err := loaders.GetWork(ctx, book.WorkID.Hex())
+	if err != nil {
+		if errors.Is(err, loaders.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return work, nil
+}
+
+// OtherEditions lists the editions of a book's work other than the book itself
+func OtherEditions(ctx context.Context, book *model.Book) ([]*model.Book, error) {
+	if book.WorkID == nil {
+		return []*model.Book{}, nil
+	}
+	bookId, err := primitive.ObjectIDFromHex(book.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID")
+	}
+	return findBooks(ctx, bson.M{"workId": *book.WorkID, "_id": bson.M{"$ne": bookId}, "deletedAt": database.NotDeleted})
+}
+
+// NextInSeries is the work with the next higher volume number in the series of
+// a book's work, nil for the last volume or a book outside any series
+func NextInSeries(ctx context.Context, book *model.Book) (*model.Work, error) {
+	WorkCollection := database.DB.Collection("Works")
+
+	work, err := BookWork(ctx, book)
+	if err != nil || work == nil || work.SeriesID == nil || work.Volume == nil {
+		return nil, err
+	}
+
+	var next model.Work
+	err = WorkCollection.FindOne(ctx,
+		bson.M{"seriesId": *work.SeriesID, "volume": bson.M{"$gt": *work.Volume}},
+		options.FindOne().SetSort(bson.D{{Key: "volume", Value: 1}, {Key: "_id", Value: 1}}),
+	).Decode(&next)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find next in series: %w", err)
+	}
+	return &next, nil
+}
+
+// CheckWork returns the ID of an existing work, for linking editions to it
+func CheckWork(ctx context.Context, id string) (primitive.ObjectID, error) {
+	workId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, errcode.Errorf(errcode.BadUserInput, "invalid work ID %q", id)
+	}
+	count, err := database.DB.Collection("Works").CountDocuments(ctx, bson.M{"_id": workId})
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("failed to look up work: %w", err)
+	}
+	if count == 0 {
+		return primitive.NilObjectID, errcode.Errorf(errcode.NotFound, "work %s not found", id)
+	}
+	return workId, nil
+}
+
+// EnsureIndexes creates the indexes edition and series lookups rely on
+func EnsureIndexes(ctx context.Context) error {
+	_, err := database.DB.Collection("Books").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "workId", Value: 1}},
+		Options: options.Index().SetName("work_editions").SetSparse(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create edition index: %w", err)
+	}
+	_, err = database.DB.Collection("Works").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "seriesId", Value: 1}, {Key: "volume", Value: 1}},
+		Options: options.Index().SetName("series_volumes").SetSparse(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create series index: %w", err)
+	}
+	return nil
+}
+
+func findBooks(ctx context.Context, filter bson.M) ([]*model.Book, error) {
+	cursor, err := database.DB.Collection("Books").Find(ctx, filter, options.Find().SetSort(editionOrder))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch editions: %w", err)
+	}
+	books := []*model.Book{}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, fmt.Errorf("failed to decode editions: %w", err)
+	}
+	return books, nil
+}